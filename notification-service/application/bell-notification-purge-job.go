@@ -0,0 +1,118 @@
+package application
+
+import (
+	"fmt"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"time"
+)
+
+// BellNotificationPurgeJob permanently removes soft-deleted notifications once their restore
+// window has passed and notifications which outlived the retention policy. It runs as a single
+// recurring job of the job store, so only one replica purges at a time.
+type BellNotificationPurgeJob struct {
+	store         domain.BellNotificationStore
+	jobs          domain.JobStore
+	restoreWindow time.Duration
+	retention     domain.RetentionPolicy
+	interval      time.Duration
+	loki          promtail.Client
+}
+
+func NewBellNotificationPurgeJob(store domain.BellNotificationStore, jobs domain.JobStore, restoreWindow time.Duration, retention domain.RetentionPolicy, interval time.Duration, loki promtail.Client) *BellNotificationPurgeJob {
+	return &BellNotificationPurgeJob{
+		store:         store,
+		jobs:          jobs,
+		restoreWindow: restoreWindow,
+		retention:     retention,
+		interval:      interval,
+		loki:          loki,
+	}
+}
+
+// Schedule stores the recurring purge job unless a replica already has.
+func (job *BellNotificationPurgeJob) Schedule() error {
+	now := time.Now()
+	_, err := job.jobs.Schedule(&domain.Job{
+		Key:       string(domain.PurgeJob),
+		Kind:      domain.PurgeJob,
+		RunAt:     now.Add(job.interval),
+		CreatedAt: now,
+	})
+	return err
+}
+
+// Run is the JobHandler of the purge job. It purges and enforces the retention policy, and runs
+// again after the interval.
+func (job *BellNotificationPurgeJob) Run(scheduled *domain.Job, span trace.Span) (*domain.Job, error) {
+	job.Purge()
+	job.EnforceRetention()
+	scheduled.RunAt = time.Now().Add(job.interval)
+	return scheduled, nil
+}
+
+func (job *BellNotificationPurgeJob) Purge() {
+	purged, err := job.store.PurgeDeleted(time.Now().Add(-job.restoreWindow))
+	if err != nil {
+		job.logError("Purge", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d deleted notifications", purged)
+	}
+}
+
+// EnforceRetention deletes, or in dry run mode only reports, the notifications older than
+// allowed by the rule of their type.
+func (job *BellNotificationPurgeJob) EnforceRetention() {
+	overridden := make([]domain.NotificationType, 0, len(job.retention.ByType))
+	for notificationType, rule := range job.retention.ByType {
+		overridden = append(overridden, notificationType)
+		job.expire(rule, domain.RetentionFilter{Types: []domain.NotificationType{notificationType}}, fmt.Sprintf("type %d", notificationType))
+	}
+	job.expire(job.retention.Default, domain.RetentionFilter{Types: overridden, ExcludeTypes: true}, "default rule")
+}
+
+func (job *BellNotificationPurgeJob) expire(rule domain.RetentionRule, filter domain.RetentionFilter, description string) {
+	for _, seen := range []bool{true, false} {
+		keep := rule.Unseen
+		state := "unseen"
+		if seen {
+			keep = rule.Seen
+			state = "seen"
+		}
+		if keep <= 0 {
+			continue
+		}
+		filter.Seen = seen
+		filter.CreatedBefore = time.Now().Add(-keep)
+
+		if job.retention.DryRun {
+			count, err := job.store.CountExpired(filter)
+			if err != nil {
+				job.logError("EnforceRetention", err)
+				continue
+			}
+			message := fmt.Sprintf("Retention dry run: would delete %d %s notifications (%s) created before %s", count, state, description, filter.CreatedBefore.Format(time.RFC3339))
+			job.loki.Infof("service_name = %s, time = %s, data = %s\n", "EnforceRetention", time.Now().String(), message)
+			log.Print(message)
+			continue
+		}
+
+		deleted, err := job.store.DeleteExpired(filter)
+		if err != nil {
+			job.logError("EnforceRetention", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Deleted %d expired %s notifications (%s)", deleted, state, description)
+		}
+	}
+}
+
+func (job *BellNotificationPurgeJob) logError(funcName string, err error) {
+	job.loki.Errorf("service_name = %s, time = %s, error = %s\n", funcName, time.Now().String(), err)
+	log.Printf("Failed to clean up notifications: %v", err)
+}
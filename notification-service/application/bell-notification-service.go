@@ -5,29 +5,38 @@ import (
 	"github.com/mmmajder/zms-devops-notification-service/domain"
 	"github.com/mmmajder/zms-devops-notification-service/infrastructure/dto"
 	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"time"
 )
 
 type BellNotificationService struct {
-	store      domain.BellNotificationStore
-	HttpClient *http.Client
-	loki       promtail.Client
+	store         domain.BellNotificationStore
+	messages      domain.MessageRenderer
+	HttpClient    *http.Client
+	loki          promtail.Client
+	restoreWindow time.Duration
 }
 
-func NewBellNotificationService(store domain.BellNotificationStore, httpClient *http.Client, loki promtail.Client) *BellNotificationService {
+func NewBellNotificationService(store domain.BellNotificationStore, messages domain.MessageRenderer, httpClient *http.Client, loki promtail.Client, restoreWindow time.Duration) *BellNotificationService {
 	return &BellNotificationService{
-		store:      store,
-		HttpClient: httpClient,
-		loki:       loki,
+		store:         store,
+		messages:      messages,
+		HttpClient:    httpClient,
+		loki:          loki,
+		restoreWindow: restoreWindow,
 	}
 }
 
-func (service *BellNotificationService) Add(userId, message, redirectId string, shouldRedirect bool, span trace.Span, loki promtail.Client) (dto.BellNotificationDTO, error) {
+func (service *BellNotificationService) Add(id primitive.ObjectID, userId, locale string, notificationType domain.NotificationType, payload domain.NotificationPayload, redirectId string, shouldRedirect bool, span trace.Span, loki promtail.Client) (dto.BellNotificationDTO, error) {
 	notification := &domain.BellNotification{
+		Id:             id,
 		UserId:         userId,
-		Message:        message,
+		Type:           notificationType,
+		Payload:        payload,
+		MessageKey:     service.messages.MessageKey(notificationType, payload),
 		TimeStamp:      time.Now(),
 		Seen:           false,
 		ShouldRedirect: shouldRedirect,
@@ -40,27 +49,164 @@ func (service *BellNotificationService) Add(userId, message, redirectId string,
 		return dto.BellNotificationDTO{}, err
 	}
 
+	notification.Id = id
+
+	return service.toDTO(notification, []string{locale}, span, loki), nil
+}
+
+// Get returns the notification rendered in the first of the locales messages can be rendered in.
+func (service *BellNotificationService) Get(userId string, id primitive.ObjectID, locales []string, span trace.Span, loki promtail.Client) (dto.BellNotificationDTO, error) {
+	util.HttpTraceInfo("Fetching notification...", span, loki, "Get", "")
+	notification, err := service.store.Get(userId, id)
+	if err != nil {
+		return dto.BellNotificationDTO{}, err
+	}
+
+	return service.toDTO(notification, locales, span, loki), nil
+}
+
+// GetAllByUserId returns a page of the user's notifications and the cursor of the next page,
+// which is empty on the last page.
+func (service *BellNotificationService) GetAllByUserId(userId string, locales []string, query domain.BellNotificationQuery, span trace.Span, loki promtail.Client) ([]dto.BellNotificationDTO, string, error) {
+	util.HttpTraceInfo("Fetching notifications...", span, loki, "GetAllByUserId", "")
+	limit := query.Limit
+	if limit == 0 {
+		response, err := service.store.GetPageByUserId(userId, query)
+		if err != nil {
+			return []dto.BellNotificationDTO{}, "", err
+		}
+		return service.toDTOs(response, locales, span, loki), "", nil
+	}
+	query.Limit = limit + 1
+	response, err := service.store.GetPageByUserId(userId, query)
+	if err != nil {
+		return []dto.BellNotificationDTO{}, "", err
+	}
+
+	nextCursor := ""
+	if int64(len(response)) > limit {
+		response = response[:limit]
+		nextCursor = response[limit-1].Id.Hex()
+	}
+	return service.toDTOs(response, locales, span, loki), nextCursor, nil
+}
+
+// GetMissed returns the oldest notifications created after the cursor, at most
+// MaxReplayedNotifications of them, and whether newer ones were left out.
+func (service *BellNotificationService) GetMissed(userId string, locales []string, cursor domain.ReplayCursor, span trace.Span, loki promtail.Client) ([]dto.BellNotificationDTO, bool, error) {
+	util.HttpTraceInfo("Fetching missed notifications...", span, loki, "GetMissed", "")
+	response, err := service.store.GetAllByUserIdAfter(userId, cursor, domain.MaxReplayedNotifications+1)
+	if err != nil {
+		return []dto.BellNotificationDTO{}, false, err
+	}
+
+	truncated := int64(len(response)) > domain.MaxReplayedNotifications
+	if truncated {
+		response = response[:domain.MaxReplayedNotifications]
+	}
+	return service.toDTOs(response, locales, span, loki), truncated, nil
+}
+
+func (service *BellNotificationService) toDTOs(notifications []*domain.BellNotification, locales []string, span trace.Span, loki promtail.Client) []dto.BellNotificationDTO {
+	locale := service.locale(locales)
+	notificationDTOs := make([]dto.BellNotificationDTO, 0, len(notifications))
+	for _, notification := range notifications {
+		notificationDTOs = append(notificationDTOs, service.render(notification, locale, span, loki))
+	}
+	return notificationDTOs
+}
+
+func (service *BellNotificationService) toDTO(notification *domain.BellNotification, locales []string, span trace.Span, loki promtail.Client) dto.BellNotificationDTO {
+	return service.render(notification, service.locale(locales), span, loki)
+}
+
+// render renders the message of the notification in the locale. Notifications without a message
+// key and those whose template can not be rendered keep their stored message.
+func (service *BellNotificationService) render(notification *domain.BellNotification, locale string, span trace.Span, loki promtail.Client) dto.BellNotificationDTO {
 	notificationDTO := dto.FromNotification(notification)
-	notificationDTO.Id = id
+	if notification.MessageKey == "" {
+		return notificationDTO
+	}
+	message, err := service.messages.Render(locale, notification.MessageKey, notification.Payload)
+	if err != nil {
+		util.HttpTraceError(err, "failed to render notification "+notification.Id.Hex(), span, loki, "render", notification.UserId)
+		return notificationDTO
+	}
+	notificationDTO.Message = message
+	return notificationDTO
+}
 
-	return notificationDTO, nil
+// locale returns the first of the locales, in order of preference, that messages can be rendered
+// in, or an empty locale for the default one.
+func (service *BellNotificationService) locale(locales []string) string {
+	for _, locale := range locales {
+		if locale != "" && service.messages.Supports(locale) {
+			return locale
+		}
+	}
+	return ""
 }
 
-func (service *BellNotificationService) GetAllByUserId(userId string, span trace.Span, loki promtail.Client) ([]dto.BellNotificationDTO, error) {
-	util.HttpTraceInfo("Inserting review...", span, loki, "GetAllByUserId", "")
-	response, err := service.store.GetAllByUserId(userId)
+func (service *BellNotificationService) GetUnreadCount(userId string, span trace.Span, loki promtail.Client) (dto.UnreadCountDTO, error) {
+	util.HttpTraceInfo("Counting unread notifications...", span, loki, "GetUnreadCount", "")
+	count, err := service.store.CountUnseen(userId)
 	if err != nil {
-		return []dto.BellNotificationDTO{}, err
+		return dto.UnreadCountDTO{}, err
 	}
 
-	return *dto.FromReviews(response), nil
+	return dto.UnreadCountDTO{Count: count}, nil
 }
 
 func (service *BellNotificationService) UpdateStatus(userId string, span trace.Span, loki promtail.Client) error {
-	util.HttpTraceInfo("Inserting review...", span, loki, "UpdateStatus", "")
-	if err := service.store.UpdateManyStatus(userId); err != nil {
+	util.HttpTraceInfo("Marking all notifications as seen...", span, loki, "UpdateStatus", "")
+	if err := service.store.UpdateManyStatus(userId, time.Now()); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func (service *BellNotificationService) MarkSeen(userId string, id primitive.ObjectID, span trace.Span, loki promtail.Client) error {
+	return service.UpdateSeen(userId, []primitive.ObjectID{id}, true, span, loki)
+}
+
+// UpdateSeen marks the given notifications of the user as seen or unseen. It fails with
+// mongo.ErrNoDocuments if any of them does not exist or belongs to another user, whose
+// notifications are never matched by the update.
+func (service *BellNotificationService) UpdateSeen(userId string, ids []primitive.ObjectID, seen bool, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Updating notifications seen status...", span, loki, "UpdateSeen", "")
+	matched, err := service.store.UpdateStatus(userId, ids, seen, time.Now())
+	return matchedAll(matched, ids, err)
+}
+
+func (service *BellNotificationService) Archive(userId string, ids []primitive.ObjectID, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Archiving notifications...", span, loki, "Archive", "")
+	matched, err := service.store.Archive(userId, ids, time.Now())
+	return matchedAll(matched, ids, err)
+}
+
+func (service *BellNotificationService) Delete(userId string, ids []primitive.ObjectID, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Deleting notifications...", span, loki, "Delete", "")
+	matched, err := service.store.Delete(userId, ids, time.Now())
+	return matchedAll(matched, ids, err)
+}
+
+// Restore un-archives notifications and undoes deletions made within the restore window.
+// Notifications deleted from the archive are restored to it.
+func (service *BellNotificationService) Restore(userId string, ids []primitive.ObjectID, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Restoring notifications...", span, loki, "Restore", "")
+	matched, err := service.store.Restore(userId, ids, time.Now().Add(-service.restoreWindow))
+	return matchedAll(matched, ids, err)
+}
+
+// matchedAll reports mongo.ErrNoDocuments when some of the ids did not match a notification
+// of the user in a state the operation applies to, in which case the store changed none of them.
+func matchedAll(matched int64, ids []primitive.ObjectID, err error) error {
+	if err != nil {
+		return err
+	}
+	if matched != int64(len(ids)) {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
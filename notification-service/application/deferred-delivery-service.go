@@ -0,0 +1,92 @@
+package application
+
+import (
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// DeferredDeliveryService holds back the interruptive deliveries of notifications created during
+// the recipient's quiet hours and delivers them once the quiet hours end.
+type DeferredDeliveryService struct {
+	jobs       domain.JobStore
+	settings   *NotificationSettingsService
+	dispatcher *NotificationDispatcher
+	loki       promtail.Client
+}
+
+func NewDeferredDeliveryService(jobs domain.JobStore, settings *NotificationSettingsService, dispatcher *NotificationDispatcher, loki promtail.Client) *DeferredDeliveryService {
+	return &DeferredDeliveryService{
+		jobs:       jobs,
+		settings:   settings,
+		dispatcher: dispatcher,
+		loki:       loki,
+	}
+}
+
+// Defer splits the channels of a notification created during quiet hours, which end at until.
+// The interruptive ones, and the real-time toast of the bell, are scheduled for the end of the
+// quiet hours; the others are returned to be delivered right away.
+func (service *DeferredDeliveryService) Defer(delivery domain.Delivery, channels []domain.Channel, until time.Time, span trace.Span, loki promtail.Client) ([]domain.Channel, error) {
+	var immediate, deferred []domain.Channel
+	for _, channel := range channels {
+		if channel.Interruptive() || channel == domain.BellChannel {
+			deferred = append(deferred, channel)
+		}
+		if !channel.Interruptive() {
+			immediate = append(immediate, channel)
+		}
+	}
+	if len(deferred) == 0 {
+		return channels, nil
+	}
+
+	util.HttpTraceInfo("Deferring delivery until the end of quiet hours", span, loki, "Defer", delivery.Recipient.UserId)
+	notificationId, err := primitive.ObjectIDFromHex(delivery.NotificationId)
+	if err != nil {
+		return channels, err
+	}
+	job := &domain.Job{
+		Key:            string(domain.DeferredDeliveryJob) + ":" + delivery.NotificationId,
+		Kind:           domain.DeferredDeliveryJob,
+		UserId:         delivery.Recipient.UserId,
+		NotificationId: notificationId,
+		Type:           delivery.Type,
+		Payload:        delivery.Payload,
+		RedirectId:     delivery.RedirectId,
+		Channels:       deferred,
+		RunAt:          until,
+		CreatedAt:      time.Now(),
+	}
+	if _, err := service.jobs.Schedule(job); err != nil {
+		return channels, err
+	}
+	return immediate, nil
+}
+
+// Deliver is the JobHandler of deferred deliveries. It delivers the held back channels other than
+// the bell, unless the user's quiet hours have since been extended, in which case the job waits
+// for their new end.
+func (service *DeferredDeliveryService) Deliver(job *domain.Job, span trace.Span) (*domain.Job, error) {
+	if until, quiet := service.settings.QuietUntil(job.UserId, job.Type, time.Now(), span, service.loki); quiet {
+		job.RunAt = until
+		return job, nil
+	}
+
+	recipient, err := service.settings.GetRecipient(job.UserId, span, service.loki)
+	if err != nil {
+		return nil, err
+	}
+	util.HttpTraceInfo("Delivering notification held back during quiet hours", span, service.loki, "Deliver", job.UserId)
+	service.dispatcher.Dispatch(domain.Delivery{
+		NotificationId: job.NotificationId.Hex(),
+		Recipient:      recipient,
+		Type:           job.Type,
+		Payload:        job.Payload,
+		RedirectId:     job.RedirectId,
+	}, job.Channels, span, service.loki)
+	return nil, nil
+}
@@ -0,0 +1,142 @@
+package application
+
+import (
+	"errors"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+	"time"
+)
+
+// DeliveryLogService records how the delivery of every notification over each of its channels
+// went, for support to look into notifications users say they never got.
+type DeliveryLogService struct {
+	store domain.DeliveryLogStore
+	loki  promtail.Client
+}
+
+func NewDeliveryLogService(store domain.DeliveryLogStore, loki promtail.Client) *DeliveryLogService {
+	return &DeliveryLogService{
+		store: store,
+		loki:  loki,
+	}
+}
+
+// Start records the delivery over each of the channels as pending and returns the ids of the
+// records by channel. Failing to record does not stop the delivery, so the ids may be missing.
+func (service *DeliveryLogService) Start(delivery domain.Delivery, channels []domain.Channel, span trace.Span, loki promtail.Client) map[domain.Channel]primitive.ObjectID {
+	now := time.Now()
+	notificationId, _ := primitive.ObjectIDFromHex(delivery.NotificationId)
+	records := make([]*domain.DeliveryRecord, 0, len(channels))
+	for _, channel := range channels {
+		records = append(records, &domain.DeliveryRecord{
+			NotificationId: notificationId,
+			UserId:         delivery.Recipient.UserId,
+			Type:           delivery.Type,
+			Channel:        channel,
+			Status:         domain.PendingDelivery,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	if err := service.store.Insert(records); err != nil {
+		util.HttpTraceError(err, "failed to record pending deliveries", span, loki, "Start", delivery.Recipient.UserId)
+		return nil
+	}
+
+	ids := make(map[domain.Channel]primitive.ObjectID, len(records))
+	for _, record := range records {
+		ids[record.Channel] = record.Id
+	}
+	return ids
+}
+
+// Complete records the outcome of a pending delivery: sent when err is nil, skipped when it was
+// not attempted, bounced when the recipient's address rejected it, still pending when it is
+// retried later and failed otherwise.
+func (service *DeliveryLogService) Complete(id primitive.ObjectID, err error, span trace.Span, loki promtail.Client) {
+	if id.IsZero() {
+		return
+	}
+	status, errorMessage := deliveryOutcome(err)
+	if err := service.store.Complete(id, status, errorMessage, time.Now()); err != nil {
+		util.HttpTraceError(err, "failed to record delivery outcome", span, loki, "Complete", "")
+	}
+}
+
+// Record records a delivery that completed right away.
+func (service *DeliveryLogService) Record(delivery domain.Delivery, channel domain.Channel, err error, span trace.Span, loki promtail.Client) {
+	ids := service.Start(delivery, []domain.Channel{channel}, span, loki)
+	service.Complete(ids[channel], err, span, loki)
+}
+
+func deliveryOutcome(err error) (domain.DeliveryStatus, string) {
+	switch {
+	case err == nil:
+		return domain.SentDelivery, ""
+	case skipped(err):
+		return domain.SkippedDelivery, err.Error()
+	case errors.Is(err, domain.ErrBounced):
+		return domain.BouncedDelivery, err.Error()
+	case errors.Is(err, domain.ErrRetryScheduled):
+		return domain.PendingDelivery, err.Error()
+	default:
+		return domain.FailedDelivery, err.Error()
+	}
+}
+
+// skipped reports whether none of the channel's notifiers attempted the delivery, because the
+// channel is not configured or the user has no contact or consent for it.
+func skipped(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			if !skipped(err) {
+				return false
+			}
+		}
+		return true
+	}
+	return errors.Is(err, ErrChannelNotConfigured) || errors.Is(err, domain.ErrMissingContact) || errors.Is(err, domain.ErrNoConsent)
+}
+
+// Sent reports whether the notification has already been sent over the channel, so deliveries
+// which run again do not send it twice.
+func (service *DeliveryLogService) Sent(userId string, notificationId primitive.ObjectID, channel domain.Channel) (bool, error) {
+	records, err := service.store.GetByNotificationId(userId, notificationId)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(records, func(record *domain.DeliveryRecord) bool {
+		return record.Channel == channel && record.Status == domain.SentDelivery
+	}), nil
+}
+
+func (service *DeliveryLogService) GetByNotificationId(userId string, notificationId primitive.ObjectID, span trace.Span, loki promtail.Client) ([]*domain.DeliveryRecord, error) {
+	util.HttpTraceInfo("Fetching deliveries of notification...", span, loki, "GetByNotificationId", "")
+	return service.store.GetByNotificationId(userId, notificationId)
+}
+
+// GetByUserId returns a page of the user's delivery records and the cursor of the next page,
+// which is empty on the last page.
+func (service *DeliveryLogService) GetByUserId(userId string, query domain.DeliveryLogQuery, span trace.Span, loki promtail.Client) ([]*domain.DeliveryRecord, string, error) {
+	util.HttpTraceInfo("Fetching deliveries of user...", span, loki, "GetByUserId", "")
+	limit := query.Limit
+	query.Limit = limit + 1
+	records, err := service.store.GetPageByUserId(userId, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if int64(len(records)) > limit {
+		records = records[:limit]
+		nextCursor = records[limit-1].Id.Hex()
+	}
+	return records, nextCursor, nil
+}
@@ -0,0 +1,40 @@
+package application
+
+import (
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type DeviceService struct {
+	store domain.DeviceStore
+	loki  promtail.Client
+}
+
+func NewDeviceService(store domain.DeviceStore, loki promtail.Client) *DeviceService {
+	return &DeviceService{
+		store: store,
+		loki:  loki,
+	}
+}
+
+func (service *DeviceService) Register(device *domain.Device, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Registering device...", span, loki, "Register", "")
+	return service.store.Upsert(device)
+}
+
+// Unregister removes the user's device with the token. It fails with mongo.ErrNoDocuments when
+// the user has no such device.
+func (service *DeviceService) Unregister(userId, token string, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Unregistering device...", span, loki, "Unregister", "")
+	deleted, err := service.store.Delete(userId, token)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
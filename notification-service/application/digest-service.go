@@ -0,0 +1,178 @@
+package application
+
+import (
+	"fmt"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+	"time"
+)
+
+// DigestService collects the events of the types a user receives as a digest and summarizes them
+// in a single notification once per digest period. A digest is identified by its user, type and
+// the end of its period, so the events of a period share one job no matter which replica collects
+// them, and delivering it again after a restart does not create a second notification or email.
+type DigestService struct {
+	events      domain.DigestEventStore
+	jobs        domain.JobStore
+	bell        *BellNotificationService
+	settings    *NotificationSettingsService
+	dispatcher  *NotificationDispatcher
+	deliveryLog *DeliveryLogService
+	hour        int
+	loki        promtail.Client
+}
+
+// NewDigestService creates the service. Daily and weekly digests are delivered at the hour of the
+// day in the user's time zone.
+func NewDigestService(events domain.DigestEventStore, jobs domain.JobStore, bell *BellNotificationService, settings *NotificationSettingsService, dispatcher *NotificationDispatcher, deliveryLog *DeliveryLogService, hour int, loki promtail.Client) *DigestService {
+	return &DigestService{
+		events:      events,
+		jobs:        jobs,
+		bell:        bell,
+		settings:    settings,
+		dispatcher:  dispatcher,
+		deliveryLog: deliveryLog,
+		hour:        hour,
+		loki:        loki,
+	}
+}
+
+func digestJobKey(userId string, notificationType domain.NotificationType, due time.Time) string {
+	return fmt.Sprintf("%s:%s:%s:%s", domain.DigestJob, userId, notificationType, due.UTC().Format(time.RFC3339))
+}
+
+// Collect adds the notification to the digest of its period when the recipient's settings receive
+// its type as a digest. The channels a digest summarizes are returned without those of the
+// notification, the others, such as webhooks, are delivered right away.
+func (service *DigestService) Collect(delivery domain.Delivery, channels []domain.Channel, settings *domain.Settings, span trace.Span, loki promtail.Client) ([]domain.Channel, error) {
+	frequency, location := settings.DigestFor(delivery.Type), settings.Location()
+	if frequency == "" {
+		return channels, nil
+	}
+
+	util.HttpTraceInfo("Collecting notification for the "+string(frequency)+" digest", span, loki, "Collect", delivery.Recipient.UserId)
+	now := time.Now()
+	due := frequency.NextDigestAt(now, location, service.hour)
+	key := digestJobKey(delivery.Recipient.UserId, delivery.Type, due)
+	err := service.events.Insert(&domain.DigestEvent{
+		DigestKey:  key,
+		UserId:     delivery.Recipient.UserId,
+		Type:       delivery.Type,
+		Payload:    delivery.Payload,
+		RedirectId: delivery.RedirectId,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		return channels, err
+	}
+	// The notification id of the digest is chosen by whichever event schedules the job first.
+	_, err = service.jobs.Schedule(&domain.Job{
+		Key:            key,
+		Kind:           domain.DigestJob,
+		UserId:         delivery.Recipient.UserId,
+		NotificationId: primitive.NewObjectID(),
+		Type:           delivery.Type,
+		RunAt:          due,
+		CreatedAt:      now,
+	})
+	if err != nil {
+		return channels, err
+	}
+	return slices.DeleteFunc(slices.Clone(channels), digested), nil
+}
+
+// digested reports whether the channel is summarized by digests.
+func digested(channel domain.Channel) bool {
+	return channel != domain.WebhookChannel
+}
+
+// Deliver is the JobHandler of digests. It stores the digest as a bell notification and emails it,
+// over those of the two channels the user receives the summarized type over, and then drops the
+// collected events. A digest falling into the user's quiet hours waits for their end. The bell
+// notification takes the job's notification id, so running the job again does not store it twice,
+// and the email is not sent again once the delivery log has it sent under that id.
+func (service *DigestService) Deliver(job *domain.Job, span trace.Span) (*domain.Job, error) {
+	events, err := service.events.GetByDigestKey(job.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	if until, quiet := service.settings.QuietUntil(job.UserId, job.Type, time.Now(), span, service.loki); quiet {
+		job.RunAt = until
+		return job, nil
+	}
+
+	channels := slices.DeleteFunc(service.settings.ResolveChannels(job.UserId, job.Type, span, service.loki), func(channel domain.Channel) bool {
+		return channel != domain.BellChannel && channel != domain.EmailChannel
+	})
+	if len(channels) > 0 {
+		if err := service.deliver(job, events, channels, span); err != nil {
+			return nil, err
+		}
+	} else {
+		util.HttpTraceInfo("Dropping digest of a notification type the user turned off", span, service.loki, "Deliver", job.UserId)
+	}
+
+	// Events collected while the digest was delivered are kept for the next one.
+	until := slices.MaxFunc(events, func(a, b *domain.DigestEvent) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}).CreatedAt
+	_, err = service.events.DeleteByDigestKey(job.Key, until)
+	return nil, err
+}
+
+func (service *DigestService) deliver(job *domain.Job, events []*domain.DigestEvent, channels []domain.Channel, span trace.Span) error {
+	recipient, err := service.settings.GetRecipient(job.UserId, span, service.loki)
+	if err != nil {
+		return err
+	}
+	util.HttpTraceInfo(fmt.Sprintf("Delivering digest of %d notifications", len(events)), span, service.loki, "Deliver", job.UserId)
+	delivery := domain.Delivery{
+		NotificationId: job.NotificationId.Hex(),
+		Recipient:      recipient,
+		Type:           domain.Digest,
+		Payload:        domain.NotificationPayload{Status: job.Type.String(), Count: len(events)},
+		RedirectId:     digestRedirectId(events),
+	}
+
+	if slices.Contains(channels, domain.BellChannel) {
+		_, err := service.bell.Add(job.NotificationId, job.UserId, recipient.Locale, domain.Digest, delivery.Payload, delivery.RedirectId, delivery.RedirectId != "", span, service.loki)
+		if mongo.IsDuplicateKeyError(err) {
+			util.HttpTraceInfo("Digest notification was already stored", span, service.loki, "Deliver", job.UserId)
+		} else if err != nil {
+			return err
+		}
+	}
+	if slices.Contains(channels, domain.EmailChannel) {
+		sent, err := service.deliveryLog.Sent(job.UserId, job.NotificationId, domain.EmailChannel)
+		if err != nil {
+			return err
+		}
+		if sent {
+			util.HttpTraceInfo("Digest email was already sent", span, service.loki, "Deliver", job.UserId)
+			channels = slices.DeleteFunc(channels, func(channel domain.Channel) bool {
+				return channel == domain.EmailChannel
+			})
+		}
+	}
+	service.dispatcher.Dispatch(delivery, channels, span, service.loki)
+	return nil
+}
+
+// digestRedirectId returns the page all the events lead to, which is empty when they lead to
+// different pages.
+func digestRedirectId(events []*domain.DigestEvent) string {
+	for _, event := range events {
+		if event.RedirectId != events[0].RedirectId {
+			return ""
+		}
+	}
+	return events[0].RedirectId
+}
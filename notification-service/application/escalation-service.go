@@ -0,0 +1,132 @@
+package application
+
+import (
+	"errors"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+	"time"
+)
+
+// EscalationService delivers bell notifications which stay unseen over further channels, one
+// step of the escalation policy of their type at a time, to users who opted in to it for the
+// type. Escalation stops as soon as the notification is seen, archived or deleted.
+type EscalationService struct {
+	jobs       domain.JobStore
+	bell       domain.BellNotificationStore
+	settings   *NotificationSettingsService
+	dispatcher *NotificationDispatcher
+	policy     domain.EscalationPolicy
+	loki       promtail.Client
+}
+
+func NewEscalationService(jobs domain.JobStore, bell domain.BellNotificationStore, settings *NotificationSettingsService, dispatcher *NotificationDispatcher, policy domain.EscalationPolicy, loki promtail.Client) *EscalationService {
+	return &EscalationService{
+		jobs:       jobs,
+		bell:       bell,
+		settings:   settings,
+		dispatcher: dispatcher,
+		policy:     policy,
+		loki:       loki,
+	}
+}
+
+func escalationJobKey(notificationId primitive.ObjectID) string {
+	return string(domain.EscalationJob) + ":" + notificationId.Hex()
+}
+
+// Schedule starts escalating the bell notification when the policy has steps for its type.
+func (service *EscalationService) Schedule(notificationId primitive.ObjectID, userId string, notificationType domain.NotificationType, createdAt time.Time, span trace.Span, loki promtail.Client) error {
+	steps := service.policy[notificationType]
+	if len(steps) == 0 {
+		return nil
+	}
+	util.HttpTraceInfo("Scheduling escalation...", span, loki, "Schedule", notificationId.Hex())
+	_, err := service.jobs.Schedule(&domain.Job{
+		Key:            escalationJobKey(notificationId),
+		Kind:           domain.EscalationJob,
+		UserId:         userId,
+		NotificationId: notificationId,
+		RunAt:          createdAt.Add(steps[0].After),
+		CreatedAt:      time.Now(),
+	})
+	return err
+}
+
+// Cancel stops escalating the given notifications.
+func (service *EscalationService) Cancel(ids []primitive.ObjectID, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Cancelling escalations...", span, loki, "Cancel", "")
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, escalationJobKey(id))
+	}
+	_, err := service.jobs.CancelByKeys(keys)
+	return err
+}
+
+// CancelAll stops escalating every notification of the user.
+func (service *EscalationService) CancelAll(userId string, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Cancelling all escalations...", span, loki, "CancelAll", "")
+	_, err := service.jobs.CancelByUserId(userId, domain.EscalationJob)
+	return err
+}
+
+// Escalate is the JobHandler of escalation jobs. It delivers the notification over the channel of
+// the job's step, unless the user already receives the type over it, and moves the job on to the
+// next step. Steps are timed from the creation of the notification; a step falling into the
+// user's quiet hours waits for their end.
+func (service *EscalationService) Escalate(job *domain.Job, span trace.Span) (*domain.Job, error) {
+	notification, err := service.bell.Get(job.UserId, job.NotificationId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	steps := service.policy[notification.Type]
+	if notification.Seen || !notification.ArchivedAt.IsZero() || job.Step >= len(steps) {
+		return nil, nil
+	}
+
+	channels := service.settings.ResolveChannels(job.UserId, notification.Type, span, service.loki)
+	if len(channels) == 0 {
+		util.HttpTraceInfo("Stopping escalation of a notification type the user turned off", span, service.loki, "Escalate", job.UserId)
+		return nil, nil
+	}
+	if !service.settings.Escalates(job.UserId, notification.Type, span, service.loki) {
+		util.HttpTraceInfo("Stopping escalation of a notification type the user has not opted in to escalate", span, service.loki, "Escalate", job.UserId)
+		return nil, nil
+	}
+	if until, quiet := service.settings.QuietUntil(job.UserId, notification.Type, time.Now(), span, service.loki); quiet {
+		job.RunAt = until
+		return job, nil
+	}
+	step := steps[job.Step]
+	if slices.Contains(channels, step.Channel) {
+		util.HttpTraceInfo("Skipping escalation over "+string(step.Channel)+", the notification was delivered over it", span, service.loki, "Escalate", job.UserId)
+	} else {
+		recipient, err := service.settings.GetRecipient(job.UserId, span, service.loki)
+		if err != nil {
+			return nil, err
+		}
+		util.HttpTraceInfo("Escalating unseen notification over "+string(step.Channel), span, service.loki, "Escalate", job.UserId)
+		service.dispatcher.Dispatch(domain.Delivery{
+			NotificationId: notification.Id.Hex(),
+			Recipient:      recipient,
+			Type:           notification.Type,
+			Payload:        notification.Payload,
+			RedirectId:     notification.RedirectId,
+		}, []domain.Channel{step.Channel}, span, service.loki)
+	}
+
+	if job.Step+1 == len(steps) {
+		return nil, nil
+	}
+	job.Step++
+	job.RunAt = notification.TimeStamp.Add(steps[job.Step].After)
+	return job, nil
+}
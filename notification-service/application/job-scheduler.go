@@ -0,0 +1,140 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"time"
+)
+
+// maxJobBackoff bounds how long a failing job waits before it is retried.
+const maxJobBackoff = time.Hour
+
+// JobHandler runs a claimed job. It returns the job again, with a new run time, when the job has
+// more work to do later, or nil when the job is done.
+type JobHandler func(job *domain.Job, span trace.Span) (*domain.Job, error)
+
+// JobScheduler runs the jobs stored in the job store once they are due. Jobs survive restarts,
+// and since every job is claimed with a lease any number of replicas can run the scheduler. The
+// lease is extended while the job runs, and a job whose replica died while running it is claimed
+// again when its lease expires.
+type JobScheduler struct {
+	store       domain.JobStore
+	handlers    map[domain.JobKind]JobHandler
+	owner       string
+	interval    time.Duration
+	lease       time.Duration
+	maxAttempts int
+	tracer      trace.Tracer
+	loki        promtail.Client
+}
+
+func NewJobScheduler(store domain.JobStore, owner string, interval, lease time.Duration, maxAttempts int, tracer trace.Tracer, loki promtail.Client) *JobScheduler {
+	return &JobScheduler{
+		store:       store,
+		handlers:    map[domain.JobKind]JobHandler{},
+		owner:       owner,
+		interval:    interval,
+		lease:       lease,
+		maxAttempts: maxAttempts,
+		tracer:      tracer,
+		loki:        loki,
+	}
+}
+
+func (scheduler *JobScheduler) Register(kind domain.JobKind, handler JobHandler) {
+	scheduler.handlers[kind] = handler
+}
+
+func (scheduler *JobScheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(scheduler.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			scheduler.RunDue(time.Now())
+		}
+	}()
+}
+
+// RunDue runs the jobs due at the given time one by one until none is left.
+func (scheduler *JobScheduler) RunDue(now time.Time) {
+	for {
+		job, err := scheduler.store.Claim(scheduler.owner, now, scheduler.lease)
+		if err != nil {
+			scheduler.logError("RunDue", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		scheduler.run(job, now)
+	}
+}
+
+func (scheduler *JobScheduler) run(job *domain.Job, now time.Time) {
+	_, span := scheduler.tracer.Start(context.TODO(), "run-job-"+string(job.Kind))
+	defer func() { span.End() }()
+
+	stopLease := scheduler.keepLease(job, now)
+	next, err := scheduler.handle(job, span)
+	stopLease()
+	switch {
+	case err != nil && job.Attempts < scheduler.maxAttempts:
+		log.Printf("Job %s failed on attempt %d, retrying: %v", job.Key, job.Attempts, err)
+		job.RunAt = now.Add(min(scheduler.interval<<min(max(job.Attempts-1, 0), 10), maxJobBackoff))
+		job.LastError = err.Error()
+		err = scheduler.store.Reschedule(job, scheduler.owner)
+	case err != nil:
+		scheduler.logError("run", fmt.Errorf("job %s failed after %d attempts: %w", job.Key, job.Attempts, err))
+		err = scheduler.store.Complete(job, scheduler.owner)
+	case next != nil:
+		next.Attempts = 0
+		next.LastError = ""
+		err = scheduler.store.Reschedule(next, scheduler.owner)
+	default:
+		err = scheduler.store.Complete(job, scheduler.owner)
+	}
+	if err != nil {
+		scheduler.logError("run", err)
+	}
+}
+
+// keepLease extends the lease of the job every third of the lease while its handler runs, so a
+// handler running longer than the lease does not let another replica claim the job. The lease is
+// timed from the claim time, which the returned function stops extending.
+func (scheduler *JobScheduler) keepLease(job *domain.Job, now time.Time) func() {
+	started := time.Now()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(scheduler.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				until := now.Add(time.Since(started) + scheduler.lease)
+				if err := scheduler.store.ExtendLease(job, scheduler.owner, until); err != nil {
+					scheduler.logError("keepLease", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (scheduler *JobScheduler) handle(job *domain.Job, span trace.Span) (*domain.Job, error) {
+	handler, ok := scheduler.handlers[job.Kind]
+	if !ok {
+		return nil, fmt.Errorf("no handler for jobs of kind %s", job.Kind)
+	}
+	return handler(job, span)
+}
+
+func (scheduler *JobScheduler) logError(funcName string, err error) {
+	scheduler.loki.Errorf("service_name = %s, time = %s, error = %s\n", funcName, time.Now().String(), err)
+	log.Printf("Failed to run scheduled jobs: %v", err)
+}
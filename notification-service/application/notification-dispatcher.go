@@ -0,0 +1,91 @@
+package application
+
+import (
+	"errors"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+)
+
+// NotificationDispatcher delivers notifications over the channels other than the bell, which
+// is served by the BellNotificationService.
+type NotificationDispatcher struct {
+	notifiers   map[domain.Channel][]domain.Notifier
+	messages    domain.MessageRenderer
+	deliveryLog *DeliveryLogService
+}
+
+var ErrChannelNotConfigured = errors.New("channel is not configured")
+
+func NewNotificationDispatcher(messages domain.MessageRenderer, deliveryLog *DeliveryLogService, notifiers ...domain.Notifier) *NotificationDispatcher {
+	dispatcher := &NotificationDispatcher{
+		notifiers:   map[domain.Channel][]domain.Notifier{},
+		messages:    messages,
+		deliveryLog: deliveryLog,
+	}
+	for _, notifier := range notifiers {
+		dispatcher.notifiers[notifier.Channel()] = append(dispatcher.notifiers[notifier.Channel()], notifier)
+	}
+	return dispatcher
+}
+
+// Dispatch delivers the notification over each of the channels other than the bell and records
+// the outcome per channel in the delivery log. A failing notifier does not keep the others from
+// delivering, and a channel delivers when any of its notifiers does.
+func (dispatcher *NotificationDispatcher) Dispatch(delivery domain.Delivery, channels []domain.Channel, span trace.Span, loki promtail.Client) {
+	channels = slices.DeleteFunc(slices.Clone(channels), func(channel domain.Channel) bool {
+		return channel == domain.BellChannel
+	})
+	records := dispatcher.deliveryLog.Start(delivery, channels, span, loki)
+
+	if delivery.Message == "" {
+		message, err := dispatcher.messages.Render(delivery.Recipient.Locale, dispatcher.messages.MessageKey(delivery.Type, delivery.Payload), delivery.Payload)
+		if err != nil {
+			util.HttpTraceError(err, "failed to render notification", span, loki, "Dispatch", "")
+			for _, channel := range channels {
+				dispatcher.deliveryLog.Complete(records[channel], err, span, loki)
+			}
+			return
+		}
+		delivery.Message = message
+	}
+
+	for _, channel := range channels {
+		dispatcher.deliveryLog.Complete(records[channel], dispatcher.deliver(delivery, channel, span, loki), span, loki)
+	}
+}
+
+func (dispatcher *NotificationDispatcher) deliver(delivery domain.Delivery, channel domain.Channel, span trace.Span, loki promtail.Client) error {
+	notifiers := dispatcher.notifiers[channel]
+	if len(notifiers) == 0 {
+		util.HttpTraceInfo("Skipping "+string(channel)+" delivery, the channel is not configured", span, loki, "Dispatch", delivery.Recipient.UserId)
+		return ErrChannelNotConfigured
+	}
+
+	var errs []error
+	delivered := false
+	for _, notifier := range notifiers {
+		err := notifier.Notify(delivery)
+		switch {
+		case errors.Is(err, domain.ErrMissingContact):
+			util.HttpTraceInfo("Skipping "+string(channel)+" delivery without contact", span, loki, "Dispatch", delivery.Recipient.UserId)
+		case errors.Is(err, domain.ErrNoConsent):
+			util.HttpTraceInfo("Skipping "+string(channel)+" delivery without consent", span, loki, "Dispatch", delivery.Recipient.UserId)
+		case errors.Is(err, domain.ErrRetryScheduled):
+			util.HttpTraceInfo("Retrying "+string(channel)+" delivery later: "+err.Error(), span, loki, "Dispatch", delivery.Recipient.UserId)
+		case err != nil:
+			util.HttpTraceError(err, "failed to deliver notification over "+string(channel), span, loki, "Dispatch", delivery.Recipient.UserId)
+		default:
+			util.HttpTraceInfo("Delivered notification over "+string(channel), span, loki, "Dispatch", delivery.Recipient.UserId)
+			delivered = true
+			continue
+		}
+		errs = append(errs, err)
+	}
+	if delivered {
+		return nil
+	}
+	return errors.Join(errs...)
+}
@@ -1,6 +1,7 @@
 package application
 
 import (
+	"errors"
 	"github.com/afiskon/promtail-client/promtail"
 	"github.com/mmmajder/zms-devops-notification-service/domain"
 	"github.com/mmmajder/zms-devops-notification-service/infrastructure/dto"
@@ -8,49 +9,64 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"log"
 	"net/http"
+	"slices"
+	"time"
 )
 
+var ErrUnsupportedLocale = errors.New(domain.UnsupportedLocaleErrorMessage)
+
 type NotificationSettingsService struct {
-	store      domain.UserNotificationSettingsStore
-	HttpClient *http.Client
-	loki       promtail.Client
+	store       domain.UserNotificationSettingsStore
+	messages    domain.MessageRenderer
+	urgentTypes []domain.NotificationType
+	HttpClient  *http.Client
+	loki        promtail.Client
 }
 
-func NewNotificationSettingsService(store domain.UserNotificationSettingsStore, httpClient *http.Client, loki promtail.Client) *NotificationSettingsService {
+// NewNotificationSettingsService creates the service. Notifications of the urgent types are
+// delivered during quiet hours too.
+func NewNotificationSettingsService(store domain.UserNotificationSettingsStore, messages domain.MessageRenderer, urgentTypes []domain.NotificationType, httpClient *http.Client, loki promtail.Client) *NotificationSettingsService {
 	return &NotificationSettingsService{
-		store:      store,
-		HttpClient: httpClient,
-		loki:       loki,
+		store:       store,
+		messages:    messages,
+		urgentTypes: urgentTypes,
+		HttpClient:  httpClient,
+		loki:        loki,
 	}
 }
 
-func (service *NotificationSettingsService) Insert(userId, role string, span trace.Span, loki promtail.Client) error {
+func (service *NotificationSettingsService) Insert(userId, role, email string, span trace.Span, loki promtail.Client) error {
 	var notificationSettings []domain.NotificationSetting
 	log.Printf("userId: %s, role: %s", userId, role)
 	if role == domain.RoleGuest {
 		notificationSettings = []domain.NotificationSetting{
 			{
-				Type:   4,
-				Active: true,
+				Type:     4,
+				Active:   true,
+				Channels: map[domain.Channel]bool{domain.BellChannel: true, domain.SmsChannel: true},
 			},
 		}
 	} else if role == domain.HostRole {
 		notificationSettings = []domain.NotificationSetting{
 			{
-				Type:   0,
-				Active: true,
+				Type:     0,
+				Active:   true,
+				Channels: map[domain.Channel]bool{domain.BellChannel: true, domain.EmailChannel: true},
 			},
 			{
-				Type:   1,
-				Active: true,
+				Type:     1,
+				Active:   true,
+				Channels: defaultChannels(),
 			},
 			{
-				Type:   2,
-				Active: true,
+				Type:     2,
+				Active:   true,
+				Channels: defaultChannels(),
 			},
 			{
-				Type:   3,
-				Active: true,
+				Type:     3,
+				Active:   true,
+				Channels: defaultChannels(),
 			},
 		}
 	}
@@ -58,6 +74,7 @@ func (service *NotificationSettingsService) Insert(userId, role string, span tra
 	settings := domain.Settings{
 		UserId:   userId,
 		Settings: notificationSettings,
+		Email:    email,
 	}
 
 	util.HttpTraceInfo("Inserting review...", span, loki, "Insert", "")
@@ -69,12 +86,13 @@ func (service *NotificationSettingsService) Insert(userId, role string, span tra
 	return nil
 }
 
-func (service *NotificationSettingsService) Update(userId string, userRole string, settingsRequest []domain.NotificationSetting, span trace.Span, loki promtail.Client) error {
+func (service *NotificationSettingsService) Update(userId string, userRole string, updates []domain.NotificationSettingUpdate, span trace.Span, loki promtail.Client) error {
 	util.HttpTraceInfo("Inserting review...", span, loki, "Update", "")
 	settings, err := service.store.GetByUserId(userId)
 	if err != nil {
 		return err
 	}
+	settingsRequest := service.applyUpdates(updates, settings.Settings)
 	if userRole == domain.HostRole {
 		settings.Settings = service.excludeNotificationSettings(settingsRequest, domain.ReviewReservation)
 	} else {
@@ -99,6 +117,145 @@ func (service *NotificationSettingsService) Get(userId string, span trace.Span,
 	return dto.FromUserNotificationSettings(settings), nil
 }
 
+// GetLocale returns the locale the user's notifications are rendered in, which is empty when
+// the user has not chosen one and the default locale applies.
+func (service *NotificationSettingsService) GetLocale(userId string, span trace.Span, loki promtail.Client) (string, error) {
+	util.HttpTraceInfo("Fetching locale...", span, loki, "GetLocale", "")
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return "", err
+	}
+
+	return settings.Locale, nil
+}
+
+func (service *NotificationSettingsService) UpdateLocale(userId, locale string, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Updating locale...", span, loki, "UpdateLocale", "")
+	if locale != "" && !service.messages.Supports(locale) {
+		return ErrUnsupportedLocale
+	}
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return err
+	}
+
+	return service.store.UpdateLocale(settings.Id, locale)
+}
+
+// Load returns the user's settings, for callers which ask several questions of them at once.
+func (service *NotificationSettingsService) Load(userId string, span trace.Span, loki promtail.Client) (*domain.Settings, error) {
+	util.HttpTraceInfo("Fetching settings...", span, loki, "Load", "")
+	return service.store.GetByUserId(userId)
+}
+
+// GetRecipient returns the contacts and the locale notifications are delivered to the user with.
+func (service *NotificationSettingsService) GetRecipient(userId string, span trace.Span, loki promtail.Client) (domain.Recipient, error) {
+	util.HttpTraceInfo("Fetching recipient...", span, loki, "GetRecipient", "")
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return domain.Recipient{UserId: userId}, err
+	}
+
+	recipient := settings.Recipient()
+	recipient.UserId = userId
+	return recipient, nil
+}
+
+func (service *NotificationSettingsService) GetEmail(userId string, span trace.Span, loki promtail.Client) (string, error) {
+	util.HttpTraceInfo("Fetching email...", span, loki, "GetEmail", "")
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return "", err
+	}
+
+	return settings.Email, nil
+}
+
+func (service *NotificationSettingsService) UpdateEmail(userId, email string, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Updating email...", span, loki, "UpdateEmail", "")
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return err
+	}
+
+	return service.store.UpdateEmail(settings.Id, email)
+}
+
+func (service *NotificationSettingsService) GetPhone(userId string, span trace.Span, loki promtail.Client) (dto.PhoneDTO, error) {
+	util.HttpTraceInfo("Fetching phone...", span, loki, "GetPhone", "")
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return dto.PhoneDTO{}, err
+	}
+
+	return dto.FromPhone(settings), nil
+}
+
+// UpdatePhone stores the number SMS are sent to. The consent to receive SMS is given for a
+// number, so it is recorded anew whenever the number changes and dropped with the number.
+func (service *NotificationSettingsService) UpdatePhone(userId, phone string, smsOptIn bool, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Updating phone...", span, loki, "UpdatePhone", "")
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return err
+	}
+
+	smsOptIn = smsOptIn && phone != ""
+	if smsOptIn && (!settings.SmsOptIn || settings.Phone != phone) {
+		settings.SmsOptInAt = time.Now()
+	}
+	if !smsOptIn {
+		settings.SmsOptInAt = time.Time{}
+	}
+	settings.Phone = phone
+	settings.SmsOptIn = smsOptIn
+	return service.store.UpdatePhone(settings.Id, settings)
+}
+
+func (service *NotificationSettingsService) GetQuietHours(userId string, span trace.Span, loki promtail.Client) (dto.QuietHoursDTO, error) {
+	util.HttpTraceInfo("Fetching quiet hours...", span, loki, "GetQuietHours", "")
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return dto.QuietHoursDTO{}, err
+	}
+
+	return dto.FromQuietHours(settings), nil
+}
+
+func (service *NotificationSettingsService) UpdateQuietHours(userId, timeZone string, quietHours []domain.QuietHours, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Updating quiet hours...", span, loki, "UpdateQuietHours", "")
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return err
+	}
+
+	settings.TimeZone = timeZone
+	settings.QuietHours = quietHours
+	return service.store.UpdateQuietHours(settings.Id, settings)
+}
+
+// QuietUntil reports whether interruptive deliveries of the type to the user are held back at
+// the given time and, if so, until when. Urgent types are never held back.
+func (service *NotificationSettingsService) QuietUntil(userId string, notificationType domain.NotificationType, now time.Time, span trace.Span, loki promtail.Client) (time.Time, bool) {
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return service.QuietUntilFor(settings, notificationType, now, span, loki)
+}
+
+// QuietUntilFor is QuietUntil for settings already loaded.
+func (service *NotificationSettingsService) QuietUntilFor(settings *domain.Settings, notificationType domain.NotificationType, now time.Time, span trace.Span, loki promtail.Client) (time.Time, bool) {
+	if slices.Contains(service.urgentTypes, notificationType) {
+		return time.Time{}, false
+	}
+	until, quiet := settings.QuietUntil(now)
+	if quiet {
+		util.HttpTraceInfo("Holding back notification during quiet hours until "+until.Format(time.RFC3339), span, loki, "QuietUntil", settings.UserId)
+	}
+	return until, quiet
+}
+
 func (service *NotificationSettingsService) Delete(userId string, span trace.Span, loki promtail.Client) error {
 	util.HttpTraceInfo("Inserting review...", span, loki, "Delete", "")
 	err := service.store.DeleteByUserId(userId)
@@ -109,25 +266,53 @@ func (service *NotificationSettingsService) Delete(userId string, span trace.Spa
 	return nil
 }
 
-func (service *NotificationSettingsService) UserIsSubscribedToNotificationType(userId string, notificationType domain.NotificationType, span trace.Span, loki promtail.Client) bool {
-	util.HttpTraceInfo("Inserting review...", span, loki, "UserIsSubscribedToNotificationType", "")
+// ResolveChannels returns the channels an event of the type is delivered over to the user, which
+// is empty when the user has no settings or has turned the type off.
+func (service *NotificationSettingsService) ResolveChannels(userId string, notificationType domain.NotificationType, span trace.Span, loki promtail.Client) []domain.Channel {
+	util.HttpTraceInfo("Resolving channels...", span, loki, "ResolveChannels", "")
 	settings, err := service.store.GetByUserId(userId)
 	if err != nil {
-		return false
+		return nil
 	}
-
-	return service.findIfUserHasSpecificActiveNotification(settings, notificationType)
+	return settings.ChannelsFor(notificationType)
 }
 
-func (service *NotificationSettingsService) findIfUserHasSpecificActiveNotification(settings *domain.Settings, notificationType domain.NotificationType) bool {
+// Escalates reports whether the user opted in to escalating unseen notifications of the type.
+func (service *NotificationSettingsService) Escalates(userId string, notificationType domain.NotificationType, span trace.Span, loki promtail.Client) bool {
+	util.HttpTraceInfo("Fetching escalation opt-in...", span, loki, "Escalates", "")
+	settings, err := service.store.GetByUserId(userId)
+	if err != nil {
+		return false
+	}
+
 	for _, setting := range settings.Settings {
-		if setting.Active && setting.Type == notificationType {
-			return true
+		if setting.Type == notificationType && setting.Active {
+			return setting.Escalate
 		}
 	}
 	return false
 }
 
+// applyUpdates merges the updates into the stored settings of their types, keeping the stored
+// channels, digest frequency and escalation opt-in the updates leave out.
+func (service *NotificationSettingsService) applyUpdates(updates []domain.NotificationSettingUpdate, stored []domain.NotificationSetting) []domain.NotificationSetting {
+	settings := make([]domain.NotificationSetting, 0, len(updates))
+	for _, update := range updates {
+		var storedSetting domain.NotificationSetting
+		for _, setting := range stored {
+			if setting.Type == update.Type {
+				storedSetting = setting
+			}
+		}
+		settings = append(settings, update.Apply(storedSetting))
+	}
+	return settings
+}
+
+func defaultChannels() map[domain.Channel]bool {
+	return map[domain.Channel]bool{domain.BellChannel: true}
+}
+
 func (service *NotificationSettingsService) filterNotificationSettings(settings []domain.NotificationSetting, notificationType domain.NotificationType) []domain.NotificationSetting {
 	var filteredSettings []domain.NotificationSetting
 
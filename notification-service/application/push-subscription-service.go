@@ -0,0 +1,40 @@
+package application
+
+import (
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type PushSubscriptionService struct {
+	store domain.PushSubscriptionStore
+	loki  promtail.Client
+}
+
+func NewPushSubscriptionService(store domain.PushSubscriptionStore, loki promtail.Client) *PushSubscriptionService {
+	return &PushSubscriptionService{
+		store: store,
+		loki:  loki,
+	}
+}
+
+func (service *PushSubscriptionService) Subscribe(subscription *domain.PushSubscription, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Registering push subscription...", span, loki, "Subscribe", "")
+	return service.store.Upsert(subscription)
+}
+
+// Unsubscribe removes the user's subscription with the endpoint. It fails with
+// mongo.ErrNoDocuments when the user has no such subscription.
+func (service *PushSubscriptionService) Unsubscribe(userId, endpoint string, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Removing push subscription...", span, loki, "Unsubscribe", "")
+	deleted, err := service.store.Delete(userId, endpoint)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package application
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const webhookSecretPrefix = "whsec_"
+
+var ErrUnsafeWebhookUrl = errors.New(domain.UnsafeWebhookUrlErrorMessage)
+
+type WebhookService struct {
+	store    domain.WebhookStore
+	checkUrl func(rawUrl string) error
+	loki     promtail.Client
+}
+
+// NewWebhookService creates the service. checkUrl rejects the urls webhooks may not be registered
+// with, such as those of the cluster's internal services.
+func NewWebhookService(store domain.WebhookStore, checkUrl func(rawUrl string) error, loki promtail.Client) *WebhookService {
+	return &WebhookService{
+		store:    store,
+		checkUrl: checkUrl,
+		loki:     loki,
+	}
+}
+
+// Register stores an active webhook with a newly generated signing secret. The returned webhook
+// is the only place the secret is shown.
+func (service *WebhookService) Register(webhook *domain.Webhook, span trace.Span, loki promtail.Client) (*domain.Webhook, error) {
+	util.HttpTraceInfo("Registering webhook...", span, loki, "Register", "")
+	if err := service.checkUrl(webhook.Url); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsafeWebhookUrl, err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	webhook.Secret = webhookSecretPrefix + hex.EncodeToString(secret)
+	webhook.Active = true
+	webhook.CreatedAt = time.Now()
+	if err := service.store.Insert(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (service *WebhookService) GetByUserId(userId string, span trace.Span, loki promtail.Client) ([]*domain.Webhook, error) {
+	util.HttpTraceInfo("Fetching webhooks...", span, loki, "GetByUserId", "")
+	return service.store.GetByUserId(userId)
+}
+
+// Delete removes the user's webhook. It fails with mongo.ErrNoDocuments when the user has no
+// such webhook.
+func (service *WebhookService) Delete(userId string, id primitive.ObjectID, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Removing webhook...", span, loki, "Delete", "")
+	deleted, err := service.store.Delete(userId, id)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// Enable activates a webhook disabled after failed deliveries and clears its failure count.
+func (service *WebhookService) Enable(userId string, id primitive.ObjectID, span trace.Span, loki promtail.Client) error {
+	util.HttpTraceInfo("Enabling webhook...", span, loki, "Enable", "")
+	matched, err := service.store.Enable(userId, id)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// GetAttempts returns the latest delivery attempts of the user's webhook, newest first.
+func (service *WebhookService) GetAttempts(userId string, id primitive.ObjectID, limit int64, span trace.Span, loki promtail.Client) ([]*domain.WebhookAttempt, error) {
+	util.HttpTraceInfo("Fetching webhook delivery attempts...", span, loki, "GetAttempts", "")
+	if _, err := service.store.Get(userId, id); err != nil {
+		return nil, err
+	}
+	return service.store.GetAttempts(id, limit)
+}
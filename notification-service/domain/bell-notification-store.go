@@ -2,11 +2,21 @@ package domain
 
 import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 )
 
 type BellNotificationStore interface {
-	GetAllByUserId(userId string) ([]*BellNotification, error)
+	Get(userId string, id primitive.ObjectID) (*BellNotification, error)
+	GetPageByUserId(userId string, query BellNotificationQuery) ([]*BellNotification, error)
+	GetAllByUserIdAfter(userId string, cursor ReplayCursor, limit int64) ([]*BellNotification, error)
+	CountUnseen(userId string) (int64, error)
 	Insert(review *BellNotification) (primitive.ObjectID, error)
-	UpdateStatus(id primitive.ObjectID, review *BellNotification) error
-	UpdateManyStatus(userId string) error
+	UpdateStatus(userId string, ids []primitive.ObjectID, seen bool, seenAt time.Time) (int64, error)
+	UpdateManyStatus(userId string, seenAt time.Time) error
+	Archive(userId string, ids []primitive.ObjectID, archivedAt time.Time) (int64, error)
+	Delete(userId string, ids []primitive.ObjectID, deletedAt time.Time) (int64, error)
+	Restore(userId string, ids []primitive.ObjectID, deletedAfter time.Time) (int64, error)
+	PurgeDeleted(deletedBefore time.Time) (int64, error)
+	CountExpired(filter RetentionFilter) (int64, error)
+	DeleteExpired(filter RetentionFilter) (int64, error)
 }
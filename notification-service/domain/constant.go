@@ -1,16 +1,44 @@
 package domain
 
 const (
-	ServiceName                 string = "notification-service"
-	NotificationContextPath     string = "/notification"
-	BellNotificationContextPath string = "/notification/bell"
-	ContentType                 string = "Content-Type"
-	JsonContentType             string = "application/json"
-	HealthCheckMessage          string = "NOTIFICATION SERVICE IS HEALTH"
-	InvalidIDErrorMessage       string = "Invalid user ID"
-	HostRole                    string = "host"
-	UserIDParam                 string = "/{userId}"
-	ReservationRedirectUrlStart string = "reservation/view/"
-	RoleGuest                   string = "guest"
-	RoleHost                    string = "host"
+	ServiceName                       string = "notification-service"
+	NotificationContextPath           string = "/notification"
+	BellNotificationContextPath       string = "/notification/bell"
+	ContentType                       string = "Content-Type"
+	JsonContentType                   string = "application/json"
+	HealthCheckMessage                string = "NOTIFICATION SERVICE IS HEALTH"
+	InvalidIDErrorMessage             string = "Invalid user ID"
+	InvalidNotificationIDErrorMessage string = "Invalid notification ID"
+	UnauthorizedErrorMessage          string = "Unauthorized"
+	ForbiddenErrorMessage             string = "Forbidden"
+	LastEventIdHeader                 string = "Last-Event-ID"
+	JwtPayloadHeader                  string = "x-jwt-payload"
+	HostRole                          string = "host"
+	UserIDParam                       string = "/{userId}"
+	NotificationIDParam               string = "/{notificationId}"
+	ReservationRedirectUrlStart       string = "reservation/view/"
+	RoleGuest                         string = "guest"
+	RoleHost                          string = "host"
+	RoleAdmin                         string = "admin"
+	RoleSupport                       string = "support"
+	SinceQueryParam                   string = "since"
+	InvalidCursorErrorMessage         string = "Invalid since cursor, expected notification id or RFC3339 timestamp"
+	MaxReplayedNotifications          int64  = 100
+	RealtimeFanoutTopic               string = "notification.realtime"
+	KafkaFanoutBackend                string = "kafka"
+	EventsQueryParam                  string = "events"
+	NotificationNotFoundErrorMessage  string = "Notification not found"
+	NextCursorHeader                  string = "X-Next-Cursor"
+	DefaultPageSize                   int64  = 20
+
+	UnsupportedLocaleErrorMessage        string = "Unsupported locale"
+	AcceptLanguageHeader                 string = "Accept-Language"
+	SettingsNotFoundErrorMessage         string = "Notification settings not found"
+	PushSubscriptionNotFoundErrorMessage string = "Push subscription not found"
+	PushDisabledErrorMessage             string = "Web Push is not configured"
+	DeviceNotFoundErrorMessage           string = "Device not found"
+	WebhookNotFoundErrorMessage          string = "Webhook not found"
+	InvalidWebhookIDErrorMessage         string = "Invalid webhook ID"
+	WebhookIDParam                       string = "/{webhookId}"
+	UnsafeWebhookUrlErrorMessage         string = "Webhook url has to be a public https url"
 )
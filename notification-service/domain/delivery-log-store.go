@@ -0,0 +1,13 @@
+package domain
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type DeliveryLogStore interface {
+	Insert(records []*DeliveryRecord) error
+	Complete(id primitive.ObjectID, status DeliveryStatus, errorMessage string, completedAt time.Time) error
+	GetByNotificationId(userId string, notificationId primitive.ObjectID) ([]*DeliveryRecord, error)
+	GetPageByUserId(userId string, query DeliveryLogQuery) ([]*DeliveryRecord, error)
+}
@@ -0,0 +1,8 @@
+package domain
+
+type DeviceStore interface {
+	GetActiveByUserId(userId string) ([]*Device, error)
+	Upsert(device *Device) error
+	Delete(userId, token string) (int64, error)
+	Deactivate(token string) error
+}
@@ -0,0 +1,12 @@
+package domain
+
+import (
+	"time"
+)
+
+type DigestEventStore interface {
+	Insert(event *DigestEvent) error
+	GetByDigestKey(key string) ([]*DigestEvent, error)
+	// DeleteByDigestKey deletes the events of the digest collected until the time.
+	DeleteByDigestKey(key string, until time.Time) (int64, error)
+}
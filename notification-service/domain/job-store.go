@@ -0,0 +1,21 @@
+package domain
+
+import (
+	"time"
+)
+
+type JobStore interface {
+	// Schedule inserts the job unless a job with the same key exists, reporting whether it did.
+	Schedule(job *Job) (bool, error)
+	// Claim leases the earliest due job to the owner, returning nil when no job is due.
+	Claim(owner string, now time.Time, lease time.Duration) (*Job, error)
+	// ExtendLease moves the end of the lease of a job claimed by the owner to the given time.
+	ExtendLease(job *Job, owner string, until time.Time) error
+	// Reschedule stores the run time, step, attempts and error of a job claimed by the owner and
+	// releases its lease.
+	Reschedule(job *Job, owner string) error
+	// Complete removes a job claimed by the owner.
+	Complete(job *Job, owner string) error
+	CancelByKeys(keys []string) (int64, error)
+	CancelByUserId(userId string, kind JobKind) (int64, error)
+}
@@ -0,0 +1,7 @@
+package domain
+
+type MessageRenderer interface {
+	MessageKey(notificationType NotificationType, payload NotificationPayload) string
+	Render(locale, key string, payload NotificationPayload) (string, error)
+	Supports(locale string) bool
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
 	"time"
 )
 
@@ -13,25 +14,495 @@ const (
 	NewHostReview
 	NewAccommodationReview
 	ReviewReservation
+	// Digest summarizes the events of another type collected over a digest period.
+	Digest
 )
 
+var notificationTypeNames = map[NotificationType]string{
+	NewReservationRequest:  "new-reservation-request",
+	CancelReservation:      "cancel-reservation",
+	NewHostReview:          "new-host-review",
+	NewAccommodationReview: "new-accommodation-review",
+	ReviewReservation:      "review-reservation",
+	Digest:                 "digest",
+}
+
+// NotificationTypes returns every notification type the service creates.
+func NotificationTypes() []NotificationType {
+	return []NotificationType{NewReservationRequest, CancelReservation, NewHostReview, NewAccommodationReview, ReviewReservation, Digest}
+}
+
+// ParseNotificationType returns the notification type with the given name.
+func ParseNotificationType(name string) (NotificationType, bool) {
+	for notificationType, typeName := range notificationTypeNames {
+		if typeName == name {
+			return notificationType, true
+		}
+	}
+	return 0, false
+}
+
+func (notificationType NotificationType) String() string {
+	if name, ok := notificationTypeNames[notificationType]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+type Channel string
+
+const (
+	BellChannel    Channel = "bell"
+	EmailChannel   Channel = "email"
+	PushChannel    Channel = "push"
+	WebhookChannel Channel = "webhook"
+	SmsChannel     Channel = "sms"
+)
+
+// Channels returns every channel notifications can be delivered over.
+func Channels() []Channel {
+	return []Channel{BellChannel, EmailChannel, PushChannel, WebhookChannel, SmsChannel}
+}
+
+// Interruptive reports whether delivering over the channel may disturb the user, which is held
+// back during quiet hours.
+func (channel Channel) Interruptive() bool {
+	return channel == EmailChannel || channel == PushChannel || channel == SmsChannel
+}
+
+// NotificationSetting turns a notification type on or off and picks the channels its events are
+// delivered over. With a Digest frequency the events are collected and summarized once per period
+// instead of being delivered one by one. Escalate opts in to having unseen bell notifications of
+// the type delivered over the further channels of the escalation policy.
 type NotificationSetting struct {
-	Type   NotificationType `bson:"type"`
-	Active bool             `bson:"active"`
+	Type     NotificationType `bson:"type"`
+	Active   bool             `bson:"active"`
+	Channels map[Channel]bool `bson:"channels,omitempty"`
+	Digest   DigestFrequency  `bson:"digest,omitempty"`
+	Escalate bool             `bson:"escalate,omitempty"`
+}
+
+// NotificationSettingUpdate changes the setting of a notification type. Fields left nil keep
+// their stored values, so clients that only change some of them do not reset the others.
+type NotificationSettingUpdate struct {
+	Type     NotificationType
+	Active   bool
+	Channels map[Channel]bool
+	Digest   *DigestFrequency
+	Escalate *bool
+}
+
+// Apply returns the setting the update leaves, given the stored one of the same type if any.
+func (update NotificationSettingUpdate) Apply(stored NotificationSetting) NotificationSetting {
+	setting := NotificationSetting{
+		Type:     update.Type,
+		Active:   update.Active,
+		Channels: stored.Channels,
+		Digest:   stored.Digest,
+		Escalate: stored.Escalate,
+	}
+	if update.Channels != nil {
+		setting.Channels = update.Channels
+	}
+	if update.Digest != nil {
+		setting.Digest = *update.Digest
+	}
+	if update.Escalate != nil {
+		setting.Escalate = *update.Escalate
+	}
+	return setting
+}
+
+// DeliversTo reports whether events of the setting's type go out over the channel. Settings
+// stored before channels were introduced only cover the bell.
+func (setting NotificationSetting) DeliversTo(channel Channel) bool {
+	if !setting.Active {
+		return false
+	}
+	if setting.Channels == nil {
+		return channel == BellChannel
+	}
+	return setting.Channels[channel]
 }
 
 type Settings struct {
 	Id       primitive.ObjectID    `bson:"_id"`
 	UserId   string                `bson:"user_id"`
 	Settings []NotificationSetting `bson:"settings"`
+	Locale   string                `bson:"locale,omitempty"`
+	Email    string                `bson:"email,omitempty"`
+	// Phone is the E.164 number SMS are sent to, only once the user has opted in to them.
+	Phone      string    `bson:"phone,omitempty"`
+	SmsOptIn   bool      `bson:"sms_opt_in,omitempty"`
+	SmsOptInAt time.Time `bson:"sms_opt_in_at,omitempty"`
+	// TimeZone is the IANA time zone the quiet hours are kept in, UTC when empty.
+	TimeZone   string       `bson:"time_zone,omitempty"`
+	QuietHours []QuietHours `bson:"quiet_hours,omitempty"`
+}
+
+// Recipient returns the contacts and the locale notifications are delivered to the user with.
+func (settings Settings) Recipient() Recipient {
+	return Recipient{
+		UserId:   settings.UserId,
+		Email:    settings.Email,
+		Phone:    settings.Phone,
+		SmsOptIn: settings.SmsOptIn,
+		Locale:   settings.Locale,
+	}
+}
+
+// ChannelsFor returns the channels an event of the type is delivered over, which is empty when
+// the user has turned the type off.
+func (settings Settings) ChannelsFor(notificationType NotificationType) []Channel {
+	var channels []Channel
+	for _, setting := range settings.Settings {
+		if setting.Type != notificationType {
+			continue
+		}
+		for _, channel := range Channels() {
+			if setting.DeliversTo(channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+	return channels
+}
+
+// DigestFor returns how often the user receives a digest of the type's events, which is empty
+// when the events are delivered one by one.
+func (settings Settings) DigestFor(notificationType NotificationType) DigestFrequency {
+	for _, setting := range settings.Settings {
+		if setting.Type == notificationType && setting.Active {
+			return setting.Digest
+		}
+	}
+	return ""
+}
+
+// Location returns the user's time zone, UTC when it is not set or unknown.
+func (settings Settings) Location() *time.Location {
+	location, err := time.LoadLocation(settings.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// QuietUntil reports whether the time falls into the user's quiet hours and, if so, when they
+// end. Windows which overlap or follow each other end with the last of them.
+func (settings Settings) QuietUntil(now time.Time) (time.Time, bool) {
+	location := settings.Location()
+	until := now
+	for range len(settings.QuietHours) {
+		extended := false
+		for _, window := range settings.QuietHours {
+			if end, ok := window.endOfWindowAt(until, location); ok {
+				until, extended = end, true
+			}
+		}
+		if !extended {
+			break
+		}
+	}
+	return until, until.After(now)
+}
+
+type DigestFrequency string
+
+const (
+	HourlyDigest DigestFrequency = "hourly"
+	DailyDigest  DigestFrequency = "daily"
+	WeeklyDigest DigestFrequency = "weekly"
+)
+
+// NextDigestAt returns when the digest collecting events at the given time is delivered, in the
+// location: at the start of the next hour, at the hour of the next day or of the next Monday. An
+// empty frequency returns the zero time.
+func (frequency DigestFrequency) NextDigestAt(now time.Time, location *time.Location, hour int) time.Time {
+	local := now.In(location)
+	year, month, day := local.Date()
+	var next time.Time
+	switch frequency {
+	case HourlyDigest:
+		return time.Date(year, month, day, local.Hour()+1, 0, 0, 0, location)
+	case DailyDigest:
+		next = time.Date(year, month, day, hour, 0, 0, 0, location)
+		if !next.After(now) {
+			next = time.Date(year, month, day+1, hour, 0, 0, 0, location)
+		}
+	case WeeklyDigest:
+		days := (int(time.Monday) - int(local.Weekday()) + 7) % 7
+		next = time.Date(year, month, day+days, hour, 0, 0, 0, location)
+		if !next.After(now) {
+			next = time.Date(year, month, day+days+7, hour, 0, 0, 0, location)
+		}
+	}
+	return next
+}
+
+const QuietHoursLayout = "15:04"
+
+// QuietHours is a daily window, from Start to End in the QuietHoursLayout in the user's time
+// zone, during which interruptive channels are held back. A window ending before it starts
+// spans midnight.
+type QuietHours struct {
+	Start string `bson:"start"`
+	End   string `bson:"end"`
+}
+
+// endOfWindowAt returns the end of the window the time falls into. The window is laid out on the
+// calendar days of the location, so across DST transitions it lasts an hour more or less.
+func (window QuietHours) endOfWindowAt(moment time.Time, location *time.Location) (time.Time, bool) {
+	start, err := time.Parse(QuietHoursLayout, window.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := time.Parse(QuietHoursLayout, window.End)
+	if err != nil || start.Equal(end) {
+		return time.Time{}, false
+	}
+
+	year, month, day := moment.In(location).Date()
+	for _, startDay := range []int{day - 1, day} {
+		from := time.Date(year, month, startDay, start.Hour(), start.Minute(), 0, 0, location)
+		endDay := startDay
+		if end.Before(start) {
+			endDay++
+		}
+		to := time.Date(year, month, endDay, end.Hour(), end.Minute(), 0, 0, location)
+		if !moment.Before(from) && moment.Before(to) {
+			return to, true
+		}
+	}
+	return time.Time{}, false
 }
 
+// NotificationPayload holds the fields of the event a notification was created from. The payload
+// of a digest holds the name of the summarized type as its Status and the number of its events.
+type NotificationPayload struct {
+	ReservationId   string `bson:"reservation_id,omitempty"`
+	AccommodationId string `bson:"accommodation_id,omitempty"`
+	ActorId         string `bson:"actor_id,omitempty"`
+	ActorName       string `bson:"actor_name,omitempty"`
+	Status          string `bson:"status,omitempty"`
+	Count           int    `bson:"count,omitempty"`
+}
+
+// BellNotification is rendered when it is read, from the template named by MessageKey with the
+// payload as its parameters. Notifications created before message keys hold the rendered Message.
 type BellNotification struct {
+	Id             primitive.ObjectID  `bson:"_id"`
+	UserId         string              `bson:"user_id"`
+	Type           NotificationType    `bson:"type"`
+	Payload        NotificationPayload `bson:"payload"`
+	MessageKey     string              `bson:"message_key,omitempty"`
+	Message        string              `bson:"message,omitempty"`
+	TimeStamp      time.Time           `bson:"time_stamp"`
+	Seen           bool                `bson:"seen"`
+	SeenAt         time.Time           `bson:"seen_at,omitempty"`
+	ShouldRedirect bool                `bson:"should_redirect"`
+	RedirectId     string              `bson:"redirect_id,omitempty"`
+	ArchivedAt     time.Time           `bson:"archived_at,omitempty"`
+	DeletedAt      time.Time           `bson:"deleted_at,omitempty"`
+}
+
+// ReplayCursor identifies the last notification a client has received, either by its id or
+// by its timestamp, so that everything created after it can be replayed on reconnect.
+type ReplayCursor struct {
+	AfterId   primitive.ObjectID
+	AfterTime time.Time
+}
+
+// BellNotificationQuery selects a page of notifications of a user, newest first. Before is the
+// id of the last notification of the previous page; zero values disable the other filters, and
+// a zero Limit lists every notification on a single page.
+// Archived switches between the archived view and the regular one, deleted ones are never listed.
+type BellNotificationQuery struct {
+	Limit    int64
+	Before   primitive.ObjectID
+	Archived bool
+	Seen     *bool
+	Types    []NotificationType
+	From     time.Time
+	To       time.Time
+}
+
+// RetentionRule keeps notifications for the given durations after their creation, depending on
+// whether they have been seen. A zero duration keeps them forever.
+type RetentionRule struct {
+	Seen   time.Duration
+	Unseen time.Duration
+}
+
+// RetentionPolicy applies the rule of a notification type when it has one and the default rule
+// to all other notifications. In dry run mode expired notifications are only counted.
+type RetentionPolicy struct {
+	Default RetentionRule
+	ByType  map[NotificationType]RetentionRule
+	DryRun  bool
+}
+
+// RetentionFilter selects notifications created before the given time, either of the given
+// types or, when ExcludeTypes is set, of any other type.
+type RetentionFilter struct {
+	Types         []NotificationType
+	ExcludeTypes  bool
+	Seen          bool
+	CreatedBefore time.Time
+}
+
+// PushSubscription is a browser's Web Push subscription, identified by its endpoint at the
+// browser's push service. P256dh and Auth are the keys payloads are encrypted for.
+type PushSubscription struct {
+	Id        primitive.ObjectID `bson:"_id"`
+	UserId    string             `bson:"user_id"`
+	Endpoint  string             `bson:"endpoint"`
+	P256dh    string             `bson:"p256dh"`
+	Auth      string             `bson:"auth"`
+	UserAgent string             `bson:"user_agent,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// Device is a mobile app installation registered for push notifications.
+type Device struct {
+	Id         primitive.ObjectID `bson:"_id"`
+	UserId     string             `bson:"user_id"`
+	Platform   string             `bson:"platform"`
+	Token      string             `bson:"token"`
+	AppVersion string             `bson:"app_version,omitempty"`
+	LastSeen   time.Time          `bson:"last_seen"`
+	Active     bool               `bson:"active"`
+}
+
+// Webhook is an endpoint that receives the user's notifications as signed JSON POSTs. An empty
+// EventTypes receives every type. Webhooks are disabled after too many consecutive failed
+// deliveries and have to be enabled again by their owner.
+type Webhook struct {
+	Id                  primitive.ObjectID `bson:"_id"`
+	UserId              string             `bson:"user_id"`
+	Url                 string             `bson:"url"`
+	Secret              string             `bson:"secret"`
+	EventTypes          []NotificationType `bson:"event_types,omitempty"`
+	Active              bool               `bson:"active"`
+	ConsecutiveFailures int                `bson:"consecutive_failures"`
+	CreatedAt           time.Time          `bson:"created_at"`
+	DisabledAt          time.Time          `bson:"disabled_at,omitempty"`
+}
+
+// Receives reports whether events of the type are delivered to the webhook.
+func (webhook Webhook) Receives(notificationType NotificationType) bool {
+	return len(webhook.EventTypes) == 0 || slices.Contains(webhook.EventTypes, notificationType)
+}
+
+// WebhookAttempt records a single POST of an event to a webhook. StatusCode is zero when no
+// response was received.
+type WebhookAttempt struct {
+	Id          primitive.ObjectID `bson:"_id"`
+	WebhookId   primitive.ObjectID `bson:"webhook_id"`
+	EventId     string             `bson:"event_id"`
+	Type        NotificationType   `bson:"type"`
+	Attempt     int                `bson:"attempt"`
+	StatusCode  int                `bson:"status_code,omitempty"`
+	Error       string             `bson:"error,omitempty"`
+	Success     bool               `bson:"success"`
+	Duration    time.Duration      `bson:"duration"`
+	AttemptedAt time.Time          `bson:"attempted_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	PendingDelivery DeliveryStatus = "pending"
+	SentDelivery    DeliveryStatus = "sent"
+	FailedDelivery  DeliveryStatus = "failed"
+	BouncedDelivery DeliveryStatus = "bounced"
+	// SkippedDelivery is a delivery which was not attempted, because the channel is not configured
+	// or the user has no contact or consent for it.
+	SkippedDelivery DeliveryStatus = "skipped"
+)
+
+// DeliveryRecord tracks the delivery of a notification over one channel, from the moment it is
+// handed to the channel until the channel reports the outcome. A bell delivery is sent once the
+// notification is stored in the user's inbox. Whether an open connection showed it is not
+// tracked, since the connections of the user may be served by any replica.
+type DeliveryRecord struct {
 	Id             primitive.ObjectID `bson:"_id"`
+	NotificationId primitive.ObjectID `bson:"notification_id"`
 	UserId         string             `bson:"user_id"`
-	Message        string             `bson:"message"`
-	TimeStamp      time.Time          `bson:"time_stamp"`
-	Seen           bool               `bson:"seen"`
-	ShouldRedirect bool               `bson:"should_redirect"`
-	RedirectId     string             `bson:"redirect_id,omitempty"`
+	Type           NotificationType   `bson:"type"`
+	Channel        Channel            `bson:"channel"`
+	Status         DeliveryStatus     `bson:"status"`
+	Error          string             `bson:"error,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at"`
+}
+
+// DeliveryLogQuery selects a page of a user's delivery records, newest first. Before is the id
+// of the last record of the previous page; zero values disable the other filters.
+type DeliveryLogQuery struct {
+	Limit   int64
+	Before  primitive.ObjectID
+	Channel Channel
+	Status  DeliveryStatus
+}
+
+// EscalationStep delivers a bell notification over Channel once it has stayed unseen for After
+// since its creation.
+type EscalationStep struct {
+	After   time.Duration
+	Channel Channel
+}
+
+// EscalationPolicy lists, per notification type, the steps taken in order while a bell
+// notification stays unseen. Types without steps are never escalated.
+type EscalationPolicy map[NotificationType][]EscalationStep
+
+type JobKind string
+
+const (
+	EscalationJob       JobKind = "escalation"
+	DeferredDeliveryJob JobKind = "deferred-delivery"
+	DigestJob           JobKind = "digest"
+	WebhookRetryJob     JobKind = "webhook-retry"
+	PurgeJob            JobKind = "purge"
+)
+
+// Job is a unit of work run at RunAt by whichever replica claims it first. Key identifies the job,
+// so scheduling the same work twice keeps a single job. A claimed job is leased to its owner until
+// LockedUntil, after which another replica may claim it again.
+type Job struct {
+	Id             primitive.ObjectID `bson:"_id"`
+	Key            string             `bson:"key"`
+	Kind           JobKind            `bson:"kind"`
+	UserId         string             `bson:"user_id"`
+	NotificationId primitive.ObjectID `bson:"notification_id,omitempty"`
+	Step           int                `bson:"step"`
+	// Type, Payload, RedirectId and Channels describe the notification a deferred delivery
+	// delivers. Type is the summarized type of a digest.
+	Type       NotificationType    `bson:"type,omitempty"`
+	Payload    NotificationPayload `bson:"payload,omitempty"`
+	RedirectId string              `bson:"redirect_id,omitempty"`
+	Channels   []Channel           `bson:"channels,omitempty"`
+	// WebhookId, EventId and Body describe the event a webhook retry posts again.
+	WebhookId   primitive.ObjectID `bson:"webhook_id,omitempty"`
+	EventId     string             `bson:"event_id,omitempty"`
+	Body        []byte             `bson:"body,omitempty"`
+	RunAt       time.Time          `bson:"run_at"`
+	Attempts    int                `bson:"attempts"`
+	LastError   string             `bson:"last_error,omitempty"`
+	LockedBy    string             `bson:"locked_by,omitempty"`
+	LockedUntil time.Time          `bson:"locked_until,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at"`
+}
+
+// DigestEvent is an event collected for the digest identified by DigestKey, which summarizes the
+// events of one type a user receives during one digest period.
+type DigestEvent struct {
+	Id         primitive.ObjectID  `bson:"_id"`
+	DigestKey  string              `bson:"digest_key"`
+	UserId     string              `bson:"user_id"`
+	Type       NotificationType    `bson:"type"`
+	Payload    NotificationPayload `bson:"payload"`
+	RedirectId string              `bson:"redirect_id,omitempty"`
+	CreatedAt  time.Time           `bson:"created_at"`
 }
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+)
+
+var (
+	ErrMissingContact = errors.New("user has no contact for the channel")
+	ErrNoConsent      = errors.New("user has not opted in to the channel")
+	// ErrBounced is wrapped by the errors of deliveries the recipient's address permanently
+	// rejected, such as unknown mailboxes and numbers or uninstalled apps.
+	ErrBounced = errors.New("recipient rejected the notification")
+	// ErrRetryScheduled is wrapped by the errors of failed deliveries which are retried later.
+	ErrRetryScheduled = errors.New("delivery failed, retry scheduled")
+)
+
+// Recipient is the user a notification is delivered to, with the contacts and preferences the
+// channels need.
+type Recipient struct {
+	UserId   string
+	Email    string
+	Phone    string
+	SmsOptIn bool
+	Locale   string
+}
+
+// Delivery is a notification on its way to a recipient over a channel other than the bell.
+// Message is already rendered in the recipient's locale.
+type Delivery struct {
+	NotificationId string
+	Recipient      Recipient
+	Type           NotificationType
+	Payload        NotificationPayload
+	Message        string
+	RedirectId     string
+}
+
+type Notifier interface {
+	Channel() Channel
+	Notify(delivery Delivery) error
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"fmt"
+)
+
+const (
+	AndroidPlatform string = "android"
+	IosPlatform     string = "ios"
+)
+
+// ErrInvalidDeviceToken is returned by push providers for tokens that will never be delivered
+// to again, such as those of uninstalled apps.
+var ErrInvalidDeviceToken = fmt.Errorf("%w: invalid device token", ErrBounced)
+
+type PushMessage struct {
+	Body string
+	Data map[string]string
+}
+
+// PushProvider sends push messages to the devices of one platform.
+type PushProvider interface {
+	Platform() string
+	Send(token string, message PushMessage) error
+}
@@ -0,0 +1,8 @@
+package domain
+
+type PushSubscriptionStore interface {
+	GetByUserId(userId string) ([]*PushSubscription, error)
+	Upsert(subscription *PushSubscription) error
+	Delete(userId, endpoint string) (int64, error)
+	DeleteByEndpoint(endpoint string) error
+}
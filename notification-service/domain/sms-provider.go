@@ -0,0 +1,10 @@
+package domain
+
+import "fmt"
+
+var ErrInvalidPhoneNumber = fmt.Errorf("%w: phone number can not receive SMS", ErrBounced)
+
+// SmsProvider hands a text message over to a carrier gateway, which splits it into segments.
+type SmsProvider interface {
+	Send(to string, body string) error
+}
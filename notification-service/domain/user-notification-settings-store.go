@@ -10,4 +10,8 @@ type UserNotificationSettingsStore interface {
 	DeleteByUserId(id string) error
 	DeleteAll()
 	Update(id primitive.ObjectID, settings *Settings) error
+	UpdateLocale(id primitive.ObjectID, locale string) error
+	UpdateEmail(id primitive.ObjectID, email string) error
+	UpdatePhone(id primitive.ObjectID, settings *Settings) error
+	UpdateQuietHours(id primitive.ObjectID, settings *Settings) error
 }
@@ -0,0 +1,18 @@
+package domain
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type WebhookStore interface {
+	Get(userId string, id primitive.ObjectID) (*Webhook, error)
+	GetByUserId(userId string) ([]*Webhook, error)
+	GetActiveByUserId(userId string) ([]*Webhook, error)
+	Insert(webhook *Webhook) error
+	Delete(userId string, id primitive.ObjectID) (int64, error)
+	Enable(userId string, id primitive.ObjectID) (int64, error)
+	RecordSuccess(id primitive.ObjectID) error
+	// RecordFailure counts a failed delivery and disables the webhook once it has failed
+	// disableAfter times in a row, reporting whether it did.
+	RecordFailure(id primitive.ObjectID, disableAfter int) (bool, error)
+	InsertAttempt(attempt *WebhookAttempt) error
+	GetAttempts(webhookId primitive.ObjectID, limit int64) ([]*WebhookAttempt, error)
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"golang.org/x/text/language"
+)
+
+// acceptedLanguages returns the languages of an Accept-Language header ordered by preference.
+func acceptedLanguages(header string) []string {
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return nil
+	}
+	languages := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		languages = append(languages, tag.String())
+	}
+	return languages
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/gorilla/mux"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/dto"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/request"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"net/http"
+)
+
+// DeliveryLogHandler serves the delivery log to support, which looks up how a user's
+// notifications went out over each channel. Only callers with the support role are served.
+type DeliveryLogHandler struct {
+	deliveryLogService *application.DeliveryLogService
+	traceProvider      *sdktrace.TracerProvider
+	loki               promtail.Client
+}
+
+func NewDeliveryLogHandler(deliveryLogService *application.DeliveryLogService, traceProvider *sdktrace.TracerProvider, loki promtail.Client) *DeliveryLogHandler {
+	return &DeliveryLogHandler{
+		deliveryLogService: deliveryLogService,
+		traceProvider:      traceProvider,
+		loki:               loki,
+	}
+}
+
+func (handler *DeliveryLogHandler) Init(router *mux.Router) {
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/deliveries", handler.GetByUserId).Methods(http.MethodGet)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/deliveries"+domain.NotificationIDParam, handler.GetByNotificationId).Methods(http.MethodGet)
+}
+
+func (handler *DeliveryLogHandler) GetByUserId(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-deliveries-get")
+	defer func() { span.End() }()
+	if !authorizeRole(w, r, domain.RoleSupport) {
+		return
+	}
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "GetByUserId", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+
+	queryRequest, err := request.NewDeliveryLogQueryRequest(r.URL.Query())
+	if err != nil {
+		util.HttpTraceError(err, "invalid query parameters", span, handler.loki, "GetByUserId", "")
+		handleError(w, http.StatusBadRequest, "Invalid delivery query parameters")
+		return
+	}
+	if err := queryRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid query parameters", span, handler.loki, "GetByUserId", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	records, nextCursor, err := handler.deliveryLogService.GetByUserId(id, queryRequest.ToQuery(), span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get deliveries", span, handler.loki, "GetByUserId", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Deliveries fetched successfully", span, handler.loki, "GetByUserId", "")
+	if nextCursor != "" {
+		w.Header().Set(domain.NextCursorHeader, nextCursor)
+	}
+
+	writeResponse(w, http.StatusOK, dto.FromDeliveryRecords(records))
+}
+
+func (handler *DeliveryLogHandler) GetByNotificationId(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-notification-deliveries-get")
+	defer func() { span.End() }()
+	if !authorizeRole(w, r, domain.RoleSupport) {
+		return
+	}
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "GetByNotificationId", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	notificationId, err := primitive.ObjectIDFromHex(mux.Vars(r)["notificationId"])
+	if err != nil {
+		util.HttpTraceError(err, "invalid notification id", span, handler.loki, "GetByNotificationId", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidNotificationIDErrorMessage)
+		return
+	}
+
+	records, err := handler.deliveryLogService.GetByNotificationId(id, notificationId, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get deliveries", span, handler.loki, "GetByNotificationId", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(records) == 0 {
+		handleError(w, http.StatusNotFound, domain.NotificationNotFoundErrorMessage)
+		return
+	}
+	util.HttpTraceInfo("Deliveries fetched successfully", span, handler.loki, "GetByNotificationId", "")
+
+	writeResponse(w, http.StatusOK, dto.FromDeliveryRecords(records))
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/gorilla/mux"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/request"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/mongo"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"net/http"
+)
+
+type DeviceHandler struct {
+	deviceService *application.DeviceService
+	traceProvider *sdktrace.TracerProvider
+	loki          promtail.Client
+}
+
+func NewDeviceHandler(deviceService *application.DeviceService, traceProvider *sdktrace.TracerProvider, loki promtail.Client) *DeviceHandler {
+	return &DeviceHandler{
+		deviceService: deviceService,
+		traceProvider: traceProvider,
+		loki:          loki,
+	}
+}
+
+func (handler *DeviceHandler) Init(router *mux.Router) {
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/devices", handler.Register).Methods(http.MethodPost)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/devices", handler.Unregister).Methods(http.MethodDelete)
+}
+
+func (handler *DeviceHandler) Register(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "register-device-post")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "Register", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	var deviceRequest request.DeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&deviceRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "Register", "")
+		handleError(w, http.StatusBadRequest, "Invalid device payload")
+		return
+	}
+
+	if err := deviceRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "Register", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := handler.deviceService.Register(deviceRequest.ToDevice(id), span, handler.loki); err != nil {
+		util.HttpTraceError(err, "failed to register device", span, handler.loki, "Register", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Device registered successfully", span, handler.loki, "Register", "")
+
+	writeResponse(w, http.StatusCreated, nil)
+}
+
+func (handler *DeviceHandler) Unregister(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "unregister-device-delete")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "Unregister", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	var tokenRequest request.DeviceTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&tokenRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "Unregister", "")
+		handleError(w, http.StatusBadRequest, "Invalid device payload")
+		return
+	}
+
+	if err := tokenRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "Unregister", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := handler.deviceService.Unregister(id, tokenRequest.Token, span, handler.loki); err != nil {
+		util.HttpTraceError(err, "failed to unregister device", span, handler.loki, "Unregister", "")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			handleError(w, http.StatusNotFound, domain.DeviceNotFoundErrorMessage)
+			return
+		}
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Device unregistered successfully", span, handler.loki, "Unregister", "")
+
+	writeResponse(w, http.StatusOK, nil)
+}
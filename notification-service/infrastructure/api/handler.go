@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/realtime"
 	"log"
 	"net/http"
 )
@@ -21,3 +22,11 @@ func writeResponse(w http.ResponseWriter, httpStatus int, data interface{}) {
 		handleError(w, http.StatusInternalServerError, err.Error())
 	}
 }
+
+func publishEvent(fanout realtime.Fanout, userId, eventType, id string, payload interface{}) error {
+	message, err := realtime.NewMessage(eventType, id, payload)
+	if err != nil {
+		return err
+	}
+	return fanout.Publish(userId, message)
+}
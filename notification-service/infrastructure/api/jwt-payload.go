@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type jwtPayload struct {
+	Subject     string `json:"sub"`
+	RealmAccess struct {
+		Roles []string `json:"roles"`
+	} `json:"realm_access"`
+}
+
+func (payload jwtPayload) hasRole(role string) bool {
+	return slices.Contains(payload.RealmAccess.Roles, role)
+}
+
+// userIdFromJwtPayload reads the identity of the caller from the JWT payload header
+// which Istio forwards after the token has been validated by the RequestAuthentication policy.
+func userIdFromJwtPayload(r *http.Request) (string, error) {
+	payload, err := jwtPayloadFrom(r)
+	if err != nil {
+		return "", err
+	}
+	return payload.Subject, nil
+}
+
+func jwtPayloadFrom(r *http.Request) (jwtPayload, error) {
+	header := r.Header.Get(domain.JwtPayloadHeader)
+	if header == "" {
+		return jwtPayload{}, errors.New("missing jwt payload header")
+	}
+
+	decoded, err := decodeSegment(header)
+	if err != nil {
+		return jwtPayload{}, err
+	}
+
+	var payload jwtPayload
+	if err := json.Unmarshal(decoded, &payload); err != nil {
+		return jwtPayload{}, err
+	}
+	if payload.Subject == "" {
+		return jwtPayload{}, errors.New("jwt payload does not contain subject")
+	}
+
+	return payload, nil
+}
+
+// authorizeUser checks that the caller is the user the request acts for or holds one of the
+// roles, which may act for any user. Otherwise it responds with the error and returns false.
+func authorizeUser(w http.ResponseWriter, r *http.Request, userId string, roles ...string) bool {
+	return authorize(w, r, func(payload jwtPayload) bool {
+		return payload.Subject == userId || slices.ContainsFunc(roles, payload.hasRole)
+	})
+}
+
+// authorizeRole checks that the caller holds one of the roles. Otherwise it responds with the
+// error and returns false.
+func authorizeRole(w http.ResponseWriter, r *http.Request, roles ...string) bool {
+	return authorize(w, r, func(payload jwtPayload) bool {
+		return slices.ContainsFunc(roles, payload.hasRole)
+	})
+}
+
+func authorize(w http.ResponseWriter, r *http.Request, allowed func(payload jwtPayload) bool) bool {
+	payload, err := jwtPayloadFrom(r)
+	if err != nil {
+		handleError(w, http.StatusUnauthorized, domain.UnauthorizedErrorMessage)
+		return false
+	}
+	if allowed(payload) {
+		return true
+	}
+	handleError(w, http.StatusForbidden, domain.ForbiddenErrorMessage)
+	return false
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	segment = strings.TrimRight(segment, "=")
+	if decoded, err := base64.RawURLEncoding.DecodeString(segment); err == nil {
+		return decoded, nil
+	}
+	return base64.RawStdEncoding.DecodeString(segment)
+}
@@ -11,31 +11,55 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/mmmajder/zms-devops-notification-service/application"
 	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/dto"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/realtime"
 	"github.com/mmmajder/zms-devops-notification-service/infrastructure/request"
 	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"log"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 type NotificationHandler struct {
 	notificationService *application.BellNotificationService
 	settingsService     *application.NotificationSettingsService
+	dispatcher          *application.NotificationDispatcher
+	deliveryLog         *application.DeliveryLogService
+	escalations         *application.EscalationService
+	deferredDeliveries  *application.DeferredDeliveryService
+	digests             *application.DigestService
 	upgrades            websocket.Upgrader
-	connections         []*websocket.Conn
+	hub                 *realtime.Hub
+	fanout              realtime.Fanout
+	dispatches          chan struct{}
+	dispatching         sync.WaitGroup
 	traceProvider       *sdktrace.TracerProvider
 	loki                promtail.Client
 }
 
-func NewNotificationHandler(bellService *application.BellNotificationService, settingsService *application.NotificationSettingsService, provider *sdktrace.TracerProvider, loki promtail.Client) *NotificationHandler {
+func NewNotificationHandler(bellService *application.BellNotificationService, settingsService *application.NotificationSettingsService, dispatcher *application.NotificationDispatcher, deliveryLog *application.DeliveryLogService, escalations *application.EscalationService, deferredDeliveries *application.DeferredDeliveryService, digests *application.DigestService, hub *realtime.Hub, fanout realtime.Fanout, dispatchWorkers int, provider *sdktrace.TracerProvider, loki promtail.Client) *NotificationHandler {
 	handler := NotificationHandler{
 		notificationService: bellService,
 		settingsService:     settingsService,
+		dispatcher:          dispatcher,
+		deliveryLog:         deliveryLog,
+		escalations:         escalations,
+		deferredDeliveries:  deferredDeliveries,
+		digests:             digests,
 		upgrades: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
 		},
-		connections:   []*websocket.Conn{},
+		hub:           hub,
+		fanout:        fanout,
+		dispatches:    make(chan struct{}, dispatchWorkers),
 		traceProvider: provider,
 		loki:          loki,
 	}
@@ -46,6 +70,19 @@ func NewNotificationHandler(bellService *application.BellNotificationService, se
 func (handler *NotificationHandler) Init(router *mux.Router) {
 	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam, handler.GetAllByUserId).Methods(http.MethodGet)
 	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+"/seen", handler.UpdateStatus).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+"/seen/bulk", handler.MarkSeenBulk).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+"/unseen/bulk", handler.MarkUnseenBulk).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+domain.NotificationIDParam+"/seen", handler.MarkSeen).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+domain.NotificationIDParam+"/unseen", handler.MarkUnseen).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+"/archived", handler.GetArchived).Methods(http.MethodGet)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+"/archive/bulk", handler.ArchiveBulk).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+"/delete/bulk", handler.DeleteBulk).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+"/restore/bulk", handler.RestoreBulk).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+domain.NotificationIDParam+"/archive", handler.Archive).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+domain.NotificationIDParam+"/restore", handler.Restore).Methods(http.MethodPut)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+domain.NotificationIDParam, handler.Delete).Methods(http.MethodDelete)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+"/stream", handler.Stream).Methods(http.MethodGet)
+	router.HandleFunc(domain.BellNotificationContextPath+domain.UserIDParam+"/unread-count", handler.GetUnreadCount).Methods(http.MethodGet)
 	router.HandleFunc("/ws", handler.WebSocketHandler)
 }
 
@@ -53,19 +90,16 @@ func (handler *NotificationHandler) OnNewReservationRequestCreated(message *kafk
 	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(context.TODO(), "on-reservation-request-created")
 	defer func() { span.End() }()
 	notificationRequest := handler.getNotificationRequest(message)
-	var textMessage = "You have a new reservation request."
-	if notificationRequest.Status == "automatic" {
-		textMessage = "Reservation request #" + notificationRequest.ReservationId + " is automatically accepted."
-	}
 
-	handler.onCreateNewNotification(notificationRequest, domain.ReservationRedirectUrlStart+notificationRequest.ReservationId, true, domain.NotificationType(0), textMessage)
+	handler.onCreateNewNotification(notificationRequest, domain.ReservationRedirectUrlStart+notificationRequest.ReservationId, true, domain.NewReservationRequest)
 }
 
 func (handler *NotificationHandler) OnReservationCancellation(message *kafka.Message) {
 	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(context.TODO(), "on-reservation-cancellation")
 	defer func() { span.End() }()
 	notificationRequest := handler.getNotificationRequest(message)
-	handler.onCreateNewNotification(notificationRequest, domain.ReservationRedirectUrlStart+notificationRequest.ReservationId, true, domain.NotificationType(1), "A reservation #"+notificationRequest.ReservationId+" has been cancelled.")
+
+	handler.onCreateNewNotification(notificationRequest, domain.ReservationRedirectUrlStart+notificationRequest.ReservationId, true, domain.CancelReservation)
 }
 
 func (handler *NotificationHandler) OnHostRated(message *kafka.Message) {
@@ -73,7 +107,7 @@ func (handler *NotificationHandler) OnHostRated(message *kafka.Message) {
 	defer func() { span.End() }()
 	notificationRequest := handler.getNotificationRequest(message)
 
-	handler.onCreateNewNotification(notificationRequest, "auth/view-profile/"+notificationRequest.ReceiverId, true, domain.NotificationType(2), notificationRequest.StartActionUserName+" has reviewed your profile. Check for more details.")
+	handler.onCreateNewNotification(notificationRequest, "auth/view-profile/"+notificationRequest.ReceiverId, true, domain.NewHostReview)
 }
 
 func (handler *NotificationHandler) OnAccommodationRated(message *kafka.Message) {
@@ -81,19 +115,15 @@ func (handler *NotificationHandler) OnAccommodationRated(message *kafka.Message)
 	defer func() { span.End() }()
 	notificationRequest := handler.getNotificationRequest(message)
 
-	handler.onCreateNewNotification(notificationRequest, "accommodation/"+notificationRequest.AccommodationId, true, domain.NotificationType(3), notificationRequest.StartActionUserName+" has reviewed your accommodation. Check for more details.")
+	handler.onCreateNewNotification(notificationRequest, "accommodation/"+notificationRequest.AccommodationId, true, domain.NewAccommodationReview)
 }
 
 func (handler *NotificationHandler) OnHostRespondedToReservationRequest(message *kafka.Message) {
 	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(context.TODO(), "on-host-responded-to-reservation-request")
 	defer func() { span.End() }()
 	notificationRequest := handler.getNotificationRequest(message)
-	var textMessage = "The host has been canceled your reservation #" + notificationRequest.ReservationId
-	if notificationRequest.Status == "accept-request" {
-		textMessage = "The host has been confirmed your reservation #" + notificationRequest.ReservationId
-	}
 
-	handler.onCreateNewNotification(notificationRequest, domain.ReservationRedirectUrlStart+notificationRequest.ReservationId, true, domain.NotificationType(4), textMessage)
+	handler.onCreateNewNotification(notificationRequest, domain.ReservationRedirectUrlStart+notificationRequest.ReservationId, true, domain.ReviewReservation)
 }
 
 func (handler *NotificationHandler) getNotificationRequest(message *kafka.Message) request.NotificationMessageRequest {
@@ -109,32 +139,360 @@ func (handler *NotificationHandler) getNotificationRequest(message *kafka.Messag
 	return notificationRequest
 }
 
-func (handler *NotificationHandler) onCreateNewNotification(notification request.NotificationMessageRequest, redirectId string, shouldRedirect bool, notificationType domain.NotificationType, message string) {
+func (handler *NotificationHandler) onCreateNewNotification(notification request.NotificationMessageRequest, redirectId string, shouldRedirect bool, notificationType domain.NotificationType) {
 	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(context.TODO(), "on-create-new-notification")
 	defer func() { span.End() }()
-	subscribed := handler.settingsService.UserIsSubscribedToNotificationType(notification.ReceiverId, notificationType, span, handler.loki)
-	if subscribed {
-		notificationDTO, err := handler.notificationService.Add(notification.ReceiverId, message, redirectId, shouldRedirect, span, handler.loki)
+	settings, err := handler.settingsService.Load(notification.ReceiverId, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get settings", span, handler.loki, "onCreateNewNotification", "")
+		return
+	}
+	channels := settings.ChannelsFor(notificationType)
+	if len(channels) == 0 {
+		return
+	}
+	recipient := settings.Recipient()
+	quietUntil, quiet := handler.settingsService.QuietUntilFor(settings, notificationType, time.Now(), span, handler.loki)
+
+	// Every channel's delivery is logged under this id, which the bell notification takes too.
+	notificationId := primitive.NewObjectID()
+	delivery := domain.Delivery{
+		NotificationId: notificationId.Hex(),
+		Recipient:      recipient,
+		Type:           notificationType,
+		Payload:        notification.ToPayload(),
+		RedirectId:     redirectId,
+	}
+	channels, err = handler.digests.Collect(delivery, channels, settings, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to collect notification for digest, delivering right away", span, handler.loki, "onCreateNewNotification", "")
+	}
+	// The toast is held back with the other interruptive channels only once they are deferred.
+	deferred := false
+	if quiet {
+		channels, err = handler.deferredDeliveries.Defer(delivery, channels, quietUntil, span, handler.loki)
 		if err != nil {
-			util.HttpTraceError(err, "failed to add notification", span, handler.loki, "onCreateNewNotification", "")
-			return
+			util.HttpTraceError(err, "failed to defer delivery, delivering right away", span, handler.loki, "onCreateNewNotification", "")
 		}
-		jsonMessage, _ := json.Marshal(notificationDTO)
-		handler.sendWebSocketMessage(jsonMessage)
+		deferred = err == nil
 	}
+	if slices.Contains(channels, domain.BellChannel) {
+		notificationDTO, err := handler.addBellNotification(notificationId, notification.ReceiverId, recipient.Locale, notificationType, delivery.Payload, redirectId, shouldRedirect, !deferred, span)
+		// The bell is recorded as sent once stored, not once shown by an open connection.
+		handler.deliveryLog.Record(delivery, domain.BellChannel, err, span, handler.loki)
+		delivery.Message = notificationDTO.Message
+		if !notificationDTO.Id.IsZero() {
+			if err := handler.escalations.Schedule(notificationId, notification.ReceiverId, notificationType, notificationDTO.TimeStamp, span, handler.loki); err != nil {
+				util.HttpTraceError(err, "failed to schedule escalation", span, handler.loki, "onCreateNewNotification", "")
+			}
+		}
+	}
+	handler.dispatchAsync(delivery, channels)
+}
+
+// addBellNotification stores the notification and, unless the toast is held back, pushes it to
+// the user's open connections, returning it rendered. The notification has no id when it could
+// not be stored, which is the only error returned: the bell is delivered once stored, even when
+// the open connections could not be reached.
+func (handler *NotificationHandler) addBellNotification(id primitive.ObjectID, userId, locale string, notificationType domain.NotificationType, payload domain.NotificationPayload, redirectId string, shouldRedirect, toast bool, span trace.Span) (dto.BellNotificationDTO, error) {
+	notificationDTO, err := handler.notificationService.Add(id, userId, locale, notificationType, payload, redirectId, shouldRedirect, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to add notification", span, handler.loki, "onCreateNewNotification", "")
+		return dto.BellNotificationDTO{}, err
+	}
+	if toast {
+		if err := publishEvent(handler.fanout, userId, realtime.NotificationCreatedEvent, notificationDTO.Id.Hex(), notificationDTO); err != nil {
+			util.HttpTraceError(err, "failed to publish notification", span, handler.loki, "onCreateNewNotification", "")
+		}
+	}
+	handler.publishUnreadCount(userId, span)
+	return notificationDTO, nil
+}
+
+// DeliverDeferred is the JobHandler of deliveries held back during quiet hours. Once the other
+// channels are delivered, the bell notification is pushed to the user's open connections, unless
+// it has been seen in the meantime.
+func (handler *NotificationHandler) DeliverDeferred(job *domain.Job, span trace.Span) (*domain.Job, error) {
+	next, err := handler.deferredDeliveries.Deliver(job, span)
+	if err != nil || next != nil || !slices.Contains(job.Channels, domain.BellChannel) {
+		return next, err
+	}
+
+	locales := handler.preferredLocales(job.UserId, "", span)
+	notificationDTO, err := handler.notificationService.Get(job.UserId, job.NotificationId, locales, span, handler.loki)
+	if errors.Is(err, mongo.ErrNoDocuments) || notificationDTO.Seen {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := publishEvent(handler.fanout, job.UserId, realtime.NotificationCreatedEvent, notificationDTO.Id.Hex(), notificationDTO); err != nil {
+		util.HttpTraceError(err, "failed to publish notification", span, handler.loki, "DeliverDeferred", "")
+	}
+	return nil, nil
+}
+
+// DeliverDigest is the JobHandler of digests. Once delivered, the digest notification is pushed
+// to the user's open connections.
+func (handler *NotificationHandler) DeliverDigest(job *domain.Job, span trace.Span) (*domain.Job, error) {
+	next, err := handler.digests.Deliver(job, span)
+	if err != nil || next != nil {
+		return next, err
+	}
+
+	locales := handler.preferredLocales(job.UserId, "", span)
+	notificationDTO, err := handler.notificationService.Get(job.UserId, job.NotificationId, locales, span, handler.loki)
+	if errors.Is(err, mongo.ErrNoDocuments) || notificationDTO.Seen {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := publishEvent(handler.fanout, job.UserId, realtime.NotificationCreatedEvent, notificationDTO.Id.Hex(), notificationDTO); err != nil {
+		util.HttpTraceError(err, "failed to publish notification", span, handler.loki, "DeliverDigest", "")
+	}
+	handler.publishUnreadCount(job.UserId, span)
+	return nil, nil
+}
+
+// dispatch delivers the notification over the channels other than the bell in the background,
+// so slow transports do not hold up the consumer.
+// dispatchAsync dispatches the delivery in the background, waiting while as many deliveries as
+// there are dispatch workers are in flight, so a burst of events slows down consuming instead of
+// piling up goroutines and connections to the channels.
+func (handler *NotificationHandler) dispatchAsync(delivery domain.Delivery, channels []domain.Channel) {
+	handler.dispatches <- struct{}{}
+	handler.dispatching.Add(1)
+	go func() {
+		defer func() {
+			<-handler.dispatches
+			handler.dispatching.Done()
+		}()
+		handler.dispatch(delivery, channels)
+	}()
+}
+
+// Drain waits until the deliveries in flight are dispatched or the context is done. Events must
+// no longer be consumed when it is called.
+func (handler *NotificationHandler) Drain(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		handler.dispatching.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (handler *NotificationHandler) dispatch(delivery domain.Delivery, channels []domain.Channel) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(context.TODO(), "dispatch-notification")
+	defer func() { span.End() }()
+	handler.dispatcher.Dispatch(delivery, channels, span, handler.loki)
 }
 
 func (handler *NotificationHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := userIdFromJwtPayload(r)
+	if err != nil {
+		log.Printf("Rejecting WebSocket connection: %v", err)
+		handleError(w, http.StatusUnauthorized, domain.UnauthorizedErrorMessage)
+		return
+	}
+
+	replay, err := handler.replayFrom(userId, r.URL.Query().Get(domain.SinceQueryParam), r.Header.Get(domain.AcceptLanguageHeader))
+	if err != nil {
+		handleError(w, http.StatusBadRequest, domain.InvalidCursorErrorMessage)
+		return
+	}
+
 	conn, err := handler.upgrades.Upgrade(w, r, nil)
 	if err != nil {
 		fmt.Println("Error upgrading to WebSocket:", err)
 		return
 	}
-	handler.connections = append(handler.connections, conn)
+
+	handler.hub.Serve(conn, userId, replay, handler)
+}
+
+func (handler *NotificationHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+
+	userId, err := userIdFromJwtPayload(r)
+	if err != nil {
+		log.Printf("Rejecting event stream connection: %v", err)
+		handleError(w, http.StatusUnauthorized, domain.UnauthorizedErrorMessage)
+		return
+	}
+	if userId != id {
+		handleError(w, http.StatusForbidden, domain.ForbiddenErrorMessage)
+		return
+	}
+
+	since := r.Header.Get(domain.LastEventIdHeader)
+	if since == "" {
+		since = r.URL.Query().Get(domain.SinceQueryParam)
+	}
+	replay, err := handler.replayFrom(userId, since, r.Header.Get(domain.AcceptLanguageHeader))
+	if err != nil {
+		handleError(w, http.StatusBadRequest, domain.InvalidCursorErrorMessage)
+		return
+	}
+
+	var events []string
+	if query := r.URL.Query().Get(domain.EventsQueryParam); query != "" {
+		events = strings.Split(query, ",")
+	}
+
+	if err := handler.hub.ServeStream(w, r, userId, replay, events); err != nil {
+		log.Printf("Failed to serve event stream: %v", err)
+		handleError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (handler *NotificationHandler) replayFrom(userId, since, acceptLanguage string) (realtime.ReplayFunc, error) {
+	if since == "" {
+		return nil, nil
+	}
+	cursor, err := parseReplayCursor(since)
+	if err != nil {
+		return nil, err
+	}
+	return handler.missedNotifications(userId, cursor, acceptLanguage), nil
+}
+
+func (handler *NotificationHandler) missedNotifications(userId string, cursor domain.ReplayCursor, acceptLanguage string) realtime.ReplayFunc {
+	return func() ([]realtime.Message, error) {
+		_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(context.TODO(), "replay-missed-notifications")
+		defer func() { span.End() }()
+		locales := handler.preferredLocales(userId, acceptLanguage, span)
+		notifications, truncated, err := handler.notificationService.GetMissed(userId, locales, cursor, span, handler.loki)
+		if err != nil {
+			util.HttpTraceError(err, "failed to get missed notifications", span, handler.loki, "missedNotifications", "")
+			return nil, err
+		}
+
+		messages := make([]realtime.Message, 0, len(notifications))
+		for _, notification := range notifications {
+			message, err := realtime.NewMessage(realtime.NotificationCreatedEvent, notification.Id.Hex(), notification)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, message)
+		}
+		if truncated {
+			message, err := realtime.NewMessage(realtime.ReplayTruncatedEvent, "", dto.ReplayTruncatedDTO{Cursor: notifications[len(notifications)-1].Id.Hex()})
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, message)
+		}
+		return messages, nil
+	}
+}
+
+// preferredLocales lists the locales to render notifications in, in order of preference: the
+// languages of the Accept-Language header and then the locale chosen in the user's settings.
+func (handler *NotificationHandler) preferredLocales(userId, acceptLanguage string, span trace.Span) []string {
+	locales := acceptedLanguages(acceptLanguage)
+	locale, err := handler.settingsService.GetLocale(userId, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get locale", span, handler.loki, "preferredLocales", "")
+		return locales
+	}
+	return append(locales, locale)
+}
+
+func (handler *NotificationHandler) HandleCommand(userId string, command realtime.Envelope) error {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(context.TODO(), "handle-websocket-command")
+	defer func() { span.End() }()
+
+	switch command.Type {
+	case realtime.MarkSeenCommand:
+		var markSeenRequest request.MarkSeenCommandRequest
+		if err := json.Unmarshal(command.Payload, &markSeenRequest); err != nil {
+			util.HttpTraceError(err, "invalid command payload", span, handler.loki, "HandleCommand", "")
+			return errors.New("invalid mark_seen payload")
+		}
+		if err := markSeenRequest.AreValidRequestData(); err != nil {
+			util.HttpTraceError(err, "invalid command data", span, handler.loki, "HandleCommand", "")
+			return err
+		}
+
+		id, _ := primitive.ObjectIDFromHex(markSeenRequest.NotificationId)
+		if err := handler.notificationService.MarkSeen(userId, id, span, handler.loki); err != nil {
+			util.HttpTraceError(err, "failed to mark notification as seen", span, handler.loki, "HandleCommand", "")
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return errors.New(domain.NotificationNotFoundErrorMessage)
+			}
+			return err
+		}
+		handler.cancelEscalations(userId, []primitive.ObjectID{id}, span)
+		handler.publishSeen(userId, dto.NotificationSeenDTO{Ids: []string{markSeenRequest.NotificationId}, Seen: true}, span)
+	case realtime.MarkAllSeenCommand:
+		if err := handler.notificationService.UpdateStatus(userId, span, handler.loki); err != nil {
+			util.HttpTraceError(err, "failed to update notification status", span, handler.loki, "HandleCommand", "")
+			return err
+		}
+		handler.cancelEscalations(userId, nil, span)
+		handler.publishSeen(userId, dto.NotificationSeenDTO{All: true, Seen: true}, span)
+	default:
+		return errors.New("unknown command " + command.Type)
+	}
+
+	return nil
+}
+
+// publishSeen notifies all connections of the user about notifications marked as seen or
+// unseen, followed by the new unread count.
+func (handler *NotificationHandler) publishSeen(userId string, seen dto.NotificationSeenDTO, span trace.Span) {
+	if err := publishEvent(handler.fanout, userId, realtime.NotificationSeenEvent, "", seen); err != nil {
+		util.HttpTraceError(err, "failed to publish seen notifications", span, handler.loki, "publishSeen", "")
+	}
+	handler.publishUnreadCount(userId, span)
+}
+
+// cancelEscalations stops escalating the notifications the user has seen, all of them when ids
+// is nil.
+func (handler *NotificationHandler) cancelEscalations(userId string, ids []primitive.ObjectID, span trace.Span) {
+	var err error
+	if ids == nil {
+		err = handler.escalations.CancelAll(userId, span, handler.loki)
+	} else {
+		err = handler.escalations.Cancel(ids, span, handler.loki)
+	}
+	if err != nil {
+		util.HttpTraceError(err, "failed to cancel escalations", span, handler.loki, "cancelEscalations", "")
+	}
+}
+
+func (handler *NotificationHandler) publishUnreadCount(userId string, span trace.Span) {
+	unreadCount, err := handler.notificationService.GetUnreadCount(userId, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to count unread notifications", span, handler.loki, "publishUnreadCount", "")
+		return
+	}
+	if err := publishEvent(handler.fanout, userId, realtime.UnreadCountChangedEvent, "", unreadCount); err != nil {
+		util.HttpTraceError(err, "failed to publish unread count", span, handler.loki, "publishUnreadCount", "")
+	}
 }
 
 func (handler *NotificationHandler) GetAllByUserId(w http.ResponseWriter, r *http.Request) {
-	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-all-by-user-id-")
+	handler.getPage(w, r, "get-all-by-user-id-", false)
+}
+
+func (handler *NotificationHandler) GetArchived(w http.ResponseWriter, r *http.Request) {
+	handler.getPage(w, r, "get-archived-get", true)
+}
+
+func (handler *NotificationHandler) getPage(w http.ResponseWriter, r *http.Request, spanName string, archived bool) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), spanName)
 	defer func() { span.End() }()
 	id := mux.Vars(r)["userId"]
 	if id == "" {
@@ -143,12 +501,50 @@ func (handler *NotificationHandler) GetAllByUserId(w http.ResponseWriter, r *htt
 		return
 	}
 
-	response, err := handler.notificationService.GetAllByUserId(id, span, handler.loki)
+	queryRequest, err := request.NewBellNotificationQueryRequest(r.URL.Query())
+	if err != nil {
+		util.HttpTraceError(err, "invalid query parameters", span, handler.loki, "GetAllByUserId", "")
+		handleError(w, http.StatusBadRequest, "Invalid notification query parameters")
+		return
+	}
+	if err := queryRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid query parameters", span, handler.loki, "GetAllByUserId", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := queryRequest.ToQuery()
+	query.Archived = archived
+	locales := handler.preferredLocales(id, r.Header.Get(domain.AcceptLanguageHeader), span)
+	response, nextCursor, err := handler.notificationService.GetAllByUserId(id, locales, query, span, handler.loki)
 	if err != nil {
 		util.HttpTraceError(err, "failed to get all notifications by id", span, handler.loki, "GetAllByUserId", "")
 		handleError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if nextCursor != "" {
+		w.Header().Set(domain.NextCursorHeader, nextCursor)
+	}
+
+	writeResponse(w, http.StatusOK, response)
+}
+
+func (handler *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-unread-count-get")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "GetUnreadCount", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+
+	response, err := handler.notificationService.GetUnreadCount(id, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to count unread notifications", span, handler.loki, "GetUnreadCount", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	writeResponse(w, http.StatusOK, response)
 }
@@ -168,17 +564,151 @@ func (handler *NotificationHandler) UpdateStatus(w http.ResponseWriter, r *http.
 		handleError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	handler.cancelEscalations(id, nil, span)
+	handler.publishSeen(id, dto.NotificationSeenDTO{All: true, Seen: true}, span)
 
 	writeResponse(w, http.StatusAccepted, nil)
 }
 
-func (handler *NotificationHandler) sendWebSocketMessage(jsonMessage []byte) {
-	for _, conn := range handler.connections {
-		err := conn.WriteMessage(websocket.TextMessage, jsonMessage)
-		if err != nil {
-			conn.Close()
+// notificationsOperation changes the state of the given notifications of a user.
+type notificationsOperation func(userId string, ids []primitive.ObjectID, span trace.Span) error
+
+func (handler *NotificationHandler) MarkSeen(w http.ResponseWriter, r *http.Request) {
+	handler.onNotification(w, r, "mark-seen-put", handler.seenOperation(true))
+}
+
+func (handler *NotificationHandler) MarkUnseen(w http.ResponseWriter, r *http.Request) {
+	handler.onNotification(w, r, "mark-unseen-put", handler.seenOperation(false))
+}
+
+func (handler *NotificationHandler) MarkSeenBulk(w http.ResponseWriter, r *http.Request) {
+	handler.onNotifications(w, r, "mark-seen-bulk-put", handler.seenOperation(true))
+}
+
+func (handler *NotificationHandler) MarkUnseenBulk(w http.ResponseWriter, r *http.Request) {
+	handler.onNotifications(w, r, "mark-unseen-bulk-put", handler.seenOperation(false))
+}
+
+func (handler *NotificationHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	handler.onNotification(w, r, "archive-put", handler.lifecycleOperation(handler.notificationService.Archive))
+}
+
+func (handler *NotificationHandler) ArchiveBulk(w http.ResponseWriter, r *http.Request) {
+	handler.onNotifications(w, r, "archive-bulk-put", handler.lifecycleOperation(handler.notificationService.Archive))
+}
+
+func (handler *NotificationHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	handler.onNotification(w, r, "delete-delete", handler.lifecycleOperation(handler.notificationService.Delete))
+}
+
+func (handler *NotificationHandler) DeleteBulk(w http.ResponseWriter, r *http.Request) {
+	handler.onNotifications(w, r, "delete-bulk-put", handler.lifecycleOperation(handler.notificationService.Delete))
+}
+
+func (handler *NotificationHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	handler.onNotification(w, r, "restore-put", handler.lifecycleOperation(handler.notificationService.Restore))
+}
+
+func (handler *NotificationHandler) RestoreBulk(w http.ResponseWriter, r *http.Request) {
+	handler.onNotifications(w, r, "restore-bulk-put", handler.lifecycleOperation(handler.notificationService.Restore))
+}
+
+func (handler *NotificationHandler) seenOperation(seen bool) notificationsOperation {
+	return func(userId string, ids []primitive.ObjectID, span trace.Span) error {
+		if err := handler.notificationService.UpdateSeen(userId, ids, seen, span, handler.loki); err != nil {
+			return err
+		}
+		if seen {
+			handler.cancelEscalations(userId, ids, span)
+		}
+
+		hexIds := make([]string, 0, len(ids))
+		for _, id := range ids {
+			hexIds = append(hexIds, id.Hex())
+		}
+		handler.publishSeen(userId, dto.NotificationSeenDTO{Ids: hexIds, Seen: seen}, span)
+		return nil
+	}
+}
+
+// lifecycleOperation wraps archiving, deleting and restoring, all of which change the unread count.
+func (handler *NotificationHandler) lifecycleOperation(operation func(string, []primitive.ObjectID, trace.Span, promtail.Client) error) notificationsOperation {
+	return func(userId string, ids []primitive.ObjectID, span trace.Span) error {
+		if err := operation(userId, ids, span, handler.loki); err != nil {
+			return err
+		}
+		handler.publishUnreadCount(userId, span)
+		return nil
+	}
+}
+
+func (handler *NotificationHandler) onNotification(w http.ResponseWriter, r *http.Request, spanName string, operation notificationsOperation) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), spanName)
+	defer func() { span.End() }()
+	userId := mux.Vars(r)["userId"]
+	if userId == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "onNotification", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	notificationId, err := primitive.ObjectIDFromHex(mux.Vars(r)["notificationId"])
+	if err != nil {
+		util.HttpTraceError(err, "invalid notification id", span, handler.loki, "onNotification", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidNotificationIDErrorMessage)
+		return
+	}
+
+	handler.apply(w, userId, []primitive.ObjectID{notificationId}, operation, span)
+}
+
+func (handler *NotificationHandler) onNotifications(w http.ResponseWriter, r *http.Request, spanName string, operation notificationsOperation) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), spanName)
+	defer func() { span.End() }()
+	userId := mux.Vars(r)["userId"]
+	if userId == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "onNotifications", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+
+	var idsRequest request.NotificationIdsRequest
+	if err := json.NewDecoder(r.Body).Decode(&idsRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "onNotifications", "")
+		handleError(w, http.StatusBadRequest, "Invalid notification ids payload")
+		return
+	}
+	if err := idsRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "onNotifications", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	handler.apply(w, userId, idsRequest.ToObjectIDs(), operation, span)
+}
+
+func (handler *NotificationHandler) apply(w http.ResponseWriter, userId string, ids []primitive.ObjectID, operation notificationsOperation, span trace.Span) {
+	if err := operation(userId, ids, span); err != nil {
+		util.HttpTraceError(err, "failed to update notifications", span, handler.loki, "apply", "")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			handleError(w, http.StatusNotFound, domain.NotificationNotFoundErrorMessage)
+			return
 		}
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeResponse(w, http.StatusAccepted, nil)
+}
+
+func parseReplayCursor(since string) (domain.ReplayCursor, error) {
+	if id, err := primitive.ObjectIDFromHex(since); err == nil {
+		return domain.ReplayCursor{AfterId: id}, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, since)
+	if err != nil {
+		return domain.ReplayCursor{}, err
 	}
+	return domain.ReplayCursor{AfterTime: timestamp}, nil
 }
 
 func (handler *NotificationHandler) GetHealthCheck(w http.ResponseWriter, r *http.Request) {
@@ -9,22 +9,28 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/mmmajder/zms-devops-notification-service/application"
 	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/dto"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/realtime"
 	"github.com/mmmajder/zms-devops-notification-service/infrastructure/request"
 	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/mongo"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"log"
 	"net/http"
 )
 
 type NotificationSettingsHandler struct {
 	settingsService *application.NotificationSettingsService
+	fanout          realtime.Fanout
 	traceProvider   *sdktrace.TracerProvider
 	loki            promtail.Client
 }
 
-func NewNotificationSettingsHandler(settingsService *application.NotificationSettingsService, traceProvider *sdktrace.TracerProvider, loki promtail.Client) *NotificationSettingsHandler {
+func NewNotificationSettingsHandler(settingsService *application.NotificationSettingsService, fanout realtime.Fanout, traceProvider *sdktrace.TracerProvider, loki promtail.Client) *NotificationSettingsHandler {
 	return &NotificationSettingsHandler{
 		settingsService: settingsService,
+		fanout:          fanout,
 		traceProvider:   traceProvider,
 		loki:            loki,
 	}
@@ -33,6 +39,14 @@ func NewNotificationSettingsHandler(settingsService *application.NotificationSet
 func (handler *NotificationSettingsHandler) Init(router *mux.Router) {
 	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam, handler.GetSettings).Methods(http.MethodGet)
 	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam, handler.UpdateSettings).Methods(http.MethodPut)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/locale", handler.GetLocale).Methods(http.MethodGet)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/locale", handler.UpdateLocale).Methods(http.MethodPut)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/email", handler.GetEmail).Methods(http.MethodGet)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/email", handler.UpdateEmail).Methods(http.MethodPut)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/phone", handler.GetPhone).Methods(http.MethodGet)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/phone", handler.UpdatePhone).Methods(http.MethodPut)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/quiet-hours", handler.GetQuietHours).Methods(http.MethodGet)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/quiet-hours", handler.UpdateQuietHours).Methods(http.MethodPut)
 	router.HandleFunc(domain.NotificationContextPath+"/health/check", handler.GetHealthCheck).Methods(http.MethodGet)
 }
 
@@ -91,10 +105,274 @@ func (handler *NotificationSettingsHandler) UpdateSettings(w http.ResponseWriter
 		return
 	}
 	util.HttpTraceInfo("Settings updated successfully", span, handler.loki, "AddRequest", "")
+	handler.publishSettingsChanged(id, span)
 
 	writeResponse(w, http.StatusAccepted, nil)
 }
 
+func (handler *NotificationSettingsHandler) GetLocale(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-locale-get")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "GetLocale", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	locale, err := handler.settingsService.GetLocale(id, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get locale", span, handler.loki, "GetLocale", "")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			handleError(w, http.StatusNotFound, domain.SettingsNotFoundErrorMessage)
+			return
+		}
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Locale fetched successfully", span, handler.loki, "GetLocale", "")
+
+	writeResponse(w, http.StatusOK, dto.LocaleDTO{Locale: locale})
+}
+
+func (handler *NotificationSettingsHandler) UpdateLocale(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "update-locale-put")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "UpdateLocale", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	var localeRequest request.LocaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&localeRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "UpdateLocale", "")
+		handleError(w, http.StatusBadRequest, "Invalid locale payload")
+		return
+	}
+
+	if err := localeRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "UpdateLocale", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := handler.settingsService.UpdateLocale(id, localeRequest.Locale, span, handler.loki); err != nil {
+		util.HttpTraceError(err, "failed to update locale", span, handler.loki, "UpdateLocale", "")
+		if errors.Is(err, application.ErrUnsupportedLocale) {
+			handleError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			handleError(w, http.StatusNotFound, domain.SettingsNotFoundErrorMessage)
+			return
+		}
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Locale updated successfully", span, handler.loki, "UpdateLocale", "")
+
+	writeResponse(w, http.StatusAccepted, nil)
+}
+
+func (handler *NotificationSettingsHandler) GetEmail(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-email-get")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "GetEmail", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	email, err := handler.settingsService.GetEmail(id, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get email", span, handler.loki, "GetEmail", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Email fetched successfully", span, handler.loki, "GetEmail", "")
+
+	writeResponse(w, http.StatusOK, dto.EmailDTO{Email: email})
+}
+
+func (handler *NotificationSettingsHandler) UpdateEmail(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "update-email-put")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "UpdateEmail", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	var emailRequest request.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&emailRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "UpdateEmail", "")
+		handleError(w, http.StatusBadRequest, "Invalid email payload")
+		return
+	}
+
+	if err := emailRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "UpdateEmail", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := handler.settingsService.UpdateEmail(id, emailRequest.Email, span, handler.loki); err != nil {
+		util.HttpTraceError(err, "failed to update email", span, handler.loki, "UpdateEmail", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Email updated successfully", span, handler.loki, "UpdateEmail", "")
+
+	writeResponse(w, http.StatusAccepted, nil)
+}
+
+func (handler *NotificationSettingsHandler) GetPhone(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-phone-get")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "GetPhone", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	phone, err := handler.settingsService.GetPhone(id, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get phone", span, handler.loki, "GetPhone", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Phone fetched successfully", span, handler.loki, "GetPhone", "")
+
+	writeResponse(w, http.StatusOK, phone)
+}
+
+func (handler *NotificationSettingsHandler) UpdatePhone(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "update-phone-put")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "UpdatePhone", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	var phoneRequest request.PhoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&phoneRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "UpdatePhone", "")
+		handleError(w, http.StatusBadRequest, "Invalid phone payload")
+		return
+	}
+
+	if err := phoneRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "UpdatePhone", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := handler.settingsService.UpdatePhone(id, phoneRequest.Phone, phoneRequest.SmsOptIn, span, handler.loki); err != nil {
+		util.HttpTraceError(err, "failed to update phone", span, handler.loki, "UpdatePhone", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Phone updated successfully", span, handler.loki, "UpdatePhone", "")
+
+	writeResponse(w, http.StatusAccepted, nil)
+}
+
+func (handler *NotificationSettingsHandler) GetQuietHours(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-quiet-hours-get")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "GetQuietHours", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	quietHours, err := handler.settingsService.GetQuietHours(id, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get quiet hours", span, handler.loki, "GetQuietHours", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Quiet hours fetched successfully", span, handler.loki, "GetQuietHours", "")
+
+	writeResponse(w, http.StatusOK, quietHours)
+}
+
+func (handler *NotificationSettingsHandler) UpdateQuietHours(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "update-quiet-hours-put")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "UpdateQuietHours", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	var quietHoursRequest request.QuietHoursRequest
+	if err := json.NewDecoder(r.Body).Decode(&quietHoursRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "UpdateQuietHours", "")
+		handleError(w, http.StatusBadRequest, "Invalid quiet hours payload")
+		return
+	}
+
+	if err := quietHoursRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "UpdateQuietHours", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := handler.settingsService.UpdateQuietHours(id, quietHoursRequest.TimeZone, quietHoursRequest.ToQuietHours(), span, handler.loki); err != nil {
+		util.HttpTraceError(err, "failed to update quiet hours", span, handler.loki, "UpdateQuietHours", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Quiet hours updated successfully", span, handler.loki, "UpdateQuietHours", "")
+
+	writeResponse(w, http.StatusAccepted, nil)
+}
+
+func (handler *NotificationSettingsHandler) publishSettingsChanged(userId string, span trace.Span) {
+	settings, err := handler.settingsService.Get(userId, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get settings", span, handler.loki, "publishSettingsChanged", "")
+		return
+	}
+	if err := publishEvent(handler.fanout, userId, realtime.SettingsChangedEvent, "", settings); err != nil {
+		util.HttpTraceError(err, "failed to publish settings change", span, handler.loki, "publishSettingsChanged", "")
+	}
+}
+
 func (handler *NotificationSettingsHandler) DeleteSettings(w http.ResponseWriter, r *http.Request) {
 	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "delete-settings-delete")
 	defer func() { span.End() }()
@@ -132,7 +410,7 @@ func (handler *NotificationSettingsHandler) OnUserCreated(message *kafka.Message
 func (handler *NotificationSettingsHandler) onCreateUserNotification(createdUser request.UserCreatedNotificationRequest) {
 	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(context.TODO(), "on-create-user-notification")
 	defer func() { span.End() }()
-	if err := handler.settingsService.Insert(createdUser.UserId, createdUser.Role, span, handler.loki); err != nil {
+	if err := handler.settingsService.Insert(createdUser.UserId, createdUser.Role, createdUser.Email, span, handler.loki); err != nil {
 
 		return
 	}
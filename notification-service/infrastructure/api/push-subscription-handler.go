@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/gorilla/mux"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/dto"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/request"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/mongo"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"net/http"
+)
+
+type PushSubscriptionHandler struct {
+	subscriptionService *application.PushSubscriptionService
+	vapidPublicKey      string
+	traceProvider       *sdktrace.TracerProvider
+	loki                promtail.Client
+}
+
+func NewPushSubscriptionHandler(subscriptionService *application.PushSubscriptionService, vapidPublicKey string, traceProvider *sdktrace.TracerProvider, loki promtail.Client) *PushSubscriptionHandler {
+	return &PushSubscriptionHandler{
+		subscriptionService: subscriptionService,
+		vapidPublicKey:      vapidPublicKey,
+		traceProvider:       traceProvider,
+		loki:                loki,
+	}
+}
+
+func (handler *PushSubscriptionHandler) Init(router *mux.Router) {
+	router.HandleFunc(domain.NotificationContextPath+"/push/vapid-public-key", handler.GetVapidPublicKey).Methods(http.MethodGet)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/push-subscriptions", handler.Subscribe).Methods(http.MethodPost)
+	router.HandleFunc(domain.NotificationContextPath+domain.UserIDParam+"/push-subscriptions", handler.Unsubscribe).Methods(http.MethodDelete)
+}
+
+func (handler *PushSubscriptionHandler) GetVapidPublicKey(w http.ResponseWriter, r *http.Request) {
+	if handler.vapidPublicKey == "" {
+		handleError(w, http.StatusNotFound, domain.PushDisabledErrorMessage)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, dto.VapidPublicKeyDTO{PublicKey: handler.vapidPublicKey})
+}
+
+func (handler *PushSubscriptionHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "push-subscribe-post")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "Subscribe", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	var subscriptionRequest request.PushSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&subscriptionRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "Subscribe", "")
+		handleError(w, http.StatusBadRequest, "Invalid push subscription payload")
+		return
+	}
+
+	if err := subscriptionRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "Subscribe", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	subscription := subscriptionRequest.ToPushSubscription(id, r.UserAgent())
+	if err := handler.subscriptionService.Subscribe(subscription, span, handler.loki); err != nil {
+		util.HttpTraceError(err, "failed to register push subscription", span, handler.loki, "Subscribe", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Push subscription registered successfully", span, handler.loki, "Subscribe", "")
+
+	writeResponse(w, http.StatusCreated, nil)
+}
+
+func (handler *PushSubscriptionHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "push-unsubscribe-delete")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "Unsubscribe", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id) {
+		return
+	}
+
+	var unsubscriptionRequest request.PushUnsubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&unsubscriptionRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "Unsubscribe", "")
+		handleError(w, http.StatusBadRequest, "Invalid push subscription payload")
+		return
+	}
+
+	if err := unsubscriptionRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "Unsubscribe", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := handler.subscriptionService.Unsubscribe(id, unsubscriptionRequest.Endpoint, span, handler.loki); err != nil {
+		util.HttpTraceError(err, "failed to remove push subscription", span, handler.loki, "Unsubscribe", "")
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			handleError(w, http.StatusNotFound, domain.PushSubscriptionNotFoundErrorMessage)
+			return
+		}
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Push subscription removed successfully", span, handler.loki, "Unsubscribe", "")
+
+	writeResponse(w, http.StatusOK, nil)
+}
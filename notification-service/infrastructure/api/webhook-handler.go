@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/afiskon/promtail-client/promtail"
+	"github.com/gorilla/mux"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/dto"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/request"
+	"github.com/mmmajder/zms-devops-notification-service/util"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+type WebhookHandler struct {
+	webhookService *application.WebhookService
+	traceProvider  *sdktrace.TracerProvider
+	loki           promtail.Client
+}
+
+func NewWebhookHandler(webhookService *application.WebhookService, traceProvider *sdktrace.TracerProvider, loki promtail.Client) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		traceProvider:  traceProvider,
+		loki:           loki,
+	}
+}
+
+// Init registers the webhook routes, which serve the user of the path and admins.
+func (handler *WebhookHandler) Init(router *mux.Router) {
+	webhooksPath := domain.NotificationContextPath + domain.UserIDParam + "/webhooks"
+	router.HandleFunc(webhooksPath, handler.Register).Methods(http.MethodPost)
+	router.HandleFunc(webhooksPath, handler.GetAll).Methods(http.MethodGet)
+	router.HandleFunc(webhooksPath+domain.WebhookIDParam, handler.Delete).Methods(http.MethodDelete)
+	router.HandleFunc(webhooksPath+domain.WebhookIDParam+"/enable", handler.Enable).Methods(http.MethodPut)
+	router.HandleFunc(webhooksPath+domain.WebhookIDParam+"/deliveries", handler.GetDeliveries).Methods(http.MethodGet)
+}
+
+func (handler *WebhookHandler) Register(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "register-webhook-post")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "Register", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id, domain.RoleAdmin) {
+		return
+	}
+
+	var webhookRequest request.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&webhookRequest); err != nil {
+		util.HttpTraceError(err, "invalid request payload", span, handler.loki, "Register", "")
+		handleError(w, http.StatusBadRequest, "Invalid webhook payload")
+		return
+	}
+
+	if err := webhookRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid request data", span, handler.loki, "Register", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := handler.webhookService.Register(webhookRequest.ToWebhook(id), span, handler.loki)
+	if errors.Is(err, application.ErrUnsafeWebhookUrl) {
+		util.HttpTraceError(err, "unsafe webhook url", span, handler.loki, "Register", "")
+		handleError(w, http.StatusBadRequest, domain.UnsafeWebhookUrlErrorMessage)
+		return
+	}
+	if err != nil {
+		util.HttpTraceError(err, "failed to register webhook", span, handler.loki, "Register", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Webhook registered successfully", span, handler.loki, "Register", "")
+
+	response := dto.FromWebhook(webhook)
+	response.Secret = webhook.Secret
+	writeResponse(w, http.StatusCreated, response)
+}
+
+func (handler *WebhookHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-webhooks-get")
+	defer func() { span.End() }()
+	id := mux.Vars(r)["userId"]
+	if id == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, "GetAll", "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return
+	}
+	if !authorizeUser(w, r, id, domain.RoleAdmin) {
+		return
+	}
+
+	webhooks, err := handler.webhookService.GetByUserId(id, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get webhooks", span, handler.loki, "GetAll", "")
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	util.HttpTraceInfo("Webhooks fetched successfully", span, handler.loki, "GetAll", "")
+
+	writeResponse(w, http.StatusOK, dto.FromWebhooks(webhooks))
+}
+
+func (handler *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	handler.onWebhook(w, r, "delete-webhook-delete", "Delete", func(userId string, webhookId primitive.ObjectID, span trace.Span) error {
+		return handler.webhookService.Delete(userId, webhookId, span, handler.loki)
+	})
+}
+
+func (handler *WebhookHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	handler.onWebhook(w, r, "enable-webhook-put", "Enable", func(userId string, webhookId primitive.ObjectID, span trace.Span) error {
+		return handler.webhookService.Enable(userId, webhookId, span, handler.loki)
+	})
+}
+
+func (handler *WebhookHandler) onWebhook(w http.ResponseWriter, r *http.Request, spanName, method string, operation func(string, primitive.ObjectID, trace.Span) error) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), spanName)
+	defer func() { span.End() }()
+	userId, webhookId, ok := handler.webhookVars(w, r, method, span)
+	if !ok {
+		return
+	}
+
+	if err := operation(userId, webhookId, span); err != nil {
+		util.HttpTraceError(err, "failed to update webhook", span, handler.loki, method, "")
+		handler.handleWebhookError(w, err)
+		return
+	}
+	util.HttpTraceInfo("Webhook updated successfully", span, handler.loki, method, "")
+
+	writeResponse(w, http.StatusOK, nil)
+}
+
+func (handler *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	_, span := handler.traceProvider.Tracer(domain.ServiceName).Start(r.Context(), "get-webhook-deliveries-get")
+	defer func() { span.End() }()
+	userId, webhookId, ok := handler.webhookVars(w, r, "GetDeliveries", span)
+	if !ok {
+		return
+	}
+
+	queryRequest, err := request.NewWebhookAttemptsQueryRequest(r.URL.Query())
+	if err != nil {
+		util.HttpTraceError(err, "invalid query parameters", span, handler.loki, "GetDeliveries", "")
+		handleError(w, http.StatusBadRequest, "Invalid webhook delivery query parameters")
+		return
+	}
+	if err := queryRequest.AreValidRequestData(); err != nil {
+		util.HttpTraceError(err, "invalid query parameters", span, handler.loki, "GetDeliveries", "")
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	attempts, err := handler.webhookService.GetAttempts(userId, webhookId, queryRequest.Limit, span, handler.loki)
+	if err != nil {
+		util.HttpTraceError(err, "failed to get webhook deliveries", span, handler.loki, "GetDeliveries", "")
+		handler.handleWebhookError(w, err)
+		return
+	}
+	util.HttpTraceInfo("Webhook deliveries fetched successfully", span, handler.loki, "GetDeliveries", "")
+
+	writeResponse(w, http.StatusOK, dto.FromWebhookAttempts(attempts))
+}
+
+func (handler *WebhookHandler) webhookVars(w http.ResponseWriter, r *http.Request, method string, span trace.Span) (string, primitive.ObjectID, bool) {
+	userId := mux.Vars(r)["userId"]
+	if userId == "" {
+		util.HttpTraceError(errors.New("user id can not be empty"), "user id can not be empty", span, handler.loki, method, "")
+		handleError(w, http.StatusBadRequest, domain.InvalidIDErrorMessage)
+		return "", primitive.NilObjectID, false
+	}
+	if !authorizeUser(w, r, userId, domain.RoleAdmin) {
+		return "", primitive.NilObjectID, false
+	}
+	webhookId, err := primitive.ObjectIDFromHex(mux.Vars(r)["webhookId"])
+	if err != nil {
+		util.HttpTraceError(err, "invalid webhook id", span, handler.loki, method, "")
+		handleError(w, http.StatusBadRequest, domain.InvalidWebhookIDErrorMessage)
+		return "", primitive.NilObjectID, false
+	}
+	return userId, webhookId, true
+}
+
+func (handler *WebhookHandler) handleWebhookError(w http.ResponseWriter, err error) {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		handleError(w, http.StatusNotFound, domain.WebhookNotFoundErrorMessage)
+		return
+	}
+	handleError(w, http.StatusInternalServerError, err.Error())
+}
@@ -6,14 +6,25 @@ import (
 	"time"
 )
 
+type NotificationPayloadDTO struct {
+	ReservationId   string `json:"reservationId,omitempty"`
+	AccommodationId string `json:"accommodationId,omitempty"`
+	ActorId         string `json:"actorId,omitempty"`
+	ActorName       string `json:"actorName,omitempty"`
+	Status          string `json:"status,omitempty"`
+}
+
 type BellNotificationDTO struct {
-	Id             primitive.ObjectID `json:"id"`
-	UserId         string             `json:"userId"`
-	Message        string             `json:"message"`
-	TimeStamp      time.Time          `json:"timeStamp"`
-	Seen           bool               `json:"seen"`
-	ShouldRedirect bool               `json:"shouldRedirect"`
-	RedirectId     string             `json:"redirectId"`
+	Id             primitive.ObjectID      `json:"id"`
+	UserId         string                  `json:"userId"`
+	Type           domain.NotificationType `json:"type"`
+	Payload        NotificationPayloadDTO  `json:"payload"`
+	Message        string                  `json:"message"`
+	TimeStamp      time.Time               `json:"timeStamp"`
+	Seen           bool                    `json:"seen"`
+	SeenAt         *time.Time              `json:"seenAt,omitempty"`
+	ShouldRedirect bool                    `json:"shouldRedirect"`
+	RedirectId     string                  `json:"redirectId"`
 }
 
 func FromReviews(notifications []*domain.BellNotification) *[]BellNotificationDTO {
@@ -29,11 +40,26 @@ func FromNotification(notification *domain.BellNotification) BellNotificationDTO
 	dto := BellNotificationDTO{
 		Id:             notification.Id,
 		UserId:         notification.UserId,
+		Type:           notification.Type,
+		Payload:        FromPayload(notification.Payload),
 		Message:        notification.Message,
 		TimeStamp:      notification.TimeStamp,
 		Seen:           notification.Seen,
 		ShouldRedirect: notification.ShouldRedirect,
 		RedirectId:     notification.RedirectId,
 	}
+	if !notification.SeenAt.IsZero() {
+		dto.SeenAt = &notification.SeenAt
+	}
 	return dto
 }
+
+func FromPayload(payload domain.NotificationPayload) NotificationPayloadDTO {
+	return NotificationPayloadDTO{
+		ReservationId:   payload.ReservationId,
+		AccommodationId: payload.AccommodationId,
+		ActorId:         payload.ActorId,
+		ActorName:       payload.ActorName,
+		Status:          payload.Status,
+	}
+}
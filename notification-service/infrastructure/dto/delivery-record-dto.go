@@ -0,0 +1,37 @@
+package dto
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type DeliveryRecordDTO struct {
+	Id             primitive.ObjectID      `json:"id"`
+	NotificationId primitive.ObjectID      `json:"notificationId"`
+	UserId         string                  `json:"userId"`
+	Type           domain.NotificationType `json:"type"`
+	Channel        domain.Channel          `json:"channel"`
+	Status         domain.DeliveryStatus   `json:"status"`
+	Error          string                  `json:"error,omitempty"`
+	CreatedAt      time.Time               `json:"createdAt"`
+	UpdatedAt      time.Time               `json:"updatedAt"`
+}
+
+func FromDeliveryRecords(records []*domain.DeliveryRecord) []DeliveryRecordDTO {
+	recordDTOs := make([]DeliveryRecordDTO, 0, len(records))
+	for _, record := range records {
+		recordDTOs = append(recordDTOs, DeliveryRecordDTO{
+			Id:             record.Id,
+			NotificationId: record.NotificationId,
+			UserId:         record.UserId,
+			Type:           record.Type,
+			Channel:        record.Channel,
+			Status:         record.Status,
+			Error:          record.Error,
+			CreatedAt:      record.CreatedAt,
+			UpdatedAt:      record.UpdatedAt,
+		})
+	}
+	return recordDTOs
+}
@@ -0,0 +1,5 @@
+package dto
+
+type EmailDTO struct {
+	Email string `json:"email"`
+}
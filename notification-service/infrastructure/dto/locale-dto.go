@@ -0,0 +1,5 @@
+package dto
+
+type LocaleDTO struct {
+	Locale string `json:"locale"`
+}
@@ -0,0 +1,7 @@
+package dto
+
+type NotificationSeenDTO struct {
+	Ids  []string `json:"ids,omitempty"`
+	All  bool     `json:"all"`
+	Seen bool     `json:"seen"`
+}
@@ -5,8 +5,11 @@ import (
 )
 
 type NotificationSettingDTO struct {
-	Type   domain.NotificationType `json:"type"`
-	Active bool                    `json:"active"`
+	Type     domain.NotificationType `json:"type"`
+	Active   bool                    `json:"active"`
+	Channels map[domain.Channel]bool `json:"channels"`
+	Digest   domain.DigestFrequency  `json:"digest,omitempty"`
+	Escalate bool                    `json:"escalate"`
 }
 
 func FromUserNotificationSettings(settings *domain.Settings) *[]NotificationSettingDTO {
@@ -19,8 +22,15 @@ func FromUserNotificationSettings(settings *domain.Settings) *[]NotificationSett
 }
 
 func FromSetting(setting domain.NotificationSetting) NotificationSettingDTO {
+	channels := make(map[domain.Channel]bool, len(domain.Channels()))
+	for _, channel := range domain.Channels() {
+		channels[channel] = setting.Channels[channel] || setting.Channels == nil && channel == domain.BellChannel
+	}
 	return NotificationSettingDTO{
-		Type:   setting.Type,
-		Active: setting.Active,
+		Type:     setting.Type,
+		Active:   setting.Active,
+		Channels: channels,
+		Digest:   setting.Digest,
+		Escalate: setting.Escalate,
 	}
 }
@@ -0,0 +1,23 @@
+package dto
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"time"
+)
+
+type PhoneDTO struct {
+	Phone      string     `json:"phone"`
+	SmsOptIn   bool       `json:"smsOptIn"`
+	SmsOptInAt *time.Time `json:"smsOptInAt,omitempty"`
+}
+
+func FromPhone(settings *domain.Settings) PhoneDTO {
+	dto := PhoneDTO{
+		Phone:    settings.Phone,
+		SmsOptIn: settings.SmsOptIn,
+	}
+	if !settings.SmsOptInAt.IsZero() {
+		dto.SmsOptInAt = &settings.SmsOptInAt
+	}
+	return dto
+}
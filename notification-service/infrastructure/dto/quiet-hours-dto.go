@@ -0,0 +1,26 @@
+package dto
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+)
+
+type QuietHoursDTO struct {
+	TimeZone string                `json:"timeZone"`
+	Windows  []QuietHoursWindowDTO `json:"windows"`
+}
+
+type QuietHoursWindowDTO struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func FromQuietHours(settings *domain.Settings) QuietHoursDTO {
+	windows := make([]QuietHoursWindowDTO, 0, len(settings.QuietHours))
+	for _, window := range settings.QuietHours {
+		windows = append(windows, QuietHoursWindowDTO{Start: window.Start, End: window.End})
+	}
+	return QuietHoursDTO{
+		TimeZone: settings.TimeZone,
+		Windows:  windows,
+	}
+}
@@ -0,0 +1,8 @@
+package dto
+
+// ReplayTruncatedDTO ends a replay which left out newer missed notifications, which live delivery
+// does not cover either. Cursor is the id of the last replayed notification, so clients can
+// reconnect with it as the since cursor to receive the rest.
+type ReplayTruncatedDTO struct {
+	Cursor string `json:"cursor"`
+}
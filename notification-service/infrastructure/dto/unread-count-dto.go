@@ -0,0 +1,5 @@
+package dto
+
+type UnreadCountDTO struct {
+	Count int64 `json:"count"`
+}
@@ -0,0 +1,5 @@
+package dto
+
+type VapidPublicKeyDTO struct {
+	PublicKey string `json:"publicKey"`
+}
@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type WebhookDTO struct {
+	Id                  primitive.ObjectID        `json:"id"`
+	Url                 string                    `json:"url"`
+	EventTypes          []domain.NotificationType `json:"eventTypes"`
+	Active              bool                      `json:"active"`
+	ConsecutiveFailures int                       `json:"consecutiveFailures"`
+	CreatedAt           time.Time                 `json:"createdAt"`
+	DisabledAt          *time.Time                `json:"disabledAt,omitempty"`
+	// Secret is only returned when the webhook is registered.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookAttemptDTO struct {
+	EventId     string                  `json:"eventId"`
+	Type        domain.NotificationType `json:"type"`
+	Attempt     int                     `json:"attempt"`
+	StatusCode  int                     `json:"statusCode,omitempty"`
+	Error       string                  `json:"error,omitempty"`
+	Success     bool                    `json:"success"`
+	DurationMs  int64                   `json:"durationMs"`
+	AttemptedAt time.Time               `json:"attemptedAt"`
+}
+
+func FromWebhooks(webhooks []*domain.Webhook) []WebhookDTO {
+	webhookDTOs := make([]WebhookDTO, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		webhookDTOs = append(webhookDTOs, FromWebhook(webhook))
+	}
+	return webhookDTOs
+}
+
+func FromWebhook(webhook *domain.Webhook) WebhookDTO {
+	dto := WebhookDTO{
+		Id:                  webhook.Id,
+		Url:                 webhook.Url,
+		EventTypes:          webhook.EventTypes,
+		Active:              webhook.Active,
+		ConsecutiveFailures: webhook.ConsecutiveFailures,
+		CreatedAt:           webhook.CreatedAt,
+	}
+	if dto.EventTypes == nil {
+		dto.EventTypes = []domain.NotificationType{}
+	}
+	if !webhook.DisabledAt.IsZero() {
+		dto.DisabledAt = &webhook.DisabledAt
+	}
+	return dto
+}
+
+func FromWebhookAttempts(attempts []*domain.WebhookAttempt) []WebhookAttemptDTO {
+	attemptDTOs := make([]WebhookAttemptDTO, 0, len(attempts))
+	for _, attempt := range attempts {
+		attemptDTOs = append(attemptDTOs, WebhookAttemptDTO{
+			EventId:     attempt.EventId,
+			Type:        attempt.Type,
+			Attempt:     attempt.Attempt,
+			StatusCode:  attempt.StatusCode,
+			Error:       attempt.Error,
+			Success:     attempt.Success,
+			DurationMs:  attempt.Duration.Milliseconds(),
+			AttemptedAt: attempt.AttemptedAt,
+		})
+	}
+	return attemptDTOs
+}
@@ -0,0 +1,151 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ApnsProductionUrl = "https://api.push.apple.com"
+	ApnsSandboxUrl    = "https://api.sandbox.push.apple.com"
+
+	// APNs rejects provider tokens older than an hour and throttles renewing them more often
+	// than every twenty minutes.
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+type ApnsConfig struct {
+	// Key is the PKCS #8 PEM encoded signing key downloaded from the Apple developer account.
+	Key     []byte
+	KeyId   string
+	TeamId  string
+	Topic   string
+	BaseUrl string
+}
+
+// ApnsProvider sends to iOS devices through the Apple Push Notification service, authenticating
+// with token based provider authentication.
+type ApnsProvider struct {
+	key        *ecdsa.PrivateKey
+	keyId      string
+	teamId     string
+	topic      string
+	baseUrl    string
+	httpClient *http.Client
+
+	mutex    sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func NewApnsProvider(config ApnsConfig, httpClient *http.Client) (*ApnsProvider, error) {
+	block, _ := pem.Decode(config.Key)
+	if block == nil {
+		return nil, errors.New("invalid APNs key: no private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APNs key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid APNs key: private key is not an ECDSA key")
+	}
+
+	baseUrl := config.BaseUrl
+	if baseUrl == "" {
+		baseUrl = ApnsProductionUrl
+	}
+	return &ApnsProvider{
+		key:        key,
+		keyId:      config.KeyId,
+		teamId:     config.TeamId,
+		topic:      config.Topic,
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		httpClient: httpClient,
+	}, nil
+}
+
+func (provider *ApnsProvider) Platform() string {
+	return domain.IosPlatform
+}
+
+func (provider *ApnsProvider) Send(token string, message domain.PushMessage) error {
+	providerToken, err := provider.providerToken()
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{"body": message.Body},
+			"sound": "default",
+		},
+	}
+	for name, value := range message.Data {
+		payload[name] = value
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, provider.baseUrl+"/3/device/"+url.PathEscape(token), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "bearer "+providerToken)
+	request.Header.Set("apns-topic", provider.topic)
+	request.Header.Set("apns-push-type", "alert")
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := provider.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var failure struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(response.Body).Decode(&failure)
+	switch {
+	case response.StatusCode == http.StatusGone, failure.Reason == "BadDeviceToken", failure.Reason == "DeviceTokenNotForTopic":
+		return domain.ErrInvalidDeviceToken
+	default:
+		return fmt.Errorf("APNs responded with %s: %s", response.Status, failure.Reason)
+	}
+}
+
+// providerToken returns the cached provider token, signing a new one once it gets too old.
+func (provider *ApnsProvider) providerToken() (string, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	if provider.token != "" && time.Since(provider.issuedAt) < apnsTokenLifetime {
+		return provider.token, nil
+	}
+
+	now := time.Now()
+	token, err := signJwt(provider.key, map[string]interface{}{"kid": provider.keyId}, map[string]interface{}{
+		"iss": provider.teamId,
+		"iat": now.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	provider.token, provider.issuedAt = token, now
+	return token, nil
+}
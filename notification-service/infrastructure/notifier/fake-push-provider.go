@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"log"
+	"sync"
+)
+
+type SentPushMessage struct {
+	Token   string
+	Message domain.PushMessage
+}
+
+// FakePushProvider records the messages it is asked to send instead of sending them, for tests
+// and local development. Tokens marked invalid are rejected like real providers reject them.
+// Tokens and messages are kept in memory only, never logged.
+type FakePushProvider struct {
+	platform      string
+	mutex         sync.Mutex
+	sent          []SentPushMessage
+	invalidTokens map[string]bool
+}
+
+func NewFakePushProvider(platform string) *FakePushProvider {
+	return &FakePushProvider{
+		platform:      platform,
+		invalidTokens: map[string]bool{},
+	}
+}
+
+func (provider *FakePushProvider) Platform() string {
+	return provider.platform
+}
+
+func (provider *FakePushProvider) Send(token string, message domain.PushMessage) error {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	if provider.invalidTokens[token] {
+		return domain.ErrInvalidDeviceToken
+	}
+	log.Printf("Fake %s push recorded instead of being sent", provider.platform)
+	provider.sent = append(provider.sent, SentPushMessage{Token: token, Message: message})
+	return nil
+}
+
+func (provider *FakePushProvider) Invalidate(token string) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.invalidTokens[token] = true
+}
+
+func (provider *FakePushProvider) Sent() []SentPushMessage {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	return append([]SentPushMessage(nil), provider.sent...)
+}
@@ -0,0 +1,36 @@
+package notifier
+
+import (
+	"log"
+	"sync"
+)
+
+type SentSms struct {
+	To   string
+	Body string
+}
+
+// FakeSmsProvider records the messages it is asked to send instead of sending them, for tests
+// and local development. Numbers and messages are kept in memory only, never logged.
+type FakeSmsProvider struct {
+	mutex sync.Mutex
+	sent  []SentSms
+}
+
+func NewFakeSmsProvider() *FakeSmsProvider {
+	return &FakeSmsProvider{}
+}
+
+func (provider *FakeSmsProvider) Send(to string, body string) error {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	log.Printf("Fake SMS recorded instead of being sent")
+	provider.sent = append(provider.sent, SentSms{To: to, Body: body})
+	return nil
+}
+
+func (provider *FakeSmsProvider) Sent() []SentSms {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	return append([]SentSms(nil), provider.sent...)
+}
@@ -0,0 +1,184 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FcmBaseUrl = "https://fcm.googleapis.com"
+
+	fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+	// Access tokens live for an hour, they are renewed a little before they expire.
+	fcmTokenRenewal = time.Minute
+)
+
+type FcmConfig struct {
+	ProjectId string
+	// Credentials is the JSON key of a service account allowed to send messages.
+	Credentials []byte
+	BaseUrl     string
+}
+
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenUri    string `json:"token_uri"`
+}
+
+// FcmProvider sends to Android devices through the Firebase Cloud Messaging HTTP v1 API,
+// authenticating with OAuth access tokens obtained for a service account.
+type FcmProvider struct {
+	projectId  string
+	baseUrl    string
+	account    serviceAccount
+	key        *rsa.PrivateKey
+	httpClient *http.Client
+
+	mutex       sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+func NewFcmProvider(config FcmConfig, httpClient *http.Client) (*FcmProvider, error) {
+	var account serviceAccount
+	if err := json.Unmarshal(config.Credentials, &account); err != nil {
+		return nil, fmt.Errorf("invalid FCM credentials: %w", err)
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, errors.New("invalid FCM credentials: no private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FCM credentials: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid FCM credentials: private key is not an RSA key")
+	}
+
+	baseUrl := config.BaseUrl
+	if baseUrl == "" {
+		baseUrl = FcmBaseUrl
+	}
+	return &FcmProvider{
+		projectId:  config.ProjectId,
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		account:    account,
+		key:        key,
+		httpClient: httpClient,
+	}, nil
+}
+
+func (provider *FcmProvider) Platform() string {
+	return domain.AndroidPlatform
+}
+
+func (provider *FcmProvider) Send(token string, message domain.PushMessage) error {
+	accessToken, err := provider.token()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token":        token,
+			"notification": map[string]string{"body": message.Body},
+			"data":         message.Data,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, provider.baseUrl+"/v1/projects/"+provider.projectId+"/messages:send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := provider.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var failure struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(response.Body).Decode(&failure)
+	if response.StatusCode == http.StatusNotFound {
+		return domain.ErrInvalidDeviceToken
+	}
+	for _, detail := range failure.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return domain.ErrInvalidDeviceToken
+		}
+	}
+	return fmt.Errorf("FCM responded with %s: %s", response.Status, failure.Error.Message)
+}
+
+// token returns a cached access token, exchanging a signed service account assertion for a new
+// one when it is about to expire.
+func (provider *FcmProvider) token() (string, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	if provider.accessToken != "" && time.Now().Before(provider.expiresAt.Add(-fcmTokenRenewal)) {
+		return provider.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := signJwt(provider.key, nil, map[string]interface{}{
+		"iss":   provider.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   provider.account.TokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	response, err := provider.httpClient.PostForm(provider.account.TokenUri, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("FCM token exchange responded with %s", response.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	provider.accessToken = token.AccessToken
+	provider.expiresAt = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return provider.accessToken, nil
+}
@@ -0,0 +1,69 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Error codes of the messaging API for numbers that can not receive SMS.
+var invalidPhoneNumberCodes = map[int]bool{
+	21211: true, // invalid To number
+	21214: true, // To number can not be reached
+	21610: true, // recipient replied STOP
+	21614: true, // To number is not a mobile number
+}
+
+type HttpSmsConfig struct {
+	BaseUrl    string
+	AccountSid string
+	AuthToken  string
+	From       string
+}
+
+// HttpSmsProvider sends SMS through a Twilio compatible messaging API, posting the message as a
+// form to the account's Messages resource with basic authentication.
+type HttpSmsProvider struct {
+	config     HttpSmsConfig
+	httpClient *http.Client
+}
+
+func NewHttpSmsProvider(config HttpSmsConfig, httpClient *http.Client) *HttpSmsProvider {
+	config.BaseUrl = strings.TrimSuffix(config.BaseUrl, "/")
+	return &HttpSmsProvider{
+		config:     config,
+		httpClient: httpClient,
+	}
+}
+
+func (provider *HttpSmsProvider) Send(to string, body string) error {
+	form := url.Values{"To": {to}, "From": {provider.config.From}, "Body": {body}}
+	request, err := http.NewRequest(http.MethodPost, provider.config.BaseUrl+"/2010-04-01/Accounts/"+provider.config.AccountSid+"/Messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(provider.config.AccountSid, provider.config.AuthToken)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := provider.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+
+	var failure struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(response.Body).Decode(&failure)
+	if invalidPhoneNumberCodes[failure.Code] {
+		return fmt.Errorf("%w: %s", domain.ErrInvalidPhoneNumber, failure.Message)
+	}
+	return fmt.Errorf("SMS API responded with %s: %s", response.Status, failure.Message)
+}
@@ -0,0 +1,59 @@
+package notifier
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// signJwt signs the claims with ES256 for ECDSA keys and RS256 for RSA keys. The header's
+// alg and typ are set from the key.
+func signJwt(key crypto.Signer, header, claims map[string]interface{}) (string, error) {
+	fullHeader := map[string]interface{}{"typ": "JWT"}
+	for name, value := range header {
+		fullHeader[name] = value
+	}
+	switch key.(type) {
+	case *ecdsa.PrivateKey:
+		fullHeader["alg"] = "ES256"
+	case *rsa.PrivateKey:
+		fullHeader["alg"] = "RS256"
+	default:
+		return "", fmt.Errorf("unsupported JWT signing key %T", key)
+	}
+
+	encodedHeader, err := json.Marshal(fullHeader)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(encodedClaims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	var signature []byte
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		// JWS uses the fixed size r || s encoding rather than ASN.1.
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return "", err
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			return "", err
+		}
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,80 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"log"
+	"strconv"
+)
+
+// MobilePushNotifier sends notifications to every active device of the recipient through the
+// provider of the device's platform. Devices whose tokens the provider rejects are deactivated.
+// A notification that reached any of the devices is delivered.
+type MobilePushNotifier struct {
+	store     domain.DeviceStore
+	providers map[string]domain.PushProvider
+}
+
+func NewMobilePushNotifier(store domain.DeviceStore, providers ...domain.PushProvider) *MobilePushNotifier {
+	notifier := &MobilePushNotifier{
+		store:     store,
+		providers: map[string]domain.PushProvider{},
+	}
+	for _, provider := range providers {
+		notifier.providers[provider.Platform()] = provider
+	}
+	return notifier
+}
+
+func (notifier *MobilePushNotifier) Channel() domain.Channel {
+	return domain.PushChannel
+}
+
+func (notifier *MobilePushNotifier) Notify(delivery domain.Delivery) error {
+	devices, err := notifier.store.GetActiveByUserId(delivery.Recipient.UserId)
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return domain.ErrMissingContact
+	}
+
+	message := domain.PushMessage{
+		Body: delivery.Message,
+		Data: map[string]string{
+			"notificationId": delivery.NotificationId,
+			"type":           strconv.Itoa(int(delivery.Type)),
+			"redirectId":     delivery.RedirectId,
+		},
+	}
+	var errs []error
+	delivered := 0
+	for _, device := range devices {
+		provider, ok := notifier.providers[device.Platform]
+		if !ok {
+			errs = append(errs, fmt.Errorf("no push provider for platform %s", device.Platform))
+			continue
+		}
+
+		err := provider.Send(device.Token, message)
+		if errors.Is(err, domain.ErrInvalidDeviceToken) {
+			log.Printf("Deactivating %s device of user %s with an invalid token", device.Platform, device.UserId)
+			if deactivateErr := notifier.store.Deactivate(device.Token); deactivateErr != nil {
+				err = errors.Join(err, deactivateErr)
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delivered++
+	}
+	if delivered > 0 {
+		if len(errs) > 0 {
+			log.Printf("Push notification reached %d of %d devices of user %s: %v", delivered, len(devices), delivery.Recipient.UserId, errors.Join(errs...))
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
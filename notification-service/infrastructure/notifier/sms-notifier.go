@@ -0,0 +1,42 @@
+package notifier
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"strings"
+)
+
+// SmsNotifier texts notifications to the recipient's phone number, only when the recipient has
+// opted in to SMS. Messages longer than maxSegments segments are shortened.
+type SmsNotifier struct {
+	provider    domain.SmsProvider
+	maxSegments int
+	appUrl      string
+}
+
+func NewSmsNotifier(provider domain.SmsProvider, maxSegments int, appUrl string) *SmsNotifier {
+	return &SmsNotifier{
+		provider:    provider,
+		maxSegments: maxSegments,
+		appUrl:      appUrl,
+	}
+}
+
+func (notifier *SmsNotifier) Channel() domain.Channel {
+	return domain.SmsChannel
+}
+
+func (notifier *SmsNotifier) Notify(delivery domain.Delivery) error {
+	if delivery.Recipient.Phone == "" {
+		return domain.ErrMissingContact
+	}
+	if !delivery.Recipient.SmsOptIn {
+		return domain.ErrNoConsent
+	}
+
+	link := ""
+	if notifier.appUrl != "" && delivery.RedirectId != "" {
+		link = strings.TrimSuffix(notifier.appUrl, "/") + "/" + delivery.RedirectId
+	}
+	body := composeSms(delivery.Message, link, notifier.maxSegments)
+	return notifier.provider.Send(delivery.Recipient.Phone, body)
+}
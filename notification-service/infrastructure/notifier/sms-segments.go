@@ -0,0 +1,99 @@
+package notifier
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	gsm7Alphabet  = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7Extension = "\f^{}\\[~]|€"
+
+	gsm7SingleLength   = 160
+	gsm7SegmentLength  = 153
+	ucs2SingleLength   = 70
+	ucs2SegmentLength  = 67
+	smsTruncatedSuffix = "..."
+)
+
+// smsLength returns the length of the text in the units of its encoding, septets for texts
+// covered by the GSM 7-bit alphabet and UTF-16 code units for all others, and whether it has
+// to be sent as UCS-2.
+func smsLength(text string) (int, bool) {
+	length := 0
+	for _, character := range text {
+		switch {
+		case strings.ContainsRune(gsm7Alphabet, character):
+			length++
+		case strings.ContainsRune(gsm7Extension, character):
+			length += 2
+		default:
+			return len(utf16.Encode([]rune(text))), true
+		}
+	}
+	return length, false
+}
+
+// smsSegments returns the number of segments the text is split into. Texts that do not fit a
+// single message lose part of every segment to the header that concatenates them.
+func smsSegments(text string) int {
+	length, ucs2 := smsLength(text)
+	single, segment := gsm7SingleLength, gsm7SegmentLength
+	if ucs2 {
+		single, segment = ucs2SingleLength, ucs2SegmentLength
+	}
+	if length <= single {
+		return 1
+	}
+	return (length + segment - 1) / segment
+}
+
+// smsMaxLength returns the most units a text in the encoding may have to fit maxSegments.
+func smsMaxLength(ucs2 bool, maxSegments int) int {
+	single, segment := gsm7SingleLength, gsm7SegmentLength
+	if ucs2 {
+		single, segment = ucs2SingleLength, ucs2SegmentLength
+	}
+	if maxSegments <= 1 {
+		return single
+	}
+	return maxSegments * segment
+}
+
+// composeSms joins the message and the link, shortening the message so the text fits into
+// maxSegments. The link is kept whole so it still opens, or dropped when it leaves no room for
+// the message.
+func composeSms(message, link string, maxSegments int) string {
+	suffix := ""
+	if link != "" {
+		suffix = "\n" + link
+	}
+	if smsSegments(message+suffix) <= maxSegments {
+		return message + suffix
+	}
+
+	_, ucs2 := smsLength(message + suffix)
+	budget := smsMaxLength(ucs2, maxSegments) - unitsIn(smsTruncatedSuffix+suffix, ucs2)
+	if budget <= 0 && link != "" {
+		return composeSms(message, "", maxSegments)
+	}
+	var shortened strings.Builder
+	used := 0
+	for _, character := range message {
+		units := unitsIn(string(character), ucs2)
+		if used+units > budget {
+			break
+		}
+		shortened.WriteRune(character)
+		used += units
+	}
+	return strings.TrimRight(shortened.String(), " ") + smsTruncatedSuffix + suffix
+}
+
+func unitsIn(text string, ucs2 bool) int {
+	if ucs2 {
+		return len(utf16.Encode([]rune(text)))
+	}
+	length, _ := smsLength(text)
+	return length
+}
@@ -0,0 +1,180 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/templates"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	StartTLSSecurity = "starttls"
+	TLSSecurity      = "tls"
+	NoSecurity       = "none"
+
+	smtpTimeout = 10 * time.Second
+)
+
+type SmtpConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// Security is StartTLSSecurity, TLSSecurity for implicit TLS or NoSecurity.
+	Security string
+	// AppUrl is the address of the web app the links in emails lead to.
+	AppUrl string
+}
+
+type SmtpNotifier struct {
+	config    SmtpConfig
+	templates *templates.EmailTemplates
+}
+
+func NewSmtpNotifier(config SmtpConfig, emailTemplates *templates.EmailTemplates) *SmtpNotifier {
+	return &SmtpNotifier{
+		config:    config,
+		templates: emailTemplates,
+	}
+}
+
+func (notifier *SmtpNotifier) Channel() domain.Channel {
+	return domain.EmailChannel
+}
+
+func (notifier *SmtpNotifier) Notify(delivery domain.Delivery) error {
+	if delivery.Recipient.Email == "" {
+		return domain.ErrMissingContact
+	}
+
+	email, err := notifier.templates.Render(delivery.Recipient.Locale, delivery.Type, templates.EmailData{
+		Message: delivery.Message,
+		Link:    strings.TrimSuffix(notifier.config.AppUrl, "/") + "/" + delivery.RedirectId,
+		Payload: delivery.Payload,
+	})
+	if err != nil {
+		return err
+	}
+	message, err := notifier.compose(delivery.Recipient.Email, email)
+	if err != nil {
+		return err
+	}
+	return notifier.send(delivery.Recipient.Email, message)
+}
+
+// compose builds a multipart/alternative message holding the plaintext and the HTML version.
+func (notifier *SmtpNotifier) compose(to string, email templates.Email) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", email.Text},
+		{"text/html; charset=UTF-8", email.Html},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", notifier.config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", email.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+func (notifier *SmtpNotifier) send(to string, message []byte) error {
+	address := net.JoinHostPort(notifier.config.Host, notifier.config.Port)
+	tlsConfig := &tls.Config{ServerName: notifier.config.Host}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	var conn net.Conn
+	var err error
+	if notifier.config.Security == TLSSecurity {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, notifier.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if notifier.config.Security == StartTLSSecurity {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if notifier.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", notifier.config.Username, notifier.config.Password, notifier.config.Host)); err != nil {
+			return err
+		}
+	}
+	from, err := mail.ParseAddress(notifier.config.From)
+	if err != nil {
+		return err
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		var protocolErr *textproto.Error
+		if errors.As(err, &protocolErr) && protocolErr.Code >= 550 && protocolErr.Code <= 553 {
+			return fmt.Errorf("%w: %w", domain.ErrBounced, err)
+		}
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
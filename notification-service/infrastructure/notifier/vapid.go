@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+)
+
+const vapidTokenLifetime = 12 * time.Hour
+
+// VapidKeys identify the service to push services (RFC 8292). The private key is the raw
+// P-256 scalar and the public key, which browsers subscribe with, the uncompressed point.
+type VapidKeys struct {
+	private *ecdsa.PrivateKey
+	public  []byte
+	subject string
+}
+
+// NewVapidKeys parses a base64url encoded private key. The subject is a mailto: or https:
+// contact for the operators of the push services.
+func NewVapidKeys(privateKey, subject string) (*VapidKeys, error) {
+	scalar, err := decodeBase64Url(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(scalar)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+
+	public := key.PublicKey().Bytes()
+	return &VapidKeys{
+		private: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(scalar),
+		},
+		public:  public,
+		subject: subject,
+	}, nil
+}
+
+func (keys *VapidKeys) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(keys.public)
+}
+
+// authorization returns the Authorization header for a request to the endpoint, holding an
+// ES256 signed JWT for the endpoint's origin.
+func (keys *VapidKeys) authorization(endpoint string) (string, error) {
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := signJwt(keys.private, nil, map[string]interface{}{
+		"aud": endpointUrl.Scheme + "://" + endpointUrl.Host,
+		"exp": time.Now().Add(vapidTokenLifetime).Unix(),
+		"sub": keys.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + token + ", k=" + keys.PublicKey(), nil
+}
+
+// decodeBase64Url decodes base64url with or without padding, as browsers and key generators
+// differ in whether they pad.
+func decodeBase64Url(value string) ([]byte, error) {
+	if decoded, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	return base64.URLEncoding.DecodeString(value)
+}
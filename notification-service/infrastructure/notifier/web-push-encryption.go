@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+const (
+	webPushRecordSize = 4096
+	webPushSaltSize   = 16
+)
+
+// encryptWebPush encrypts the payload for a subscription with the aes128gcm content coding of
+// Message Encryption for Web Push (RFC 8291), as a single record.
+func encryptWebPush(payload []byte, p256dh, auth string) ([]byte, error) {
+	subscriberKeyBytes, err := decodeBase64Url(p256dh)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := decodeBase64Url(auth)
+	if err != nil {
+		return nil, err
+	}
+	subscriberKey, err := ecdh.P256().NewPublicKey(subscriberKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload)+17+86 > webPushRecordSize {
+		return nil, errors.New("web push payload is too large")
+	}
+
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := serverKey.ECDH(subscriberKey)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	keyInfo := append([]byte("WebPush: info\x00"), subscriberKeyBytes...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm, err := expand(hkdf.Extract(sha256.New, sharedSecret, authSecret), keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, webPushSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	contentKey, err := expand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := expand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// The delimiter 0x02 marks the last record.
+	record := gcm.Seal(nil, nonce, append(append([]byte{}, payload...), 0x02), nil)
+
+	header := make([]byte, 0, webPushSaltSize+4+1+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+	return append(header, record...), nil
+}
+
+func expand(prk, info []byte, length int) ([]byte, error) {
+	key := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
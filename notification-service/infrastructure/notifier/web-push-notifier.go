@@ -0,0 +1,121 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const webPushTTL = 24 * time.Hour
+
+type webPushPayload struct {
+	NotificationId string                  `json:"notificationId,omitempty"`
+	Type           domain.NotificationType `json:"type"`
+	Message        string                  `json:"message"`
+	Url            string                  `json:"url"`
+}
+
+// WebPushNotifier sends notifications to every browser the recipient subscribed to Web Push
+// with. Subscriptions the push service reports as gone are removed. A notification that reached
+// any of the browsers is delivered.
+type WebPushNotifier struct {
+	store      domain.PushSubscriptionStore
+	keys       *VapidKeys
+	httpClient *http.Client
+	appUrl     string
+}
+
+func NewWebPushNotifier(store domain.PushSubscriptionStore, keys *VapidKeys, httpClient *http.Client, appUrl string) *WebPushNotifier {
+	return &WebPushNotifier{
+		store:      store,
+		keys:       keys,
+		httpClient: httpClient,
+		appUrl:     appUrl,
+	}
+}
+
+func (notifier *WebPushNotifier) Channel() domain.Channel {
+	return domain.PushChannel
+}
+
+func (notifier *WebPushNotifier) Notify(delivery domain.Delivery) error {
+	subscriptions, err := notifier.store.GetByUserId(delivery.Recipient.UserId)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return domain.ErrMissingContact
+	}
+
+	payload, err := json.Marshal(webPushPayload{
+		NotificationId: delivery.NotificationId,
+		Type:           delivery.Type,
+		Message:        delivery.Message,
+		Url:            strings.TrimSuffix(notifier.appUrl, "/") + "/" + delivery.RedirectId,
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	delivered := 0
+	for _, subscription := range subscriptions {
+		if err := notifier.send(subscription, payload); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delivered++
+	}
+	if delivered > 0 {
+		if len(errs) > 0 {
+			log.Printf("Web Push notification reached %d of %d browsers of user %s: %v", delivered, len(subscriptions), delivery.Recipient.UserId, errors.Join(errs...))
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+func (notifier *WebPushNotifier) send(subscription *domain.PushSubscription, payload []byte) error {
+	body, err := encryptWebPush(payload, subscription.P256dh, subscription.Auth)
+	if err != nil {
+		return err
+	}
+	authorization, err := notifier.keys.authorization(subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", authorization)
+	request.Header.Set("Content-Encoding", "aes128gcm")
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.Header.Set("TTL", strconv.Itoa(int(webPushTTL.Seconds())))
+
+	response, err := notifier.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone:
+		log.Printf("Removing expired push subscription of user %s", subscription.UserId)
+		if err := notifier.store.DeleteByEndpoint(subscription.Endpoint); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: push subscription expired", domain.ErrBounced)
+	case response.StatusCode >= 300:
+		return fmt.Errorf("push service responded with %s", response.Status)
+	}
+	return nil
+}
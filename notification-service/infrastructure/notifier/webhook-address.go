@@ -0,0 +1,106 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"time"
+)
+
+var ErrPrivateWebhookAddress = errors.New("webhook address is not public")
+
+// blockedPrefixes are ranges outside of the ones net.IP classifies which must not be reached
+// either: shared address space used inside clusters and the cloud metadata services.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("fd00:ec2::254/128"),
+}
+
+// publicAddress reports whether the address is a public unicast address, so webhooks cannot
+// reach loopback, private, link-local or metadata endpoints of the cluster.
+func publicAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	address, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	address = address.Unmap()
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(address) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWebhookUrl accepts https URLs whose host resolves to public addresses only.
+func CheckWebhookUrl(rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return errors.New("webhook url has to be an https url")
+	}
+	addresses, err := net.DefaultResolver.LookupIPAddr(context.Background(), parsed.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if !publicAddress(address.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateWebhookAddress, parsed.Hostname(), address.IP)
+		}
+	}
+	return nil
+}
+
+// NewWebhookHttpClient returns the client webhooks are posted with. It only connects to public
+// addresses, checked after resolving so a host cannot be pointed at the cluster once registered,
+// and does not follow redirects.
+func NewWebhookHttpClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, resolved := range addresses {
+			if !publicAddress(resolved.IP) {
+				errs = append(errs, fmt.Errorf("%w: %s resolves to %s", ErrPrivateWebhookAddress, host, resolved.IP))
+				continue
+			}
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(resolved.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("no addresses for %s", host)
+		}
+		return nil, errors.Join(errs...)
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,256 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WebhookEventIdHeader   = "X-Webhook-Id"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+type WebhookConfig struct {
+	// MaxAttempts is the number of times an event is posted before the delivery fails.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, it doubles with every further retry
+	// up to MaxBackoff. Waits shorter than the job poll interval last until the next poll.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DisableAfter is the number of consecutive failed deliveries that disables a webhook.
+	DisableAfter int
+	AppUrl       string
+}
+
+type webhookEvent struct {
+	Id             string                 `json:"id"`
+	Type           string                 `json:"type"`
+	CreatedAt      time.Time              `json:"createdAt"`
+	UserId         string                 `json:"userId"`
+	NotificationId string                 `json:"notificationId,omitempty"`
+	Message        string                 `json:"message"`
+	Url            string                 `json:"url,omitempty"`
+	Payload        map[string]interface{} `json:"payload"`
+
+	notificationType domain.NotificationType
+}
+
+// WebhookNotifier posts notifications as JSON events to the recipient's webhooks that receive
+// their type. Every request is signed with the webhook's secret: the signature header holds
+// "sha256=" followed by the hex encoded HMAC-SHA256 of "<timestamp>.<body>", where timestamp is
+// the value of the timestamp header. Failed posts are retried by webhook retry jobs, which keep
+// the event's id so receivers can drop duplicates.
+type WebhookNotifier struct {
+	store      domain.WebhookStore
+	jobs       domain.JobStore
+	httpClient *http.Client
+	config     WebhookConfig
+}
+
+func NewWebhookNotifier(store domain.WebhookStore, jobs domain.JobStore, httpClient *http.Client, config WebhookConfig) *WebhookNotifier {
+	return &WebhookNotifier{
+		store:      store,
+		jobs:       jobs,
+		httpClient: httpClient,
+		config:     config,
+	}
+}
+
+func (notifier *WebhookNotifier) Channel() domain.Channel {
+	return domain.WebhookChannel
+}
+
+// Notify posts the event to all webhooks at once and returns when every webhook has accepted it,
+// failed for good or had a retry scheduled.
+func (notifier *WebhookNotifier) Notify(delivery domain.Delivery) error {
+	webhooks, err := notifier.store.GetActiveByUserId(delivery.Recipient.UserId)
+	if err != nil {
+		return err
+	}
+	var receiving []*domain.Webhook
+	for _, webhook := range webhooks {
+		if webhook.Receives(delivery.Type) {
+			receiving = append(receiving, webhook)
+		}
+	}
+	if len(receiving) == 0 {
+		return domain.ErrMissingContact
+	}
+
+	event := notifier.event(delivery)
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(receiving))
+	for i, webhook := range receiving {
+		wg.Add(1)
+		go func(i int, webhook *domain.Webhook) {
+			defer wg.Done()
+			errs[i] = notifier.deliver(webhook, event.Id, event.notificationType, body, 1)
+		}(i, webhook)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (notifier *WebhookNotifier) event(delivery domain.Delivery) webhookEvent {
+	payload := map[string]interface{}{}
+	for name, value := range map[string]string{
+		"reservationId":   delivery.Payload.ReservationId,
+		"accommodationId": delivery.Payload.AccommodationId,
+		"actorId":         delivery.Payload.ActorId,
+		"actorName":       delivery.Payload.ActorName,
+		"status":          delivery.Payload.Status,
+	} {
+		if value != "" {
+			payload[name] = value
+		}
+	}
+
+	event := webhookEvent{
+		Id:             primitive.NewObjectID().Hex(),
+		Type:           delivery.Type.String(),
+		CreatedAt:      time.Now().UTC(),
+		UserId:         delivery.Recipient.UserId,
+		NotificationId: delivery.NotificationId,
+		Message:        delivery.Message,
+		Payload:        payload,
+
+		notificationType: delivery.Type,
+	}
+	if delivery.RedirectId != "" {
+		event.Url = strings.TrimSuffix(notifier.config.AppUrl, "/") + "/" + delivery.RedirectId
+	}
+	return event
+}
+
+// Retry is the JobHandler of webhook retries. It posts the event again unless the webhook has
+// been deleted or disabled since.
+func (notifier *WebhookNotifier) Retry(job *domain.Job, span trace.Span) (*domain.Job, error) {
+	webhook, err := notifier.store.Get(job.UserId, job.WebhookId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !webhook.Active {
+		return nil, nil
+	}
+	if err := notifier.deliver(webhook, job.EventId, job.Type, job.Body, job.Step+1); err != nil && !errors.Is(err, domain.ErrRetryScheduled) {
+		log.Printf("Failed to deliver event %s to webhook %s: %v", job.EventId, webhook.Id.Hex(), err)
+	}
+	return nil, nil
+}
+
+// deliver makes the given attempt to post the event. When a further attempt is allowed after a
+// failure that may pass, a retry job is scheduled, backing off exponentially. Responses other
+// than server errors, timeouts and throttling are not retried.
+func (notifier *WebhookNotifier) deliver(webhook *domain.Webhook, eventId string, notificationType domain.NotificationType, body []byte, attempt int) error {
+	retryable, err := notifier.post(webhook, eventId, notificationType, body, attempt)
+	if err == nil {
+		return notifier.store.RecordSuccess(webhook.Id)
+	}
+	if retryable && attempt < notifier.config.MaxAttempts {
+		backoff := min(notifier.config.InitialBackoff<<min(attempt-1, 30), notifier.config.MaxBackoff)
+		_, scheduleErr := notifier.jobs.Schedule(&domain.Job{
+			Key:       fmt.Sprintf("%s:%s:%s:%d", domain.WebhookRetryJob, webhook.Id.Hex(), eventId, attempt),
+			Kind:      domain.WebhookRetryJob,
+			UserId:    webhook.UserId,
+			Step:      attempt,
+			Type:      notificationType,
+			WebhookId: webhook.Id,
+			EventId:   eventId,
+			Body:      body,
+			RunAt:     time.Now().Add(backoff),
+			CreatedAt: time.Now(),
+		})
+		if scheduleErr == nil {
+			return fmt.Errorf("webhook %s: %w: %v", webhook.Id.Hex(), domain.ErrRetryScheduled, err)
+		}
+		err = errors.Join(err, scheduleErr)
+	}
+
+	disabled, recordErr := notifier.store.RecordFailure(webhook.Id, notifier.config.DisableAfter)
+	if recordErr != nil {
+		return errors.Join(err, recordErr)
+	}
+	if disabled {
+		log.Printf("Disabled webhook %s of user %s after %d failed deliveries", webhook.Id.Hex(), webhook.UserId, notifier.config.DisableAfter)
+	}
+	return fmt.Errorf("webhook %s: %w", webhook.Id.Hex(), err)
+}
+
+func (notifier *WebhookNotifier) post(webhook *domain.Webhook, eventId string, notificationType domain.NotificationType, body []byte, attempt int) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventIdHeader, eventId)
+	request.Header.Set(WebhookTimestampHeader, timestamp)
+	request.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(webhook.Secret, timestamp, body))
+
+	record := &domain.WebhookAttempt{
+		WebhookId:   webhook.Id,
+		EventId:     eventId,
+		Type:        notificationType,
+		Attempt:     attempt,
+		AttemptedAt: time.Now(),
+	}
+
+	statusCode, retryable, err := notifier.send(request)
+	record.Duration = time.Since(record.AttemptedAt)
+	record.StatusCode = statusCode
+	record.Success = err == nil
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if recordErr := notifier.store.InsertAttempt(record); recordErr != nil {
+		log.Printf("Failed to record attempt of webhook %s: %v", webhook.Id.Hex(), recordErr)
+	}
+	return retryable, err
+}
+
+func (notifier *WebhookNotifier) send(request *http.Request) (int, bool, error) {
+	response, err := notifier.httpClient.Do(request)
+	if err != nil {
+		return 0, true, err
+	}
+	response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response.StatusCode, false, nil
+	}
+
+	retryable := response.StatusCode >= 500 || response.StatusCode == http.StatusRequestTimeout || response.StatusCode == http.StatusTooManyRequests
+	return response.StatusCode, retryable, fmt.Errorf("webhook responded with %s", response.Status)
+}
+
+// SignWebhook returns the hex encoded HMAC-SHA256 signature of a webhook request body sent at
+// the given unix timestamp.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
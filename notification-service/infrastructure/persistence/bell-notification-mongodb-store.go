@@ -6,27 +6,93 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
 )
 
+const NOTIFICATIONS_COLLECTION = "notifications"
+
+var notSet = bson.M{"$exists": false}
+
 type BellNotificationMongoDBStore struct {
 	notifications *mongo.Collection
 }
 
 func NewBellNotificationMongoDBStore(client *mongo.Client) domain.BellNotificationStore {
-	notifications := client.Database("notificationdb").Collection("notifications")
-	return &BellNotificationMongoDBStore{
+	notifications := client.Database(DATABASE).Collection(NOTIFICATIONS_COLLECTION)
+	store := &BellNotificationMongoDBStore{
 		notifications: notifications,
 	}
+	store.ensureIndexes()
+	return store
+}
+
+func (store *BellNotificationMongoDBStore) ensureIndexes() {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "seen", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "type", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "time_stamp", Value: -1}}},
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "seen", Value: 1}, {Key: "time_stamp", Value: 1}}},
+	}
+	if _, err := store.notifications.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		log.Printf("Failed to create notification indexes: %v", err)
+	}
+}
+
+func (store *BellNotificationMongoDBStore) Get(userId string, id primitive.ObjectID) (*domain.BellNotification, error) {
+	return store.filterOne(bson.M{"_id": id, "user_id": userId, "deleted_at": notSet})
+}
+
+func (store *BellNotificationMongoDBStore) GetPageByUserId(userId string, query domain.BellNotificationQuery) ([]*domain.BellNotification, error) {
+	filter := bson.M{"user_id": userId, "deleted_at": notSet, "archived_at": bson.M{"$exists": query.Archived}}
+	if !query.Before.IsZero() {
+		filter["_id"] = bson.M{"$lt": query.Before}
+	}
+	if query.Seen != nil {
+		filter["seen"] = *query.Seen
+	}
+	if len(query.Types) > 0 {
+		filter["type"] = bson.M{"$in": query.Types}
+	}
+	timeRange := bson.M{}
+	if !query.From.IsZero() {
+		timeRange["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timeRange["$lt"] = query.To
+	}
+	if len(timeRange) > 0 {
+		filter["time_stamp"] = timeRange
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(query.Limit)
+
+	return store.filter(filter, opts)
 }
 
-func (store *BellNotificationMongoDBStore) GetAllByUserId(userId string) ([]*domain.BellNotification, error) {
-	filter := bson.M{"user_id": userId}
+func (store *BellNotificationMongoDBStore) GetAllByUserIdAfter(userId string, cursor domain.ReplayCursor, limit int64) ([]*domain.BellNotification, error) {
+	filter := bson.M{"user_id": userId, "deleted_at": notSet}
+	if !cursor.AfterId.IsZero() {
+		filter["_id"] = bson.M{"$gt": cursor.AfterId}
+	} else {
+		filter["time_stamp"] = bson.M{"$gt": cursor.AfterTime}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+
+	return store.filter(filter, opts)
+}
 
-	return store.filter(filter)
+func (store *BellNotificationMongoDBStore) CountUnseen(userId string) (int64, error) {
+	filter := bson.M{"user_id": userId, "seen": false, "deleted_at": notSet, "archived_at": notSet}
+	return store.notifications.CountDocuments(context.TODO(), filter)
 }
 
 func (store *BellNotificationMongoDBStore) Insert(notification *domain.BellNotification) (primitive.ObjectID, error) {
-	notification.Id = primitive.NewObjectID()
+	if notification.Id.IsZero() {
+		notification.Id = primitive.NewObjectID()
+	}
 	result, err := store.notifications.InsertOne(context.TODO(), notification)
 	if err != nil {
 		return primitive.NilObjectID, err
@@ -35,41 +101,114 @@ func (store *BellNotificationMongoDBStore) Insert(notification *domain.BellNotif
 	return notification.Id, nil
 }
 
-func (store *BellNotificationMongoDBStore) UpdateStatus(id primitive.ObjectID, notification *domain.BellNotification) error {
-	filter := bson.M{"_id": id}
-	update := bson.D{
-		{"$set", bson.D{
-			{"seen", notification.Seen},
-		}},
+func (store *BellNotificationMongoDBStore) UpdateStatus(userId string, ids []primitive.ObjectID, seen bool, seenAt time.Time) (int64, error) {
+	filter := bson.M{"_id": bson.M{"$in": ids}, "user_id": userId, "deleted_at": notSet}
+	update := bson.M{"$set": bson.M{"seen": true, "seen_at": seenAt}}
+	if !seen {
+		update = bson.M{"$set": bson.M{"seen": false}, "$unset": bson.M{"seen_at": ""}}
 	}
-	_, err := store.notifications.UpdateOne(context.TODO(), filter, update)
+	return store.updateAll(filter, update, ids)
+}
+
+func (store *BellNotificationMongoDBStore) UpdateManyStatus(userId string, seenAt time.Time) error {
+	filter := bson.M{"user_id": userId, "seen": false}
+	update := bson.M{"$set": bson.M{"seen": true, "seen_at": seenAt}}
+	_, err := store.notifications.UpdateMany(context.TODO(), filter, update)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (store *BellNotificationMongoDBStore) UpdateManyStatus(userId string) error {
-	filter := bson.M{"user_id": userId}
-	update := bson.D{
-		{"$set", bson.D{
-			{"seen", true},
-		}},
+func (store *BellNotificationMongoDBStore) Archive(userId string, ids []primitive.ObjectID, archivedAt time.Time) (int64, error) {
+	filter := bson.M{"_id": bson.M{"$in": ids}, "user_id": userId, "deleted_at": notSet}
+	update := bson.M{"$set": bson.M{"archived_at": archivedAt}}
+	return store.updateAll(filter, update, ids)
+}
+
+func (store *BellNotificationMongoDBStore) Delete(userId string, ids []primitive.ObjectID, deletedAt time.Time) (int64, error) {
+	filter := bson.M{"_id": bson.M{"$in": ids}, "user_id": userId, "deleted_at": notSet}
+	update := bson.M{"$set": bson.M{"deleted_at": deletedAt}}
+	return store.updateAll(filter, update, ids)
+}
+
+// Restore brings back archived notifications and notifications deleted after the given time,
+// so deletions older than the undo window can no longer be reverted.
+func (store *BellNotificationMongoDBStore) Restore(userId string, ids []primitive.ObjectID, deletedAfter time.Time) (int64, error) {
+	filter := bson.M{
+		"_id":     bson.M{"$in": ids},
+		"user_id": userId,
+		"$or": bson.A{
+			bson.M{"deleted_at": bson.M{"$gte": deletedAfter}},
+			bson.M{"deleted_at": notSet, "archived_at": bson.M{"$exists": true}},
+		},
 	}
-	_, err := store.notifications.UpdateMany(context.TODO(), filter, update)
+	// Deleted notifications come back as they were, so those deleted from the archive stay in it.
+	update := bson.A{bson.M{"$set": bson.M{
+		"deleted_at":  "$$REMOVE",
+		"archived_at": bson.M{"$cond": bson.A{bson.M{"$ifNull": bson.A{"$deleted_at", false}}, "$archived_at", "$$REMOVE"}},
+	}}}
+	return store.updateAll(filter, update, ids)
+}
+
+// updateAll applies the update only if the filter matches every one of the ids, so a request
+// naming a notification which does not exist or is in another state changes none of them. It
+// returns the number of matched notifications.
+func (store *BellNotificationMongoDBStore) updateAll(filter bson.M, update interface{}, ids []primitive.ObjectID) (int64, error) {
+	matched, err := store.notifications.CountDocuments(context.TODO(), filter)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	if matched != int64(len(ids)) {
+		return matched, nil
+	}
+	result, err := store.notifications.UpdateMany(context.TODO(), filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
 }
 
-func (store *BellNotificationMongoDBStore) filter(filter interface{}) ([]*domain.BellNotification, error) {
-	cursor, err := store.notifications.Find(context.TODO(), filter)
-	defer cursor.Close(context.TODO())
+func (store *BellNotificationMongoDBStore) PurgeDeleted(deletedBefore time.Time) (int64, error) {
+	filter := bson.M{"deleted_at": bson.M{"$lt": deletedBefore}}
+	result, err := store.notifications.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (store *BellNotificationMongoDBStore) CountExpired(filter domain.RetentionFilter) (int64, error) {
+	return store.notifications.CountDocuments(context.TODO(), retentionFilter(filter))
+}
 
+func (store *BellNotificationMongoDBStore) DeleteExpired(filter domain.RetentionFilter) (int64, error) {
+	result, err := store.notifications.DeleteMany(context.TODO(), retentionFilter(filter))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func retentionFilter(filter domain.RetentionFilter) bson.M {
+	types := bson.M{"$in": filter.Types}
+	if filter.ExcludeTypes {
+		types = bson.M{"$nin": filter.Types}
+	}
+	return bson.M{
+		"type":       types,
+		"seen":       filter.Seen,
+		"time_stamp": bson.M{"$lt": filter.CreatedBefore},
+	}
+}
+
+func (store *BellNotificationMongoDBStore) filter(filter interface{}, opts ...*options.FindOptions) ([]*domain.BellNotification, error) {
+	cursor, err := store.notifications.Find(context.TODO(), filter, opts...)
 	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(context.TODO())
+
 	return store.decode(cursor)
 }
 
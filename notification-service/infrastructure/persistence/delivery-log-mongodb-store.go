@@ -0,0 +1,100 @@
+package persistence
+
+import (
+	"context"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+const (
+	DELIVERY_LOG_COLLECTION = "delivery_log"
+
+	// deliveryLogRetention bounds how long support can look back at deliveries.
+	deliveryLogRetention = 90 * 24 * time.Hour
+)
+
+type DeliveryLogMongoDBStore struct {
+	records *mongo.Collection
+}
+
+func NewDeliveryLogMongoDBStore(client *mongo.Client) domain.DeliveryLogStore {
+	records := client.Database(DATABASE).Collection(DELIVERY_LOG_COLLECTION)
+	store := &DeliveryLogMongoDBStore{
+		records: records,
+	}
+	store.ensureIndexes()
+	return store
+}
+
+func (store *DeliveryLogMongoDBStore) ensureIndexes() {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "notification_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(deliveryLogRetention.Seconds()))},
+	}
+	if _, err := store.records.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		log.Printf("Failed to create delivery log indexes: %v", err)
+	}
+}
+
+func (store *DeliveryLogMongoDBStore) Insert(records []*domain.DeliveryRecord) error {
+	documents := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		record.Id = primitive.NewObjectID()
+		documents = append(documents, record)
+	}
+	_, err := store.records.InsertMany(context.TODO(), documents)
+	return err
+}
+
+func (store *DeliveryLogMongoDBStore) Complete(id primitive.ObjectID, status domain.DeliveryStatus, errorMessage string, completedAt time.Time) error {
+	set := bson.M{"status": status, "updated_at": completedAt}
+	update := bson.M{"$set": set}
+	if errorMessage != "" {
+		set["error"] = errorMessage
+	} else {
+		update["$unset"] = bson.M{"error": ""}
+	}
+	_, err := store.records.UpdateOne(context.TODO(), bson.M{"_id": id}, update)
+	return err
+}
+
+func (store *DeliveryLogMongoDBStore) GetByNotificationId(userId string, notificationId primitive.ObjectID) ([]*domain.DeliveryRecord, error) {
+	filter := bson.M{"user_id": userId, "notification_id": notificationId}
+	return store.filter(filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+}
+
+func (store *DeliveryLogMongoDBStore) GetPageByUserId(userId string, query domain.DeliveryLogQuery) ([]*domain.DeliveryRecord, error) {
+	filter := bson.M{"user_id": userId}
+	if !query.Before.IsZero() {
+		filter["_id"] = bson.M{"$lt": query.Before}
+	}
+	if query.Channel != "" {
+		filter["channel"] = query.Channel
+	}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(query.Limit)
+
+	return store.filter(filter, opts)
+}
+
+func (store *DeliveryLogMongoDBStore) filter(filter interface{}, opts *options.FindOptions) ([]*domain.DeliveryRecord, error) {
+	cursor, err := store.records.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	records := []*domain.DeliveryRecord{}
+	if err := cursor.All(context.TODO(), &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,81 @@
+package persistence
+
+import (
+	"context"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+)
+
+const DEVICES_COLLECTION = "devices"
+
+type DeviceMongoDBStore struct {
+	devices *mongo.Collection
+}
+
+func NewDeviceMongoDBStore(client *mongo.Client) domain.DeviceStore {
+	devices := client.Database(DATABASE).Collection(DEVICES_COLLECTION)
+	store := &DeviceMongoDBStore{
+		devices: devices,
+	}
+	store.ensureIndexes()
+	return store
+}
+
+func (store *DeviceMongoDBStore) ensureIndexes() {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "active", Value: 1}}},
+	}
+	if _, err := store.devices.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		log.Printf("Failed to create device indexes: %v", err)
+	}
+}
+
+func (store *DeviceMongoDBStore) GetActiveByUserId(userId string) ([]*domain.Device, error) {
+	cursor, err := store.devices.Find(context.TODO(), bson.M{"user_id": userId, "active": true})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	devices := []*domain.Device{}
+	if err := cursor.All(context.TODO(), &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// Upsert stores the device by its token and reactivates it. A token registered again, possibly
+// by another user signed in on the device, moves to that user.
+func (store *DeviceMongoDBStore) Upsert(device *domain.Device) error {
+	filter := bson.M{"token": device.Token}
+	update := bson.M{
+		"$set": bson.M{
+			"user_id":     device.UserId,
+			"platform":    device.Platform,
+			"app_version": device.AppVersion,
+			"last_seen":   device.LastSeen,
+			"active":      true,
+		},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+	_, err := store.devices.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (store *DeviceMongoDBStore) Delete(userId, token string) (int64, error) {
+	result, err := store.devices.DeleteOne(context.TODO(), bson.M{"user_id": userId, "token": token})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (store *DeviceMongoDBStore) Deactivate(token string) error {
+	_, err := store.devices.UpdateOne(context.TODO(), bson.M{"token": token}, bson.M{"$set": bson.M{"active": false}})
+	return err
+}
@@ -0,0 +1,72 @@
+package persistence
+
+import (
+	"context"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+const (
+	DIGEST_EVENTS_COLLECTION = "digest_events"
+
+	// digestEventRetention drops events whose digest was never delivered, well after the longest
+	// digest period.
+	digestEventRetention = 30 * 24 * time.Hour
+)
+
+type DigestEventMongoDBStore struct {
+	events *mongo.Collection
+}
+
+func NewDigestEventMongoDBStore(client *mongo.Client) domain.DigestEventStore {
+	events := client.Database(DATABASE).Collection(DIGEST_EVENTS_COLLECTION)
+	store := &DigestEventMongoDBStore{
+		events: events,
+	}
+	store.ensureIndexes()
+	return store
+}
+
+func (store *DigestEventMongoDBStore) ensureIndexes() {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "digest_key", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(digestEventRetention.Seconds()))},
+	}
+	if _, err := store.events.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		log.Printf("Failed to create digest event indexes: %v", err)
+	}
+}
+
+func (store *DigestEventMongoDBStore) Insert(event *domain.DigestEvent) error {
+	event.Id = primitive.NewObjectID()
+	_, err := store.events.InsertOne(context.TODO(), event)
+	return err
+}
+
+func (store *DigestEventMongoDBStore) GetByDigestKey(key string) ([]*domain.DigestEvent, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := store.events.Find(context.TODO(), bson.M{"digest_key": key}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	events := []*domain.DigestEvent{}
+	if err := cursor.All(context.TODO(), &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (store *DigestEventMongoDBStore) DeleteByDigestKey(key string, until time.Time) (int64, error) {
+	result, err := store.events.DeleteMany(context.TODO(), bson.M{"digest_key": key, "created_at": bson.M{"$lte": until}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
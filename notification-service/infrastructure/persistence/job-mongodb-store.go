@@ -0,0 +1,113 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+const JOBS_COLLECTION = "jobs"
+
+type JobMongoDBStore struct {
+	jobs *mongo.Collection
+}
+
+func NewJobMongoDBStore(client *mongo.Client) domain.JobStore {
+	jobs := client.Database(DATABASE).Collection(JOBS_COLLECTION)
+	store := &JobMongoDBStore{
+		jobs: jobs,
+	}
+	store.ensureIndexes()
+	return store
+}
+
+func (store *JobMongoDBStore) ensureIndexes() {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "run_at", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "kind", Value: 1}}},
+	}
+	if _, err := store.jobs.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		log.Printf("Failed to create job indexes: %v", err)
+	}
+}
+
+func (store *JobMongoDBStore) Schedule(job *domain.Job) (bool, error) {
+	job.Id = primitive.NewObjectID()
+	_, err := store.jobs.InsertOne(context.TODO(), job)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Claim atomically takes the earliest due job which is not leased to another replica, so each
+// run of a job happens on a single replica.
+func (store *JobMongoDBStore) Claim(owner string, now time.Time, lease time.Duration) (*domain.Job, error) {
+	filter := bson.M{
+		"run_at": bson.M{"$lte": now},
+		"$or":    bson.A{bson.M{"locked_until": notSet}, bson.M{"locked_until": bson.M{"$lte": now}}},
+	}
+	update := bson.M{
+		"$set": bson.M{"locked_by": owner, "locked_until": now.Add(lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "run_at", Value: 1}}).SetReturnDocument(options.After)
+
+	var job domain.Job
+	err := store.jobs.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (store *JobMongoDBStore) ExtendLease(job *domain.Job, owner string, until time.Time) error {
+	_, err := store.jobs.UpdateOne(context.TODO(), bson.M{"_id": job.Id, "locked_by": owner}, bson.M{"$set": bson.M{"locked_until": until}})
+	return err
+}
+
+func (store *JobMongoDBStore) Reschedule(job *domain.Job, owner string) error {
+	set := bson.M{"run_at": job.RunAt, "step": job.Step, "attempts": job.Attempts}
+	unset := bson.M{"locked_by": "", "locked_until": ""}
+	if job.LastError != "" {
+		set["last_error"] = job.LastError
+	} else {
+		unset["last_error"] = ""
+	}
+	_, err := store.jobs.UpdateOne(context.TODO(), bson.M{"_id": job.Id, "locked_by": owner}, bson.M{"$set": set, "$unset": unset})
+	return err
+}
+
+func (store *JobMongoDBStore) Complete(job *domain.Job, owner string) error {
+	_, err := store.jobs.DeleteOne(context.TODO(), bson.M{"_id": job.Id, "locked_by": owner})
+	return err
+}
+
+func (store *JobMongoDBStore) CancelByKeys(keys []string) (int64, error) {
+	result, err := store.jobs.DeleteMany(context.TODO(), bson.M{"key": bson.M{"$in": keys}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (store *JobMongoDBStore) CancelByUserId(userId string, kind domain.JobKind) (int64, error) {
+	result, err := store.jobs.DeleteMany(context.TODO(), bson.M{"user_id": userId, "kind": kind})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
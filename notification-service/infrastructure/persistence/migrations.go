@@ -0,0 +1,160 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	MIGRATIONS_COLLECTION = "migrations"
+	migrationBatchSize    = 500
+	// migrationClaimTimeout is how long a claimed migration may run before another replica takes
+	// it over, assuming the replica which claimed it died.
+	migrationClaimTimeout = 30 * time.Minute
+)
+
+type migration struct {
+	name string
+	run  func(database *mongo.Database) error
+}
+
+var migrations = []migration{
+	{name: "bell-notification-type-and-payload", run: migrateBellNotificationTypeAndPayload},
+}
+
+// RunMigrations applies the migrations which have not been applied yet. A migration is claimed
+// by inserting its record first, so when several replicas start at once only one of them runs it.
+// A claim which has not finished within migrationClaimTimeout is taken over, so a replica dying
+// mid-migration does not leave it unapplied. Migrations are idempotent, so running one again is
+// safe.
+func RunMigrations(client *mongo.Client) error {
+	database := client.Database(DATABASE)
+	applied := database.Collection(MIGRATIONS_COLLECTION)
+	for _, m := range migrations {
+		claimed, err := claimMigration(applied, m.name, time.Now())
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := m.run(database); err != nil {
+			_, _ = applied.DeleteOne(context.TODO(), bson.M{"_id": m.name})
+			return fmt.Errorf("migration %s failed: %w", m.name, err)
+		}
+		_, _ = applied.UpdateOne(context.TODO(), bson.M{"_id": m.name}, bson.M{"$set": bson.M{"finished_at": time.Now()}})
+		log.Printf("Applied migration %s", m.name)
+	}
+	return nil
+}
+
+// claimMigration claims the migration unless it is applied or claimed by a replica within the
+// claim timeout.
+func claimMigration(applied *mongo.Collection, name string, now time.Time) (bool, error) {
+	_, err := applied.InsertOne(context.TODO(), bson.M{"_id": name, "started_at": now})
+	if err == nil {
+		return true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return false, err
+	}
+
+	filter := bson.M{
+		"_id":         name,
+		"finished_at": bson.M{"$exists": false},
+		"started_at":  bson.M{"$lt": now.Add(-migrationClaimTimeout)},
+	}
+	result, err := applied.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"started_at": now}})
+	if err != nil {
+		return false, err
+	}
+	if result.ModifiedCount == 1 {
+		log.Printf("Taking over stale migration %s", name)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+type legacyBellNotification struct {
+	Id         primitive.ObjectID       `bson:"_id"`
+	Type       *domain.NotificationType `bson:"type"`
+	Message    string                   `bson:"message"`
+	RedirectId string                   `bson:"redirect_id"`
+}
+
+// migrateBellNotificationTypeAndPayload fills in the type and payload of notifications stored
+// before they were persisted, recovering them from the redirect id and the rendered message.
+func migrateBellNotificationTypeAndPayload(database *mongo.Database) error {
+	notifications := database.Collection(NOTIFICATIONS_COLLECTION)
+	cursor, err := notifications.Find(context.TODO(), bson.M{"payload": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	var models []mongo.WriteModel
+	for cursor.Next(context.TODO()) {
+		var legacy legacyBellNotification
+		if err := cursor.Decode(&legacy); err != nil {
+			return err
+		}
+
+		notificationType, payload := InferTypeAndPayload(legacy.Message, legacy.RedirectId)
+		if legacy.Type != nil {
+			notificationType = *legacy.Type
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": legacy.Id}).
+			SetUpdate(bson.M{"$set": bson.M{"type": notificationType, "payload": payload}}))
+
+		if len(models) == migrationBatchSize {
+			if _, err := notifications.BulkWrite(context.TODO(), models); err != nil {
+				return err
+			}
+			models = nil
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if len(models) > 0 {
+		_, err = notifications.BulkWrite(context.TODO(), models)
+	}
+	return err
+}
+
+// InferTypeAndPayload recovers the type and payload of a notification stored before they were
+// persisted from its rendered message and redirect id.
+func InferTypeAndPayload(message, redirectId string) (domain.NotificationType, domain.NotificationPayload) {
+	var payload domain.NotificationPayload
+	switch {
+	case strings.HasPrefix(redirectId, "auth/view-profile/"):
+		payload.ActorName, _, _ = strings.Cut(message, " has reviewed your profile")
+		return domain.NewHostReview, payload
+	case strings.HasPrefix(redirectId, "accommodation/"):
+		payload.AccommodationId = strings.TrimPrefix(redirectId, "accommodation/")
+		payload.ActorName, _, _ = strings.Cut(message, " has reviewed your accommodation")
+		return domain.NewAccommodationReview, payload
+	}
+
+	payload.ReservationId = strings.TrimPrefix(redirectId, domain.ReservationRedirectUrlStart)
+	switch {
+	case strings.Contains(message, "has been cancelled"):
+		return domain.CancelReservation, payload
+	case strings.HasPrefix(message, "The host has been confirmed"):
+		payload.Status = "accept-request"
+		return domain.ReviewReservation, payload
+	case strings.HasPrefix(message, "The host has been canceled"):
+		return domain.ReviewReservation, payload
+	case strings.Contains(message, "automatically accepted"):
+		payload.Status = "automatic"
+	}
+	return domain.NewReservationRequest, payload
+}
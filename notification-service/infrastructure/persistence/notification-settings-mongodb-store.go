@@ -52,12 +52,45 @@ func (store *NotificationSettingsMongoDBStore) DeleteAll() {
 	store.settings.DeleteMany(context.TODO(), bson.D{{}})
 }
 
+// Update and the other updates each set only the fields their endpoint changes, so concurrent
+// changes to the other fields are not overwritten.
 func (store *NotificationSettingsMongoDBStore) Update(id primitive.ObjectID, notificationSettings *domain.Settings) error {
+	return store.set(id, bson.D{
+		{"settings", notificationSettings.Settings},
+	})
+}
+
+func (store *NotificationSettingsMongoDBStore) UpdateLocale(id primitive.ObjectID, locale string) error {
+	return store.set(id, bson.D{
+		{"locale", locale},
+	})
+}
+
+func (store *NotificationSettingsMongoDBStore) UpdateEmail(id primitive.ObjectID, email string) error {
+	return store.set(id, bson.D{
+		{"email", email},
+	})
+}
+
+func (store *NotificationSettingsMongoDBStore) UpdatePhone(id primitive.ObjectID, notificationSettings *domain.Settings) error {
+	return store.set(id, bson.D{
+		{"phone", notificationSettings.Phone},
+		{"sms_opt_in", notificationSettings.SmsOptIn},
+		{"sms_opt_in_at", notificationSettings.SmsOptInAt},
+	})
+}
+
+func (store *NotificationSettingsMongoDBStore) UpdateQuietHours(id primitive.ObjectID, notificationSettings *domain.Settings) error {
+	return store.set(id, bson.D{
+		{"time_zone", notificationSettings.TimeZone},
+		{"quiet_hours", notificationSettings.QuietHours},
+	})
+}
+
+func (store *NotificationSettingsMongoDBStore) set(id primitive.ObjectID, fields bson.D) error {
 	filter := bson.M{"_id": id}
 	update := bson.D{
-		{"$set", bson.D{
-			{"settings", notificationSettings.Settings},
-		}},
+		{"$set", fields},
 	}
 	_, err := store.settings.UpdateOne(context.TODO(), filter, update)
 	if err != nil {
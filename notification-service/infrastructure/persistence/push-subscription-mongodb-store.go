@@ -0,0 +1,81 @@
+package persistence
+
+import (
+	"context"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+)
+
+const PUSH_SUBSCRIPTIONS_COLLECTION = "push_subscriptions"
+
+type PushSubscriptionMongoDBStore struct {
+	subscriptions *mongo.Collection
+}
+
+func NewPushSubscriptionMongoDBStore(client *mongo.Client) domain.PushSubscriptionStore {
+	subscriptions := client.Database(DATABASE).Collection(PUSH_SUBSCRIPTIONS_COLLECTION)
+	store := &PushSubscriptionMongoDBStore{
+		subscriptions: subscriptions,
+	}
+	store.ensureIndexes()
+	return store
+}
+
+func (store *PushSubscriptionMongoDBStore) ensureIndexes() {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "endpoint", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	}
+	if _, err := store.subscriptions.Indexes().CreateMany(context.TODO(), indexes); err != nil {
+		log.Printf("Failed to create push subscription indexes: %v", err)
+	}
+}
+
+func (store *PushSubscriptionMongoDBStore) GetByUserId(userId string) ([]*domain.PushSubscription, error) {
+	cursor, err := store.subscriptions.Find(context.TODO(), bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	subscriptions := []*domain.PushSubscription{}
+	if err := cursor.All(context.TODO(), &subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// Upsert stores the subscription by its endpoint. A browser that subscribes again, possibly for
+// another user signed in on it, replaces its previous subscription.
+func (store *PushSubscriptionMongoDBStore) Upsert(subscription *domain.PushSubscription) error {
+	filter := bson.M{"endpoint": subscription.Endpoint}
+	update := bson.M{
+		"$set": bson.M{
+			"user_id":    subscription.UserId,
+			"p256dh":     subscription.P256dh,
+			"auth":       subscription.Auth,
+			"user_agent": subscription.UserAgent,
+			"created_at": subscription.CreatedAt,
+		},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+	_, err := store.subscriptions.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (store *PushSubscriptionMongoDBStore) Delete(userId, endpoint string) (int64, error) {
+	result, err := store.subscriptions.DeleteOne(context.TODO(), bson.M{"user_id": userId, "endpoint": endpoint})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (store *PushSubscriptionMongoDBStore) DeleteByEndpoint(endpoint string) error {
+	_, err := store.subscriptions.DeleteOne(context.TODO(), bson.M{"endpoint": endpoint})
+	return err
+}
@@ -0,0 +1,157 @@
+package persistence
+
+import (
+	"context"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+const (
+	WEBHOOKS_COLLECTION         = "webhooks"
+	WEBHOOK_ATTEMPTS_COLLECTION = "webhook_attempts"
+
+	// webhookAttemptRetention bounds the delivery history kept for every webhook.
+	webhookAttemptRetention = 30 * 24 * time.Hour
+)
+
+type WebhookMongoDBStore struct {
+	webhooks *mongo.Collection
+	attempts *mongo.Collection
+}
+
+func NewWebhookMongoDBStore(client *mongo.Client) domain.WebhookStore {
+	database := client.Database(DATABASE)
+	store := &WebhookMongoDBStore{
+		webhooks: database.Collection(WEBHOOKS_COLLECTION),
+		attempts: database.Collection(WEBHOOK_ATTEMPTS_COLLECTION),
+	}
+	store.ensureIndexes()
+	return store
+}
+
+func (store *WebhookMongoDBStore) ensureIndexes() {
+	if _, err := store.webhooks.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "active", Value: 1}},
+	}); err != nil {
+		log.Printf("Failed to create webhook indexes: %v", err)
+	}
+	attemptIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "attempted_at", Value: -1}}},
+		{Keys: bson.D{{Key: "attempted_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(webhookAttemptRetention.Seconds()))},
+	}
+	if _, err := store.attempts.Indexes().CreateMany(context.TODO(), attemptIndexes); err != nil {
+		log.Printf("Failed to create webhook attempt indexes: %v", err)
+	}
+}
+
+func (store *WebhookMongoDBStore) Get(userId string, id primitive.ObjectID) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	if err := store.webhooks.FindOne(context.TODO(), bson.M{"_id": id, "user_id": userId}).Decode(&webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (store *WebhookMongoDBStore) GetByUserId(userId string) ([]*domain.Webhook, error) {
+	return store.filter(bson.M{"user_id": userId})
+}
+
+func (store *WebhookMongoDBStore) GetActiveByUserId(userId string) ([]*domain.Webhook, error) {
+	return store.filter(bson.M{"user_id": userId, "active": true})
+}
+
+func (store *WebhookMongoDBStore) filter(filter interface{}) ([]*domain.Webhook, error) {
+	cursor, err := store.webhooks.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	webhooks := []*domain.Webhook{}
+	if err := cursor.All(context.TODO(), &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (store *WebhookMongoDBStore) Insert(webhook *domain.Webhook) error {
+	webhook.Id = primitive.NewObjectID()
+	_, err := store.webhooks.InsertOne(context.TODO(), webhook)
+	return err
+}
+
+// Delete removes the webhook together with its delivery history.
+func (store *WebhookMongoDBStore) Delete(userId string, id primitive.ObjectID) (int64, error) {
+	result, err := store.webhooks.DeleteOne(context.TODO(), bson.M{"_id": id, "user_id": userId})
+	if err != nil || result.DeletedCount == 0 {
+		return 0, err
+	}
+	if _, err := store.attempts.DeleteMany(context.TODO(), bson.M{"webhook_id": id}); err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (store *WebhookMongoDBStore) Enable(userId string, id primitive.ObjectID) (int64, error) {
+	update := bson.M{
+		"$set":   bson.M{"active": true, "consecutive_failures": 0},
+		"$unset": bson.M{"disabled_at": ""},
+	}
+	result, err := store.webhooks.UpdateOne(context.TODO(), bson.M{"_id": id, "user_id": userId}, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+func (store *WebhookMongoDBStore) RecordSuccess(id primitive.ObjectID) error {
+	_, err := store.webhooks.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"consecutive_failures": 0}})
+	return err
+}
+
+func (store *WebhookMongoDBStore) RecordFailure(id primitive.ObjectID, disableAfter int) (bool, error) {
+	var webhook domain.Webhook
+	err := store.webhooks.FindOneAndUpdate(context.TODO(), bson.M{"_id": id},
+		bson.M{"$inc": bson.M{"consecutive_failures": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&webhook)
+	if err != nil {
+		return false, err
+	}
+	if !webhook.Active || webhook.ConsecutiveFailures < disableAfter {
+		return false, nil
+	}
+
+	update := bson.M{"$set": bson.M{"active": false, "disabled_at": time.Now()}}
+	result, err := store.webhooks.UpdateOne(context.TODO(), bson.M{"_id": id, "active": true}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (store *WebhookMongoDBStore) InsertAttempt(attempt *domain.WebhookAttempt) error {
+	attempt.Id = primitive.NewObjectID()
+	_, err := store.attempts.InsertOne(context.TODO(), attempt)
+	return err
+}
+
+// GetAttempts returns the latest delivery attempts of the webhook, newest first.
+func (store *WebhookMongoDBStore) GetAttempts(webhookId primitive.ObjectID, limit int64) ([]*domain.WebhookAttempt, error) {
+	findOptions := options.Find().SetSort(bson.D{{Key: "attempted_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit)
+	cursor, err := store.attempts.Find(context.TODO(), bson.M{"webhook_id": webhookId}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	attempts := []*domain.WebhookAttempt{}
+	if err := cursor.All(context.TODO(), &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
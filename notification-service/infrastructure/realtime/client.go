@@ -0,0 +1,147 @@
+package realtime
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"log"
+	"time"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	maxMessageSize = 4096
+	sendQueueSize  = 64
+)
+
+// Client is a single WebSocket connection of a user. Only writePump writes to the
+// connection and only readPump reads from it, as required by gorilla/websocket.
+type Client struct {
+	hub          *Hub
+	conn         *websocket.Conn
+	subscription *subscription
+	commands     CommandHandler
+}
+
+func newClient(hub *Hub, conn *websocket.Conn, sub *subscription, commands CommandHandler) *Client {
+	return &Client{
+		hub:          hub,
+		conn:         conn,
+		subscription: sub,
+		commands:     commands,
+	}
+}
+
+func (client *Client) readPump() {
+	defer func() {
+		client.hub.unregister <- client.subscription
+		client.conn.Close()
+	}()
+
+	client.conn.SetReadLimit(maxMessageSize)
+	_ = client.conn.SetReadDeadline(time.Now().Add(client.hub.pongWait))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(client.hub.pongWait))
+	})
+
+	for {
+		_, data, err := client.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket connection of user %s closed unexpectedly: %v", client.subscription.userId, err)
+			}
+			return
+		}
+		client.handleCommand(data)
+	}
+}
+
+func (client *Client) handleCommand(data []byte) {
+	var command Envelope
+	if err := json.Unmarshal(data, &command); err != nil {
+		client.replyError(command, "Invalid message, expected JSON envelope")
+		return
+	}
+	if command.Version != ProtocolVersion {
+		client.replyError(command, "Unsupported protocol version")
+		return
+	}
+
+	if command.Type == SubscribeCommand {
+		var payload SubscribePayload
+		if err := json.Unmarshal(command.Payload, &payload); err != nil {
+			client.replyError(command, "Invalid subscribe payload")
+			return
+		}
+		client.subscription.subscribeTo(payload.Events)
+		return
+	}
+
+	if client.commands == nil {
+		client.replyError(command, "Commands are not supported")
+		return
+	}
+	if err := client.commands.HandleCommand(client.subscription.userId, command); err != nil {
+		client.replyError(command, err.Error())
+	}
+}
+
+func (client *Client) replyError(command Envelope, message string) {
+	client.hub.reply <- reply{subscription: client.subscription, message: newErrorMessage(command, message)}
+}
+
+func (client *Client) writePump(replayed []Message) {
+	ticker := time.NewTicker(client.hub.pingPeriod())
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
+	pending, open, err := client.subscription.replay(replayed, client.write)
+	if err != nil {
+		log.Printf("Failed to replay messages to WebSocket connection of user %s: %v", client.subscription.userId, err)
+		return
+	}
+	skip := replayedIds(replayed)
+	for _, message := range pending {
+		if _, ok := skip[message.Id]; ok || !client.subscription.wants(message) {
+			continue
+		}
+		if err := client.write(message); err != nil {
+			log.Printf("Failed to write to WebSocket connection of user %s: %v", client.subscription.userId, err)
+			return
+		}
+	}
+	if !open {
+		_ = client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		_ = client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+		return
+	}
+
+	for {
+		select {
+		case message, ok := <-client.subscription.send:
+			if !ok {
+				_ = client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				_ = client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if _, ok := skip[message.Id]; ok || !client.subscription.wants(message) {
+				continue
+			}
+			if err := client.write(message); err != nil {
+				log.Printf("Failed to write to WebSocket connection of user %s: %v", client.subscription.userId, err)
+				return
+			}
+		case <-ticker.C:
+			_ = client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (client *Client) write(message Message) error {
+	_ = client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return client.conn.WriteMessage(websocket.TextMessage, message.Data)
+}
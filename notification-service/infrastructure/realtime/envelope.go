@@ -0,0 +1,69 @@
+package realtime
+
+import (
+	"encoding/json"
+)
+
+const ProtocolVersion = 1
+
+// Events pushed by the server.
+const (
+	NotificationCreatedEvent = "notification.created"
+	NotificationSeenEvent    = "notification.seen"
+	UnreadCountChangedEvent  = "unread_count.changed"
+	SettingsChangedEvent     = "settings.changed"
+	ReplayTruncatedEvent     = "replay.truncated"
+	ErrorEvent               = "error"
+)
+
+// Commands sent by clients.
+const (
+	MarkSeenCommand    = "mark_seen"
+	MarkAllSeenCommand = "mark_all_seen"
+	SubscribeCommand   = "subscribe"
+)
+
+// Envelope is the frame of every message exchanged over a real-time connection. Id is set by
+// clients on commands and echoed back on the error reply to correlate it.
+type Envelope struct {
+	Version int             `json:"version"`
+	Type    string          `json:"type"`
+	Id      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type ErrorPayload struct {
+	Message string `json:"message"`
+	Command string `json:"command,omitempty"`
+}
+
+type SubscribePayload struct {
+	Events []string `json:"events"`
+}
+
+// CommandHandler executes the commands clients send over their WebSocket connection,
+// except subscribe which only concerns the connection itself and is handled by the hub.
+type CommandHandler interface {
+	HandleCommand(userId string, command Envelope) error
+}
+
+// NewMessage wraps the payload into an envelope of the given event type. The id is only used
+// for de-duplication and is empty for events which are never replayed.
+func NewMessage(eventType, id string, payload interface{}) (Message, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+
+	envelope, err := json.Marshal(Envelope{Version: ProtocolVersion, Type: eventType, Payload: data})
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Id: id, Type: eventType, Data: envelope}, nil
+}
+
+func newErrorMessage(command Envelope, message string) Message {
+	data, _ := json.Marshal(ErrorPayload{Message: message, Command: command.Type})
+	envelope, _ := json.Marshal(Envelope{Version: ProtocolVersion, Type: ErrorEvent, Id: command.Id, Payload: data})
+	return Message{Type: ErrorEvent, Data: envelope}
+}
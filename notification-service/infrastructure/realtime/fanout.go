@@ -0,0 +1,40 @@
+package realtime
+
+import "sync"
+
+// DeliverFunc hands a message published by any replica to the local hub.
+type DeliverFunc func(userId string, message Message)
+
+// Fanout distributes real-time messages to every replica of the service, so a user connected
+// to any of them receives notifications consumed by another one.
+type Fanout interface {
+	Publish(userId string, message Message) error
+	Subscribe(deliver DeliverFunc) error
+}
+
+// InMemoryFanout delivers messages only within the current process. It is meant for single
+// replica deployments and tests.
+type InMemoryFanout struct {
+	mutex       sync.RWMutex
+	subscribers []DeliverFunc
+}
+
+func NewInMemoryFanout() *InMemoryFanout {
+	return &InMemoryFanout{}
+}
+
+func (fanout *InMemoryFanout) Publish(userId string, message Message) error {
+	fanout.mutex.RLock()
+	defer fanout.mutex.RUnlock()
+	for _, deliver := range fanout.subscribers {
+		deliver(userId, message)
+	}
+	return nil
+}
+
+func (fanout *InMemoryFanout) Subscribe(deliver DeliverFunc) error {
+	fanout.mutex.Lock()
+	defer fanout.mutex.Unlock()
+	fanout.subscribers = append(fanout.subscribers, deliver)
+	return nil
+}
@@ -0,0 +1,233 @@
+package realtime
+
+import (
+	"github.com/gorilla/websocket"
+	"log"
+	"sync"
+	"time"
+)
+
+const deliveryQueueSize = 256
+
+// Message is a payload pushed to the connections of a user. Id is used to drop live
+// messages which have already been written to a connection while replaying, and Type to
+// skip events a connection did not subscribe to.
+type Message struct {
+	Id   string
+	Type string
+	Data []byte
+}
+
+// ReplayFunc loads the messages a connection missed while it was offline.
+type ReplayFunc func() ([]Message, error)
+
+// subscription is the hub's view of a single connection, independent of its transport.
+type subscription struct {
+	userId      string
+	send        chan Message
+	eventsMutex sync.RWMutex
+	events      map[string]struct{}
+}
+
+// subscribeTo limits the events delivered to the connection. An empty list restores all events.
+func (sub *subscription) subscribeTo(events []string) {
+	sub.eventsMutex.Lock()
+	defer sub.eventsMutex.Unlock()
+	if len(events) == 0 {
+		sub.events = nil
+		return
+	}
+	sub.events = make(map[string]struct{}, len(events))
+	for _, event := range events {
+		sub.events[event] = struct{}{}
+	}
+	sub.events[ErrorEvent] = struct{}{}
+}
+
+func (sub *subscription) wants(message Message) bool {
+	sub.eventsMutex.RLock()
+	defer sub.eventsMutex.RUnlock()
+	if sub.events == nil {
+		return true
+	}
+	_, ok := sub.events[message.Type]
+	return ok
+}
+
+type delivery struct {
+	userId  string
+	message Message
+}
+
+type reply struct {
+	subscription *subscription
+	message      Message
+}
+
+type connectionCount struct {
+	userId string
+	count  chan int
+}
+
+// Hub keeps track of the open WebSocket and event stream connections of every user. All
+// mutations of the connection registry happen on the goroutine running Run, so HTTP handlers
+// and the Kafka consumer only talk to it through channels.
+type Hub struct {
+	pongWait      time.Duration
+	subscriptions map[string]map[*subscription]struct{}
+	register      chan *subscription
+	unregister    chan *subscription
+	deliver       chan delivery
+	reply         chan reply
+	connections   chan connectionCount
+}
+
+// NewHub creates a hub which closes WebSocket connections that have not answered a ping within
+// the pong wait.
+func NewHub(pongWait time.Duration) *Hub {
+	return &Hub{
+		pongWait:      pongWait,
+		subscriptions: map[string]map[*subscription]struct{}{},
+		register:      make(chan *subscription),
+		unregister:    make(chan *subscription),
+		deliver:       make(chan delivery, deliveryQueueSize),
+		reply:         make(chan reply),
+		connections:   make(chan connectionCount),
+	}
+}
+
+func (hub *Hub) Run() {
+	for {
+		select {
+		case sub := <-hub.register:
+			if hub.subscriptions[sub.userId] == nil {
+				hub.subscriptions[sub.userId] = map[*subscription]struct{}{}
+			}
+			hub.subscriptions[sub.userId][sub] = struct{}{}
+		case sub := <-hub.unregister:
+			hub.remove(sub)
+		case message := <-hub.deliver:
+			for sub := range hub.subscriptions[message.userId] {
+				hub.enqueue(sub, message.message)
+			}
+		case message := <-hub.reply:
+			if _, ok := hub.subscriptions[message.subscription.userId][message.subscription]; ok {
+				hub.enqueue(message.subscription, message.message)
+			}
+		case query := <-hub.connections:
+			query.count <- len(hub.subscriptions[query.userId])
+		}
+	}
+}
+
+// pingPeriod leaves connections time to answer a ping before the pong wait runs out.
+func (hub *Hub) pingPeriod() time.Duration {
+	return hub.pongWait * 9 / 10
+}
+
+// Serve registers the upgraded connection of the given user and starts its read and write pumps.
+// Commands received over the connection are passed to the given handler.
+func (hub *Hub) Serve(conn *websocket.Conn, userId string, replay ReplayFunc, commands CommandHandler) {
+	sub, replayed := hub.subscribe(userId, replay)
+	client := newClient(hub, conn, sub, commands)
+
+	go client.writePump(replayed)
+	go client.readPump()
+}
+
+func (hub *Hub) SendToUser(userId string, message Message) {
+	hub.deliver <- delivery{userId: userId, message: message}
+}
+
+// Connections returns the number of open connections of the user.
+func (hub *Hub) Connections(userId string) int {
+	query := connectionCount{userId: userId, count: make(chan int, 1)}
+	hub.connections <- query
+	return <-query.count
+}
+
+// subscribe registers a connection before its replay is loaded, so messages created in the
+// meantime are queued and written after the replayed ones, skipping those already replayed.
+func (hub *Hub) subscribe(userId string, replay ReplayFunc) (*subscription, []Message) {
+	sub := &subscription{
+		userId: userId,
+		send:   make(chan Message, sendQueueSize),
+	}
+	hub.register <- sub
+
+	if replay == nil {
+		return sub, nil
+	}
+	replayed, err := replay()
+	if err != nil {
+		log.Printf("Failed to load missed messages of user %s: %v", userId, err)
+	}
+	return sub, replayed
+}
+
+func (hub *Hub) enqueue(sub *subscription, message Message) {
+	select {
+	case sub.send <- message:
+	default:
+		log.Printf("Send queue of connection for user %s is full, closing it", sub.userId)
+		hub.remove(sub)
+	}
+}
+
+func (hub *Hub) remove(sub *subscription) {
+	userSubscriptions, ok := hub.subscriptions[sub.userId]
+	if !ok {
+		return
+	}
+	if _, ok := userSubscriptions[sub]; !ok {
+		return
+	}
+
+	delete(userSubscriptions, sub)
+	if len(userSubscriptions) == 0 {
+		delete(hub.subscriptions, sub.userId)
+	}
+	close(sub.send)
+}
+
+func replayedIds(replayed []Message) map[string]struct{} {
+	ids := make(map[string]struct{}, len(replayed))
+	for _, message := range replayed {
+		if message.Id != "" {
+			ids[message.Id] = struct{}{}
+		}
+	}
+	return ids
+}
+
+// replay writes the replayed messages while taking the messages queued for the connection in the
+// meantime, so a connection which is slow to replay does not fill up its queue and get closed as
+// too slow. It returns the messages queued during the replay and whether the hub still has the
+// connection open.
+func (sub *subscription) replay(replayed []Message, write func(Message) error) ([]Message, bool, error) {
+	written := make(chan error, 1)
+	go func() {
+		for _, message := range replayed {
+			if err := write(message); err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- nil
+	}()
+
+	var pending []Message
+	queue := sub.send
+	for {
+		select {
+		case err := <-written:
+			return pending, queue != nil, err
+		case message, ok := <-queue:
+			if !ok {
+				queue = nil
+				continue
+			}
+			pending = append(pending, message)
+		}
+	}
+}
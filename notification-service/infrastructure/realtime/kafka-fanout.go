@@ -0,0 +1,195 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"log"
+	"time"
+)
+
+const (
+	fanoutPollTimeout     = time.Second
+	fanoutMetadataRefresh = time.Minute
+	fanoutInitialBackoff  = 100 * time.Millisecond
+	fanoutMaxBackoff      = 30 * time.Second
+)
+
+type fanoutRecord struct {
+	UserId string `json:"user_id"`
+	Id     string `json:"id"`
+	Type   string `json:"type"`
+	Data   []byte `json:"data"`
+}
+
+// KafkaFanout broadcasts messages through a Kafka topic. Every replica reads all partitions of
+// the topic from their end, assigned manually instead of by a consumer group, so each of them
+// sees every message and delivers it to its own hub without leaving groups behind on the brokers.
+// The consumer config still needs a group.id, but no group is joined and no offsets committed.
+// The partitions are looked up again every fanoutMetadataRefresh, so partitions added to the
+// topic later are read too.
+type KafkaFanout struct {
+	producer   *kafka.Producer
+	consumer   *kafka.Consumer
+	topic      string
+	partitions map[int32]struct{}
+}
+
+func NewKafkaFanout(producerConfig, consumerConfig *kafka.ConfigMap, topic string) (*KafkaFanout, error) {
+	producer, err := kafka.NewProducer(producerConfig)
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := kafka.NewConsumer(consumerConfig)
+	if err != nil {
+		producer.Close()
+		return nil, err
+	}
+
+	if err := createTopic(producer, topic); err != nil {
+		producer.Close()
+		consumer.Close()
+		return nil, fmt.Errorf("failed to create topic %s: %w", topic, err)
+	}
+
+	go logDeliveryErrors(producer)
+
+	return &KafkaFanout{
+		producer:   producer,
+		consumer:   consumer,
+		topic:      topic,
+		partitions: map[int32]struct{}{},
+	}, nil
+}
+
+func (fanout *KafkaFanout) Publish(userId string, message Message) error {
+	value, err := json.Marshal(fanoutRecord{UserId: userId, Id: message.Id, Type: message.Type, Data: message.Data})
+	if err != nil {
+		return err
+	}
+
+	return fanout.producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &fanout.topic, Partition: kafka.PartitionAny},
+		Key:            []byte(userId),
+		Value:          value,
+	}, nil)
+}
+
+func (fanout *KafkaFanout) Subscribe(deliver DeliverFunc) error {
+	if err := fanout.assign(kafka.OffsetEnd); err != nil {
+		return err
+	}
+
+	go func() {
+		refreshed := time.Now()
+		backoff := fanoutInitialBackoff
+		for {
+			if time.Since(refreshed) >= fanoutMetadataRefresh {
+				refreshed = time.Now()
+				// Partitions added since the last refresh only hold messages published after they
+				// were created, so they are read from their beginning.
+				if err := fanout.assign(kafka.OffsetBeginning); err != nil {
+					log.Printf("Failed to refresh partitions of real-time fanout topic %s: %v", fanout.topic, err)
+				}
+			}
+
+			msg, err := fanout.consumer.ReadMessage(fanoutPollTimeout)
+			if err != nil {
+				if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.IsTimeout() {
+					continue
+				}
+				log.Printf("Error reading real-time fanout message, retrying in %s: %v", backoff, err)
+				time.Sleep(backoff)
+				backoff = min(2*backoff, fanoutMaxBackoff)
+				continue
+			}
+			backoff = fanoutInitialBackoff
+
+			var record fanoutRecord
+			if err := json.Unmarshal(msg.Value, &record); err != nil {
+				log.Printf("Failed to unmarshal real-time fanout message: %v", err)
+				continue
+			}
+			deliver(record.UserId, Message{Id: record.Id, Type: record.Type, Data: record.Data})
+		}
+	}()
+	return nil
+}
+
+// assign reads the current partitions of the topic from the brokers, starts reading the new ones
+// at the given offset and stops reading those which are gone.
+func (fanout *KafkaFanout) assign(offset kafka.Offset) error {
+	metadata, err := fanout.consumer.GetMetadata(&fanout.topic, false, 10000)
+	if err != nil {
+		return err
+	}
+	topic, ok := metadata.Topics[fanout.topic]
+	if !ok || topic.Error.Code() != kafka.ErrNoError || len(topic.Partitions) == 0 {
+		return fmt.Errorf("topic %s has no partitions: %v", fanout.topic, topic.Error)
+	}
+
+	current := map[int32]struct{}{}
+	var added []kafka.TopicPartition
+	for _, partition := range topic.Partitions {
+		current[partition.ID] = struct{}{}
+		if _, ok := fanout.partitions[partition.ID]; !ok {
+			added = append(added, kafka.TopicPartition{Topic: &fanout.topic, Partition: partition.ID, Offset: offset})
+		}
+	}
+	var removed []kafka.TopicPartition
+	for id := range fanout.partitions {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, kafka.TopicPartition{Topic: &fanout.topic, Partition: id})
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := fanout.consumer.IncrementalUnassign(removed); err != nil {
+			return err
+		}
+		for _, partition := range removed {
+			delete(fanout.partitions, partition.Partition)
+		}
+	}
+	if len(added) > 0 {
+		if err := fanout.consumer.IncrementalAssign(added); err != nil {
+			return err
+		}
+		for _, partition := range added {
+			fanout.partitions[partition.Partition] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// createTopic creates the fanout topic with the partition count and replication factor the
+// brokers default to, unless it exists already.
+func createTopic(producer *kafka.Producer, topic string) error {
+	admin, err := kafka.NewAdminClientFromProducer(producer)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	results, err := admin.CreateTopics(ctx, []kafka.TopicSpecification{{Topic: topic, NumPartitions: -1}})
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if code := result.Error.Code(); code != kafka.ErrNoError && code != kafka.ErrTopicAlreadyExists {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+func logDeliveryErrors(producer *kafka.Producer) {
+	for event := range producer.Events() {
+		if msg, ok := event.(*kafka.Message); ok && msg.TopicPartition.Error != nil {
+			log.Printf("Failed to publish real-time fanout message: %v", msg.TopicPartition.Error)
+		}
+	}
+}
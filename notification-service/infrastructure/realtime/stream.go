@@ -0,0 +1,95 @@
+package realtime
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ServeStream delivers the messages of the given user as Server-Sent Events until the client
+// disconnects or the hub evicts the connection. It shares the registry with WebSocket clients.
+// The stream is read-only, so the events it carries are chosen up front; nil means all of them.
+func (hub *Hub) ServeStream(w http.ResponseWriter, r *http.Request, userId string, replay ReplayFunc, events []string) error {
+	if _, ok := w.(http.Flusher); !ok {
+		return errors.New("streaming is not supported by the connection")
+	}
+	controller := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := controller.Flush(); err != nil {
+		return err
+	}
+
+	sub, replayed := hub.subscribe(userId, replay)
+	sub.subscribeTo(events)
+	defer func() { hub.unregister <- sub }()
+
+	pending, open, err := sub.replay(replayed, func(message Message) error {
+		return writeEvent(controller, w, message)
+	})
+	if err != nil {
+		log.Printf("Failed to replay messages to event stream of user %s: %v", userId, err)
+		return nil
+	}
+	skip := replayedIds(replayed)
+	for _, message := range pending {
+		if _, ok := skip[message.Id]; ok || !sub.wants(message) {
+			continue
+		}
+		if err := writeEvent(controller, w, message); err != nil {
+			log.Printf("Failed to write to event stream of user %s: %v", userId, err)
+			return nil
+		}
+	}
+	if !open {
+		return nil
+	}
+
+	ticker := time.NewTicker(hub.pingPeriod())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case message, ok := <-sub.send:
+			if !ok {
+				return nil
+			}
+			if _, ok := skip[message.Id]; ok || !sub.wants(message) {
+				continue
+			}
+			if err := writeEvent(controller, w, message); err != nil {
+				log.Printf("Failed to write to event stream of user %s: %v", userId, err)
+				return nil
+			}
+		case <-ticker.C:
+			_ = controller.SetWriteDeadline(time.Now().Add(writeWait))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+			if err := controller.Flush(); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func writeEvent(controller *http.ResponseController, w http.ResponseWriter, message Message) error {
+	_ = controller.SetWriteDeadline(time.Now().Add(writeWait))
+	if message.Id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", message.Id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Type, message.Data); err != nil {
+		return err
+	}
+	return controller.Flush()
+}
@@ -0,0 +1,92 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BellNotificationQueryRequest lists every notification when neither a limit nor a before cursor
+// is given, as the list did before it was paged.
+type BellNotificationQueryRequest struct {
+	Limit  *int64 `validate:"omitempty,min=1,max=100"`
+	Before string `validate:"omitempty,mongodb"`
+	Seen   string `validate:"omitempty,oneof=true false"`
+	Types  []int  `validate:"dive,notification_type"`
+	From   string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To     string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// NewBellNotificationQueryRequest reads the query parameters of the bell notification list.
+// Types may be given either as repeated type parameters or as a comma separated list.
+func NewBellNotificationQueryRequest(values url.Values) (BellNotificationQueryRequest, error) {
+	request := BellNotificationQueryRequest{
+		Before: values.Get("before"),
+		Seen:   values.Get("seen"),
+		From:   values.Get("from"),
+		To:     values.Get("to"),
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			return request, err
+		}
+		request.Limit = &parsed
+	}
+
+	for _, value := range values["type"] {
+		for _, notificationType := range strings.Split(value, ",") {
+			parsed, err := strconv.Atoi(notificationType)
+			if err != nil {
+				return request, err
+			}
+			request.Types = append(request.Types, parsed)
+		}
+	}
+
+	return request, nil
+}
+
+func (request BellNotificationQueryRequest) AreValidRequestData() error {
+	validate := validator.New()
+	validate.RegisterValidation("notification_type", func(field validator.FieldLevel) bool {
+		return slices.Contains(domain.NotificationTypes(), domain.NotificationType(field.Field().Int()))
+	})
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
+
+func (request BellNotificationQueryRequest) ToQuery() domain.BellNotificationQuery {
+	query := domain.BellNotificationQuery{}
+	if request.Limit != nil {
+		query.Limit = *request.Limit
+	} else if request.Before != "" {
+		query.Limit = domain.DefaultPageSize
+	}
+	if request.Before != "" {
+		query.Before, _ = primitive.ObjectIDFromHex(request.Before)
+	}
+	if request.Seen != "" {
+		seen := request.Seen == "true"
+		query.Seen = &seen
+	}
+	for _, notificationType := range request.Types {
+		query.Types = append(query.Types, domain.NotificationType(notificationType))
+	}
+	if request.From != "" {
+		query.From, _ = time.Parse(time.RFC3339, request.From)
+	}
+	if request.To != "" {
+		query.To, _ = time.Parse(time.RFC3339, request.To)
+	}
+	return query
+}
@@ -0,0 +1,56 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"strconv"
+)
+
+type DeliveryLogQueryRequest struct {
+	Limit   int64  `validate:"min=1,max=100"`
+	Before  string `validate:"omitempty,mongodb"`
+	Channel string `validate:"omitempty,oneof=bell email push webhook sms"`
+	Status  string `validate:"omitempty,oneof=pending sent failed bounced skipped"`
+}
+
+func NewDeliveryLogQueryRequest(values url.Values) (DeliveryLogQueryRequest, error) {
+	request := DeliveryLogQueryRequest{
+		Limit:   domain.DefaultPageSize,
+		Before:  values.Get("before"),
+		Channel: values.Get("channel"),
+		Status:  values.Get("status"),
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			return request, err
+		}
+		request.Limit = parsed
+	}
+
+	return request, nil
+}
+
+func (request DeliveryLogQueryRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
+
+func (request DeliveryLogQueryRequest) ToQuery() domain.DeliveryLogQuery {
+	query := domain.DeliveryLogQuery{
+		Limit:   request.Limit,
+		Channel: domain.Channel(request.Channel),
+		Status:  domain.DeliveryStatus(request.Status),
+	}
+	if request.Before != "" {
+		query.Before, _ = primitive.ObjectIDFromHex(request.Before)
+	}
+	return query
+}
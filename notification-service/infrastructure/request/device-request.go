@@ -0,0 +1,53 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"time"
+)
+
+// DeviceRequest registers a device. APNs device tokens are 32 bytes in hex, FCM registration
+// tokens are opaque.
+type DeviceRequest struct {
+	Platform   string `json:"platform" validate:"required,oneof=android ios"`
+	Token      string `json:"token" validate:"required,max=4096,printascii,excludesall=/?#%"`
+	AppVersion string `json:"appVersion" validate:"omitempty,max=64"`
+}
+
+type DeviceTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (request DeviceRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+	if request.Platform == domain.IosPlatform {
+		if err := validate.Var(request.Token, "hexadecimal,len=64"); err != nil {
+			return err.(validator.ValidationErrors)
+		}
+	}
+
+	return nil
+}
+
+func (request DeviceTokenRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
+
+func (request DeviceRequest) ToDevice(userId string) *domain.Device {
+	return &domain.Device{
+		UserId:     userId,
+		Platform:   request.Platform,
+		Token:      request.Token,
+		AppVersion: request.AppVersion,
+		LastSeen:   time.Now(),
+		Active:     true,
+	}
+}
@@ -0,0 +1,18 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+)
+
+type EmailRequest struct {
+	Email string `json:"email" validate:"omitempty,email"`
+}
+
+func (request EmailRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
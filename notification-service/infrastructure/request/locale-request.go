@@ -0,0 +1,18 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+)
+
+type LocaleRequest struct {
+	Locale string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+}
+
+func (request LocaleRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+)
+
+type MarkSeenCommandRequest struct {
+	NotificationId string `json:"notificationId" validate:"required,mongodb"`
+}
+
+func (request MarkSeenCommandRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
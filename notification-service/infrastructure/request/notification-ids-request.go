@@ -0,0 +1,34 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type NotificationIdsRequest struct {
+	Ids []string `json:"ids" validate:"required,min=1,max=100,dive,mongodb"`
+}
+
+func (request NotificationIdsRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
+
+// ToObjectIDs converts the validated ids, dropping duplicates.
+func (request NotificationIdsRequest) ToObjectIDs() []primitive.ObjectID {
+	seen := make(map[primitive.ObjectID]struct{}, len(request.Ids))
+	ids := make([]primitive.ObjectID, 0, len(request.Ids))
+	for _, hex := range request.Ids {
+		id, _ := primitive.ObjectIDFromHex(hex)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	return ids
+}
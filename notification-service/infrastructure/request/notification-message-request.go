@@ -2,11 +2,13 @@ package request
 
 import (
 	"github.com/go-playground/validator/v10"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
 )
 
 type NotificationMessageRequest struct {
 	ReceiverId          string `json:"receiver_id" validate:"required"`
 	Status              string `json:"status"`
+	StartActionUserId   string `json:"start_action_user_id" validate:"omitempty"`
 	StartActionUserName string `json:"start_action_user_name" validate:"omitempty"`
 	AccommodationId     string `json:"accommodation_id" validate:"omitempty"`
 	ReservationId       string `json:"reservation_id" validate:"omitempty"`
@@ -20,3 +22,13 @@ func (request NotificationMessageRequest) AreValidRequestData() error {
 
 	return nil
 }
+
+func (request NotificationMessageRequest) ToPayload() domain.NotificationPayload {
+	return domain.NotificationPayload{
+		ReservationId:   request.ReservationId,
+		AccommodationId: request.AccommodationId,
+		ActorId:         request.StartActionUserId,
+		ActorName:       request.StartActionUserName,
+		Status:          request.Status,
+	}
+}
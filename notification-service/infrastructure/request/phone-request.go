@@ -0,0 +1,21 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+)
+
+// PhoneRequest sets the number SMS are sent to in E.164 format, an empty number removes it.
+// Opting in to SMS requires a number.
+type PhoneRequest struct {
+	Phone    string `json:"phone" validate:"required_if=SmsOptIn true,omitempty,e164"`
+	SmsOptIn bool   `json:"smsOptIn"`
+}
+
+func (request PhoneRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
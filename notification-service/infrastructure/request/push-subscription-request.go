@@ -0,0 +1,51 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"time"
+)
+
+type PushSubscriptionKeysRequest struct {
+	P256dh string `json:"p256dh" validate:"required,base64rawurl|base64url"`
+	Auth   string `json:"auth" validate:"required,base64rawurl|base64url"`
+}
+
+// PushSubscriptionRequest is the JSON form of a browser's PushSubscription.
+type PushSubscriptionRequest struct {
+	Endpoint string                      `json:"endpoint" validate:"required,url,startswith=https://"`
+	Keys     PushSubscriptionKeysRequest `json:"keys" validate:"required"`
+}
+
+type PushUnsubscriptionRequest struct {
+	Endpoint string `json:"endpoint" validate:"required,url"`
+}
+
+func (request PushSubscriptionRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
+
+func (request PushUnsubscriptionRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
+
+func (request PushSubscriptionRequest) ToPushSubscription(userId, userAgent string) *domain.PushSubscription {
+	return &domain.PushSubscription{
+		UserId:    userId,
+		Endpoint:  request.Endpoint,
+		P256dh:    request.Keys.P256dh,
+		Auth:      request.Keys.Auth,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+	}
+}
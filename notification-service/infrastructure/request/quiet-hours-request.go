@@ -0,0 +1,36 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+)
+
+// QuietHoursRequest replaces the user's quiet hours. Times are written as "15:04" in the IANA
+// time zone of the request; a window ending before it starts spans midnight. No windows turn
+// quiet hours off.
+type QuietHoursRequest struct {
+	TimeZone string                    `json:"timeZone" validate:"required_with=Windows,omitempty,timezone"`
+	Windows  []QuietHoursWindowRequest `json:"windows" validate:"max=7,dive"`
+}
+
+type QuietHoursWindowRequest struct {
+	Start string `json:"start" validate:"required,datetime=15:04"`
+	End   string `json:"end" validate:"required,datetime=15:04,nefield=Start"`
+}
+
+func (request QuietHoursRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
+
+func (request QuietHoursRequest) ToQuietHours() []domain.QuietHours {
+	quietHours := make([]domain.QuietHours, 0, len(request.Windows))
+	for _, window := range request.Windows {
+		quietHours = append(quietHours, domain.QuietHours{Start: window.Start, End: window.End})
+	}
+	return quietHours
+}
@@ -7,6 +7,7 @@ import (
 type UserCreatedNotificationRequest struct {
 	UserId string `json:"user_id" validate:"required"`
 	Role   string `json:"role" validate:"required"`
+	Email  string `json:"email" validate:"omitempty,email"`
 }
 
 func (request UserCreatedNotificationRequest) AreValidRequestData() error {
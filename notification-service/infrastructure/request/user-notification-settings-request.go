@@ -6,12 +6,15 @@ import (
 )
 
 type NotificationSettingRequest struct {
-	Type   int  `json:"type" validate:"min=0,max=4"`
-	Active bool `json:"active"`
+	Type     int             `json:"type" validate:"min=0,max=4"`
+	Active   bool            `json:"active"`
+	Channels map[string]bool `json:"channels" validate:"omitempty,dive,keys,oneof=bell email push webhook sms,endkeys"`
+	Digest   *string         `json:"digest" validate:"omitnil,oneof='' hourly daily weekly"`
+	Escalate *bool           `json:"escalate"`
 }
 
 type UserNotificationSettingsRequest struct {
-	Settings []NotificationSettingRequest `json:"settings" validate:"required"`
+	Settings []NotificationSettingRequest `json:"settings" validate:"required,dive"`
 	Role     string                       `json:"role" validate:"required"`
 }
 
@@ -24,17 +27,29 @@ func (request UserNotificationSettingsRequest) AreValidRequestData() error {
 	return nil
 }
 
-func FromSettingsRequests(settingsRequests []NotificationSettingRequest) []domain.NotificationSetting {
-	settings := make([]domain.NotificationSetting, 0, len(settingsRequests))
+func FromSettingsRequests(settingsRequests []NotificationSettingRequest) []domain.NotificationSettingUpdate {
+	settings := make([]domain.NotificationSettingUpdate, 0, len(settingsRequests))
 	for _, setting := range settingsRequests {
 		settings = append(settings, FromSettingsRequest(setting))
 	}
 	return settings
 }
 
-func FromSettingsRequest(settingsRequest NotificationSettingRequest) domain.NotificationSetting {
-	return domain.NotificationSetting{
-		Type:   domain.NotificationType(settingsRequest.Type),
-		Active: settingsRequest.Active,
+func FromSettingsRequest(settingsRequest NotificationSettingRequest) domain.NotificationSettingUpdate {
+	setting := domain.NotificationSettingUpdate{
+		Type:     domain.NotificationType(settingsRequest.Type),
+		Active:   settingsRequest.Active,
+		Escalate: settingsRequest.Escalate,
 	}
+	if settingsRequest.Digest != nil {
+		digest := domain.DigestFrequency(*settingsRequest.Digest)
+		setting.Digest = &digest
+	}
+	if settingsRequest.Channels != nil {
+		setting.Channels = make(map[domain.Channel]bool, len(settingsRequest.Channels))
+		for channel, enabled := range settingsRequest.Channels {
+			setting.Channels[domain.Channel(channel)] = enabled
+		}
+	}
+	return setting
 }
@@ -0,0 +1,58 @@
+package request
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"net/url"
+	"strconv"
+)
+
+type WebhookRequest struct {
+	Url        string `json:"url" validate:"required,http_url,startswith=https://,max=2048"`
+	EventTypes []int  `json:"eventTypes" validate:"omitempty,unique,dive,min=0,max=4"`
+}
+
+type WebhookAttemptsQueryRequest struct {
+	Limit int64 `validate:"min=1,max=100"`
+}
+
+func (request WebhookRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
+
+func (request WebhookRequest) ToWebhook(userId string) *domain.Webhook {
+	webhook := &domain.Webhook{
+		UserId: userId,
+		Url:    request.Url,
+	}
+	for _, eventType := range request.EventTypes {
+		webhook.EventTypes = append(webhook.EventTypes, domain.NotificationType(eventType))
+	}
+	return webhook
+}
+
+func NewWebhookAttemptsQueryRequest(values url.Values) (WebhookAttemptsQueryRequest, error) {
+	request := WebhookAttemptsQueryRequest{Limit: domain.DefaultPageSize}
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil {
+			return request, err
+		}
+		request.Limit = parsed
+	}
+	return request, nil
+}
+
+func (request WebhookAttemptsQueryRequest) AreValidRequestData() error {
+	validate := validator.New()
+	if err := validate.Struct(request); err != nil {
+		return err.(validator.ValidationErrors)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	subjectTemplateName = "subject"
+	bodyTemplateName    = "body"
+)
+
+// EmailData is what email templates are rendered with.
+type EmailData struct {
+	Message string
+	Link    string
+	Payload domain.NotificationPayload
+}
+
+type Email struct {
+	Subject string
+	Text    string
+	Html    string
+}
+
+type emailTemplate struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+// EmailTemplates renders emails from a plaintext and an HTML template per notification type,
+// "<type>.txt" defining the "subject" and "body" templates and "<type>.html" holding the HTML
+// document. Like messages, they are kept in a directory per locale and emails missing from a
+// locale are rendered in the default locale.
+type EmailTemplates struct {
+	catalogs      map[string]map[domain.NotificationType]emailTemplate
+	defaultLocale string
+}
+
+func LoadEmailTemplates(dir string, defaultLocale string) (*EmailTemplates, error) {
+	dirs, err := localeDirs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	emailTemplates := &EmailTemplates{catalogs: map[string]map[domain.NotificationType]emailTemplate{}, defaultLocale: normalizeLocale(defaultLocale)}
+	var errs []error
+	for locale, path := range dirs {
+		complete := locale == emailTemplates.defaultLocale
+		emailTemplates.catalogs[locale] = map[domain.NotificationType]emailTemplate{}
+		for _, notificationType := range domain.NotificationTypes() {
+			loaded, err := parseEmailTemplate(filepath.Join(path, notificationType.String()))
+			if errors.Is(err, os.ErrNotExist) && !complete {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			emailTemplates.catalogs[locale][notificationType] = loaded
+		}
+	}
+	if _, ok := dirs[emailTemplates.defaultLocale]; !ok {
+		errs = append(errs, fmt.Errorf("no email templates for default locale %q in %s", defaultLocale, dir))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return emailTemplates, nil
+}
+
+func parseEmailTemplate(path string) (emailTemplate, error) {
+	text, err := template.ParseFiles(path + ".txt")
+	if err != nil {
+		return emailTemplate{}, err
+	}
+	html, err := htmltemplate.ParseFiles(path + ".html")
+	if err != nil {
+		return emailTemplate{}, err
+	}
+	for _, name := range []string{subjectTemplateName, bodyTemplateName} {
+		if text.Lookup(name) == nil {
+			return emailTemplate{}, fmt.Errorf("%s.txt: missing %q template", path, name)
+		}
+	}
+
+	loaded := emailTemplate{text: text.Option("missingkey=error"), html: html.Option("missingkey=error")}
+	sample := EmailData{Message: "message", Link: "https://example.com", Payload: domain.NotificationPayload{ActorName: "name"}}
+	if _, err := loaded.render(sample); err != nil {
+		return emailTemplate{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return loaded, nil
+}
+
+func (emailTemplates *EmailTemplates) Render(locale string, notificationType domain.NotificationType, data EmailData) (Email, error) {
+	for _, candidate := range append(candidateLocales(locale), emailTemplates.defaultLocale) {
+		if loaded, ok := emailTemplates.catalogs[candidate][notificationType]; ok {
+			return loaded.render(data)
+		}
+	}
+	return Email{}, fmt.Errorf("no email template for notification type %d", notificationType)
+}
+
+func (loaded emailTemplate) render(data EmailData) (Email, error) {
+	var subject, text, html strings.Builder
+	if err := loaded.text.ExecuteTemplate(&subject, subjectTemplateName, data); err != nil {
+		return Email{}, err
+	}
+	if err := loaded.text.ExecuteTemplate(&text, bodyTemplateName, data); err != nil {
+		return Email{}, err
+	}
+	if err := loaded.html.Execute(&html, data); err != nil {
+		return Email{}, err
+	}
+	return Email{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+		Html:    html.String(),
+	}, nil
+}
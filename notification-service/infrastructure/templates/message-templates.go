@@ -0,0 +1,180 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	templateExtension   = ".tmpl"
+	defaultTemplateName = "default"
+)
+
+type catalog map[domain.NotificationType]*template.Template
+
+// MessageTemplates renders notification messages from text/template files. Every locale has its
+// own directory holding one file per notification type named after it. Every file defines a
+// "default" template and may define templates named after an event status, which take
+// precedence for events with that status. Messages missing from a locale are rendered in the
+// default locale, whose catalog has to be complete.
+type MessageTemplates struct {
+	catalogs      map[string]catalog
+	defaultLocale string
+}
+
+// LoadMessageTemplates parses the catalog of every locale from dir and checks that each of its
+// templates renders, so a broken template stops the service at startup.
+func LoadMessageTemplates(dir string, defaultLocale string) (*MessageTemplates, error) {
+	dirs, err := localeDirs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	messageTemplates := &MessageTemplates{catalogs: map[string]catalog{}, defaultLocale: normalizeLocale(defaultLocale)}
+	var errs []error
+	for locale, path := range dirs {
+		loaded, err := loadCatalog(path, locale == messageTemplates.defaultLocale)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		messageTemplates.catalogs[locale] = loaded
+	}
+	if _, ok := dirs[messageTemplates.defaultLocale]; !ok {
+		errs = append(errs, fmt.Errorf("no message templates for default locale %q in %s", defaultLocale, dir))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return messageTemplates, nil
+}
+
+// localeDirs returns the directory of every locale in dir by its normalized locale.
+func localeDirs(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := map[string]string{}
+	for _, entry := range entries {
+		// Stat follows symlinks, which is how ConfigMap volumes expose their directories.
+		path := filepath.Join(dir, entry.Name())
+		if info, err := os.Stat(path); err != nil || !info.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		dirs[normalizeLocale(entry.Name())] = path
+	}
+	return dirs, nil
+}
+
+func loadCatalog(dir string, complete bool) (catalog, error) {
+	loaded := catalog{}
+	var errs []error
+	for _, notificationType := range domain.NotificationTypes() {
+		path := filepath.Join(dir, notificationType.String()+templateExtension)
+		parsed, err := parseTemplate(path)
+		if errors.Is(err, os.ErrNotExist) && !complete {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		loaded[notificationType] = parsed
+	}
+	return loaded, errors.Join(errs...)
+}
+
+func parseTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Lookup(defaultTemplateName) == nil {
+		return nil, fmt.Errorf("%s: missing %q template", path, defaultTemplateName)
+	}
+
+	sample := domain.NotificationPayload{ReservationId: "id", AccommodationId: "id", ActorId: "id", ActorName: "name", Count: 2}
+	for _, defined := range parsed.Templates() {
+		if defined.Name() == parsed.Name() {
+			continue
+		}
+		if err := defined.Execute(&strings.Builder{}, sample); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return parsed, nil
+}
+
+// Supports reports whether messages can be rendered in the locale or in its base language.
+func (messageTemplates *MessageTemplates) Supports(locale string) bool {
+	for _, candidate := range candidateLocales(locale) {
+		if _, ok := messageTemplates.catalogs[candidate]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MessageKey returns the key of the template the event is rendered from, "<type>.<status>"
+// when the default locale has a template for the event's status and "<type>.default" otherwise.
+func (messageTemplates *MessageTemplates) MessageKey(notificationType domain.NotificationType, payload domain.NotificationPayload) string {
+	name := defaultTemplateName
+	parsed, ok := messageTemplates.catalogs[messageTemplates.defaultLocale][notificationType]
+	if ok && payload.Status != "" && parsed.Lookup(payload.Status) != nil {
+		name = payload.Status
+	}
+	return notificationType.String() + "." + name
+}
+
+// Render renders the message with the given key in the locale, falling back to its base language
+// ("sr" for "sr-Latn") and then to the default locale.
+func (messageTemplates *MessageTemplates) Render(locale, key string, payload domain.NotificationPayload) (string, error) {
+	typeName, name, _ := strings.Cut(key, ".")
+	notificationType, ok := domain.ParseNotificationType(typeName)
+	if !ok {
+		return "", fmt.Errorf("unknown message key %q", key)
+	}
+	parsed, ok := messageTemplates.lookup(locale, notificationType, name)
+	if !ok {
+		return "", fmt.Errorf("no message template for key %q", key)
+	}
+
+	var message strings.Builder
+	if err := parsed.ExecuteTemplate(&message, name, payload); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(message.String()), nil
+}
+
+func (messageTemplates *MessageTemplates) lookup(locale string, notificationType domain.NotificationType, name string) (*template.Template, bool) {
+	for _, candidate := range append(candidateLocales(locale), messageTemplates.defaultLocale) {
+		if parsed, ok := messageTemplates.catalogs[candidate][notificationType]; ok && parsed.Lookup(name) != nil {
+			return parsed, true
+		}
+	}
+	return nil, false
+}
+
+func candidateLocales(locale string) []string {
+	locale = normalizeLocale(locale)
+	base, _, found := strings.Cut(locale, "-")
+	if !found {
+		return []string{locale}
+	}
+	return []string{locale, base}
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
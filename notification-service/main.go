@@ -16,6 +16,8 @@ import (
 	"log"
 	"os"
 	"time"
+	// Quiet hours are kept in the users' time zones, which the runtime image has no database of.
+	_ "time/tzdata"
 )
 
 func initJaegerTracer(jaegerHost string) (*sdktrace.TracerProvider, error) {
@@ -69,15 +71,10 @@ func main() {
 
 	server := startup.NewServer(config, tp, loki)
 
-	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers": config.BootstrapServers,
-		"security.protocol": "sasl_plaintext",
-		"sasl.mechanism":    "PLAIN",
-		"sasl.username":     "user1",
-		"sasl.password":     config.KafkaAuthPassword,
+	consumer, err := kafka.NewConsumer(server.KafkaConfig(kafka.ConfigMap{
 		"group.id":          "notification-service",
 		"auto.offset.reset": "earliest",
-	})
+	}))
 	if err != nil {
 		log.Fatalf("Failed to create consumer: %s", err)
 	}
@@ -93,10 +90,22 @@ func main() {
 		"host-reviewed-reservation-request": server.NotificationHandler.OnHostRespondedToReservationRequest,
 	}
 
+	stopConsuming := make(chan struct{})
+	consumed := make(chan struct{})
 	go func() {
+		defer close(consumed)
 		for {
-			msg, err := consumer.ReadMessage(-1)
+			select {
+			case <-stopConsuming:
+				return
+			default:
+			}
+
+			msg, err := consumer.ReadMessage(time.Second)
 			if err != nil {
+				if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.IsTimeout() {
+					continue
+				}
 				log.Printf("Error reading message: %v", err)
 				continue
 			}
@@ -120,5 +129,8 @@ func main() {
 	}()
 
 	server.Start()
+	close(stopConsuming)
+	<-consumed
+	server.Drain()
 	loki.Shutdown()
 }
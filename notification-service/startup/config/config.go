@@ -1,6 +1,15 @@
 package config
 
-import "os"
+import (
+	"cmp"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Port              string
@@ -12,6 +21,60 @@ type Config struct {
 	KafkaAuthPassword string
 	JaegerHost        string
 	LokiHost          string
+	FanoutBackend     string
+	RealtimePongWait  time.Duration
+	PodName           string
+	RestoreWindow     time.Duration
+	PurgeInterval     time.Duration
+	Retention         domain.RetentionPolicy
+	TemplatesDir      string
+	DefaultLocale     string
+	EmailTemplatesDir string
+	SmtpHost          string
+	SmtpPort          string
+	SmtpUsername      string
+	SmtpPassword      string
+	SmtpFrom          string
+	SmtpSecurity      string
+	AppUrl            string
+	VapidPrivateKey   string
+	VapidSubject      string
+	PushProvider      string
+	FcmProjectId      string
+	FcmCredentials    string
+	ApnsKeyFile       string
+	ApnsKeyId         string
+	ApnsTeamId        string
+	ApnsTopic         string
+	ApnsProduction    bool
+	Webhook           WebhookConfig
+	SmsProvider       string
+	SmsApiUrl         string
+	SmsAccountSid     string
+	SmsAuthToken      string
+	SmsFrom           string
+	SmsMaxSegments    int
+	Jobs              JobsConfig
+	Escalation        domain.EscalationPolicy
+	// QuietHoursUrgentTypes are delivered during quiet hours too.
+	QuietHoursUrgentTypes []domain.NotificationType
+	// DigestHour is the hour of the day daily and weekly digests are delivered at.
+	DigestHour int
+	// DispatchWorkers bounds the notifications delivered over external channels at once.
+	DispatchWorkers int
+}
+
+type WebhookConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	DisableAfter   int
+}
+
+type JobsConfig struct {
+	PollInterval time.Duration
+	Lease        time.Duration
+	MaxAttempts  int
 }
 
 func NewConfig() *Config {
@@ -25,5 +88,212 @@ func NewConfig() *Config {
 		KafkaAuthPassword: os.Getenv("KAFKA_AUTH_PASSWORD"),
 		JaegerHost:        os.Getenv("JAEGER_ENDPOINT"),
 		LokiHost:          os.Getenv("LOKI_ENDPOINT"),
+		FanoutBackend:     os.Getenv("FANOUT_BACKEND"),
+		RealtimePongWait:  durationEnv("REALTIME_PONG_WAIT", 60*time.Second),
+		PodName:           podName(),
+		RestoreWindow:     durationEnv("NOTIFICATION_RESTORE_WINDOW", 24*time.Hour),
+		PurgeInterval:     durationEnv("NOTIFICATION_PURGE_INTERVAL", time.Hour),
+		Retention: domain.RetentionPolicy{
+			Default: domain.RetentionRule{
+				Seen:   durationEnv("RETENTION_SEEN", 90*24*time.Hour),
+				Unseen: durationEnv("RETENTION_UNSEEN", 365*24*time.Hour),
+			},
+			ByType: RetentionRules(os.Getenv("RETENTION_RULES")),
+			DryRun: os.Getenv("RETENTION_DRY_RUN") == "true",
+		},
+		TemplatesDir:      stringEnv("NOTIFICATION_TEMPLATES_DIR", "templates"),
+		DefaultLocale:     stringEnv("DEFAULT_LOCALE", "en"),
+		EmailTemplatesDir: stringEnv("EMAIL_TEMPLATES_DIR", "email-templates"),
+		SmtpHost:          os.Getenv("SMTP_HOST"),
+		SmtpPort:          stringEnv("SMTP_PORT", "587"),
+		SmtpUsername:      os.Getenv("SMTP_USERNAME"),
+		SmtpPassword:      os.Getenv("SMTP_PASSWORD"),
+		SmtpFrom:          os.Getenv("SMTP_FROM"),
+		SmtpSecurity:      stringEnv("SMTP_SECURITY", "starttls"),
+		AppUrl:            os.Getenv("APP_URL"),
+		VapidPrivateKey:   os.Getenv("VAPID_PRIVATE_KEY"),
+		VapidSubject:      stringEnv("VAPID_SUBJECT", "mailto:no-reply@zms.com"),
+		PushProvider:      os.Getenv("PUSH_PROVIDER"),
+		FcmProjectId:      os.Getenv("FCM_PROJECT_ID"),
+		FcmCredentials:    os.Getenv("FCM_CREDENTIALS_FILE"),
+		ApnsKeyFile:       os.Getenv("APNS_KEY_FILE"),
+		ApnsKeyId:         os.Getenv("APNS_KEY_ID"),
+		ApnsTeamId:        os.Getenv("APNS_TEAM_ID"),
+		ApnsTopic:         os.Getenv("APNS_TOPIC"),
+		ApnsProduction:    os.Getenv("APNS_PRODUCTION") == "true",
+		Webhook: WebhookConfig{
+			MaxAttempts:    intEnv("WEBHOOK_MAX_ATTEMPTS", 5),
+			InitialBackoff: durationEnv("WEBHOOK_INITIAL_BACKOFF", 30*time.Second),
+			MaxBackoff:     durationEnv("WEBHOOK_MAX_BACKOFF", time.Hour),
+			DisableAfter:   intEnv("WEBHOOK_DISABLE_AFTER", 10),
+		},
+		SmsProvider:    os.Getenv("SMS_PROVIDER"),
+		SmsApiUrl:      stringEnv("SMS_API_URL", "https://api.twilio.com"),
+		SmsAccountSid:  os.Getenv("SMS_ACCOUNT_SID"),
+		SmsAuthToken:   os.Getenv("SMS_AUTH_TOKEN"),
+		SmsFrom:        os.Getenv("SMS_FROM"),
+		SmsMaxSegments: intEnv("SMS_MAX_SEGMENTS", 3),
+		Jobs: JobsConfig{
+			PollInterval: durationEnv("JOB_POLL_INTERVAL", 15*time.Second),
+			Lease:        durationEnv("JOB_LEASE", 5*time.Minute),
+			MaxAttempts:  intEnv("JOB_MAX_ATTEMPTS", 5),
+		},
+		Escalation:            escalationRules(stringEnv("ESCALATION_RULES", "0:30m=email,2h=push")),
+		QuietHoursUrgentTypes: notificationTypes(stringEnv("QUIET_HOURS_URGENT_TYPES", "1")),
+		DigestHour:            hourEnv("DIGEST_HOUR", 8),
+		DispatchWorkers:       intEnv("DISPATCH_WORKERS", 32),
+	}
+}
+
+func podName() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+func stringEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func intEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		log.Printf("Invalid number %q for %s, using %d", value, key, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// hourEnv reads an hour of the day, from 0 to 23.
+func hourEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 || parsed > 23 {
+		log.Printf("Invalid hour %q for %s, using %d", value, key, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration %q for %s, using %s", value, key, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// RetentionRules parses per type overrides of the retention policy, written as
+// "<type>:seen=<duration>,unseen=<duration>;<type>:...". Missing durations keep notifications
+// forever. Rules of unknown types, unknown keys and rules without a valid duration are logged
+// and left out.
+func RetentionRules(value string) map[domain.NotificationType]domain.RetentionRule {
+	rules := map[domain.NotificationType]domain.RetentionRule{}
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		typeValue, durations, found := strings.Cut(entry, ":")
+		notificationType, err := strconv.Atoi(strings.TrimSpace(typeValue))
+		if !found || err != nil || !slices.Contains(domain.NotificationTypes(), domain.NotificationType(notificationType)) {
+			log.Printf("Invalid retention rule %q", entry)
+			continue
+		}
+
+		var rule domain.RetentionRule
+		parsedAny := false
+		for _, duration := range strings.Split(durations, ",") {
+			key, durationValue, _ := strings.Cut(strings.TrimSpace(duration), "=")
+			if key != "seen" && key != "unseen" {
+				log.Printf("Unknown retention key %q in rule %q, expected seen or unseen", key, entry)
+				continue
+			}
+			parsed, err := time.ParseDuration(durationValue)
+			if err != nil {
+				log.Printf("Invalid retention duration %q in rule %q", duration, entry)
+				continue
+			}
+			if key == "seen" {
+				rule.Seen = parsed
+			} else {
+				rule.Unseen = parsed
+			}
+			parsedAny = true
+		}
+		if !parsedAny {
+			log.Printf("Retention rule %q has no valid duration, leaving it out", entry)
+			continue
+		}
+		rules[domain.NotificationType(notificationType)] = rule
+	}
+	return rules
+}
+
+// escalationRules parses the escalation policy, written as
+// "<type>:<duration>=<channel>,<duration>=<channel>;<type>:...". Steps are taken in the order of
+// their durations, which count from the creation of the notification. Rules of unknown types
+// and invalid steps are logged and left out.
+func escalationRules(value string) domain.EscalationPolicy {
+	policy := domain.EscalationPolicy{}
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		typeValue, steps, found := strings.Cut(entry, ":")
+		notificationType, err := strconv.Atoi(strings.TrimSpace(typeValue))
+		if !found || err != nil || !slices.Contains(domain.NotificationTypes(), domain.NotificationType(notificationType)) {
+			log.Printf("Invalid escalation rule %q", entry)
+			continue
+		}
+
+		var rule []domain.EscalationStep
+		for _, step := range strings.Split(steps, ",") {
+			durationValue, channel, _ := strings.Cut(strings.TrimSpace(step), "=")
+			after, err := time.ParseDuration(durationValue)
+			if err != nil || channel == string(domain.BellChannel) || !slices.Contains(domain.Channels(), domain.Channel(channel)) {
+				log.Printf("Invalid escalation step %q in rule %q", step, entry)
+				continue
+			}
+			rule = append(rule, domain.EscalationStep{After: after, Channel: domain.Channel(channel)})
+		}
+		slices.SortStableFunc(rule, func(a, b domain.EscalationStep) int {
+			return cmp.Compare(a.After, b.After)
+		})
+		policy[domain.NotificationType(notificationType)] = rule
+	}
+	return policy
+}
+
+// notificationTypes parses a comma separated list of notification types.
+func notificationTypes(value string) []domain.NotificationType {
+	var types []domain.NotificationType
+	for _, typeValue := range strings.Split(value, ",") {
+		if strings.TrimSpace(typeValue) == "" {
+			continue
+		}
+		notificationType, err := strconv.Atoi(strings.TrimSpace(typeValue))
+		if err != nil {
+			log.Printf("Invalid notification type %q", typeValue)
+			continue
+		}
+		types = append(types, domain.NotificationType(notificationType))
 	}
+	return types
 }
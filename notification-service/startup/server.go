@@ -1,20 +1,33 @@
 package startup
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/afiskon/promtail-client/promtail"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/gorilla/mux"
 	"github.com/mmmajder/zms-devops-notification-service/application"
 	"github.com/mmmajder/zms-devops-notification-service/domain"
 	"github.com/mmmajder/zms-devops-notification-service/infrastructure/api"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/notifier"
 	"github.com/mmmajder/zms-devops-notification-service/infrastructure/persistence"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/realtime"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/templates"
 	"github.com/mmmajder/zms-devops-notification-service/startup/config"
 	"go.mongodb.org/mongo-driver/mongo"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout leaves each shutdown step time to finish within the grace period of the pod.
+const shutdownTimeout = 10 * time.Second
+
 type Server struct {
 	config              *config.Config
 	router              *mux.Router
@@ -38,33 +51,82 @@ func NewServer(config *config.Config, traceProvider *sdktrace.TracerProvider, lo
 	return handler
 }
 
+// Start serves requests until the process is asked to terminate, then stops accepting new ones
+// and returns once those in flight are answered.
 func (server *Server) Start() {
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", server.config.Port), server.router))
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%s", server.config.Port), Handler: server.router}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+	log.Println("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down the server gracefully: %v", err)
+	}
+}
+
+// Drain waits until the notifications consumed so far are delivered. Events must no longer be
+// consumed when it is called.
+func (server *Server) Drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.NotificationHandler.Drain(ctx); err != nil {
+		log.Printf("Failed to deliver the notifications in flight before shutting down: %v", err)
+	}
 }
 
 func (server *Server) setupHandlers() (*api.NotificationHandler, *api.NotificationSettingsHandler) {
 	mongoClient := server.initMongoClient()
+	messageTemplates := server.initMessageTemplates()
 	settingsStore := server.initNotificationSettingsStore(mongoClient)
-	settingsService := server.initSettingsService(settingsStore)
+	settingsService := server.initSettingsService(settingsStore, messageTemplates)
 
 	bellNotificationStore := server.initBellNotificationStore(mongoClient)
-	bellNotificationService := server.initBellNotificationService(bellNotificationStore)
-	notificationHandler := server.initNotificationHandler(bellNotificationService, settingsService)
+	bellNotificationService := server.initBellNotificationService(bellNotificationStore, messageTemplates)
+	hub := server.initHub()
+	fanout := server.initFanout(hub)
+	vapidKeys := server.initVapidKeys()
+	pushSubscriptionStore := server.initPushSubscriptionStore(mongoClient)
+	deviceStore := server.initDeviceStore(mongoClient)
+	webhookStore := server.initWebhookStore(mongoClient)
+	deliveryLogService := server.initDeliveryLogService(mongoClient)
+	jobStore := server.initJobStore(mongoClient)
+	purgeJob := server.initPurgeJob(bellNotificationStore, jobStore)
+	webhookNotifier := server.initWebhookNotifier(webhookStore, jobStore)
+	dispatcher := server.initDispatcher(messageTemplates, deliveryLogService, pushSubscriptionStore, vapidKeys, deviceStore, webhookNotifier)
+	escalationService := server.initEscalationService(jobStore, bellNotificationStore, settingsService, dispatcher)
+	deferredDeliveryService := server.initDeferredDeliveryService(jobStore, settingsService, dispatcher)
+	digestService := server.initDigestService(mongoClient, jobStore, bellNotificationService, settingsService, dispatcher, deliveryLogService)
+	notificationHandler := server.initNotificationHandler(bellNotificationService, settingsService, dispatcher, deliveryLogService, escalationService, deferredDeliveryService, digestService, hub, fanout)
+	server.initJobScheduler(jobStore, escalationService, webhookNotifier, purgeJob, notificationHandler)
 
-	settingsHandler := server.initSettingsHandler(settingsService)
+	settingsHandler := server.initSettingsHandler(settingsService, fanout)
+	pushSubscriptionHandler := server.initPushSubscriptionHandler(pushSubscriptionStore, vapidKeys)
 	settingsHandler.Init(server.router)
 	notificationHandler.Init(server.router)
+	pushSubscriptionHandler.Init(server.router)
+	server.initDeviceHandler(deviceStore).Init(server.router)
+	server.initWebhookHandler(webhookStore).Init(server.router)
+	server.initDeliveryLogHandler(deliveryLogService).Init(server.router)
 
 	return notificationHandler, settingsHandler
 }
 
-func (server *Server) initSettingsService(store domain.UserNotificationSettingsStore) *application.NotificationSettingsService {
+func (server *Server) initSettingsService(store domain.UserNotificationSettingsStore, messages domain.MessageRenderer) *application.NotificationSettingsService {
 
-	return application.NewNotificationSettingsService(store, &http.Client{}, server.loki)
+	return application.NewNotificationSettingsService(store, messages, server.config.QuietHoursUrgentTypes, &http.Client{}, server.loki)
 }
 
-func (server *Server) initSettingsHandler(settingsService *application.NotificationSettingsService) *api.NotificationSettingsHandler {
-	return api.NewNotificationSettingsHandler(settingsService, server.traceProvider, server.loki)
+func (server *Server) initSettingsHandler(settingsService *application.NotificationSettingsService, fanout realtime.Fanout) *api.NotificationSettingsHandler {
+	return api.NewNotificationSettingsHandler(settingsService, fanout, server.traceProvider, server.loki)
 }
 
 func (server *Server) initMongoClient() *mongo.Client {
@@ -72,6 +134,9 @@ func (server *Server) initMongoClient() *mongo.Client {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := persistence.RunMigrations(client); err != nil {
+		log.Printf("Failed to run migrations: %v", err)
+	}
 	return client
 }
 
@@ -87,10 +152,256 @@ func (server *Server) initNotificationSettingsStore(client *mongo.Client) domain
 	return store
 }
 
-func (server *Server) initBellNotificationService(store domain.BellNotificationStore) *application.BellNotificationService {
-	return application.NewBellNotificationService(store, &http.Client{}, server.loki)
+func (server *Server) initMessageTemplates() *templates.MessageTemplates {
+	messageTemplates, err := templates.LoadMessageTemplates(server.config.TemplatesDir, server.config.DefaultLocale)
+	if err != nil {
+		log.Fatalf("Failed to load message templates: %v", err)
+	}
+	return messageTemplates
+}
+
+func (server *Server) initBellNotificationService(store domain.BellNotificationStore, messages domain.MessageRenderer) *application.BellNotificationService {
+	return application.NewBellNotificationService(store, messages, &http.Client{}, server.loki, server.config.RestoreWindow)
+}
+
+func (server *Server) initPurgeJob(store domain.BellNotificationStore, jobStore domain.JobStore) *application.BellNotificationPurgeJob {
+	job := application.NewBellNotificationPurgeJob(store, jobStore, server.config.RestoreWindow, server.config.Retention, server.config.PurgeInterval, server.loki)
+	if err := job.Schedule(); err != nil {
+		log.Printf("Failed to schedule notification purge: %v", err)
+	}
+	return job
+}
+
+func (server *Server) initHub() *realtime.Hub {
+	hub := realtime.NewHub(server.config.RealtimePongWait)
+	go hub.Run()
+	return hub
+}
+
+func (server *Server) initFanout(hub *realtime.Hub) realtime.Fanout {
+	var fanout realtime.Fanout = realtime.NewInMemoryFanout()
+	if server.config.FanoutBackend == domain.KafkaFanoutBackend {
+		kafkaFanout, err := realtime.NewKafkaFanout(server.KafkaConfig(kafka.ConfigMap{}), server.KafkaConfig(kafka.ConfigMap{
+			"group.id":           domain.ServiceName + "-realtime",
+			"enable.auto.commit": false,
+		}), domain.RealtimeFanoutTopic)
+		if err != nil {
+			log.Fatalf("Failed to create real-time fanout: %s", err)
+		}
+		fanout = kafkaFanout
+	}
+
+	if err := fanout.Subscribe(hub.SendToUser); err != nil {
+		log.Fatalf("Failed to subscribe to real-time fanout: %s", err)
+	}
+	return fanout
+}
+
+// KafkaConfig returns the config of a Kafka client of the service with the overrides applied.
+func (server *Server) KafkaConfig(overrides kafka.ConfigMap) *kafka.ConfigMap {
+	configMap := kafka.ConfigMap{
+		"bootstrap.servers": server.config.BootstrapServers,
+		"security.protocol": "sasl_plaintext",
+		"sasl.mechanism":    "PLAIN",
+		"sasl.username":     "user1",
+		"sasl.password":     server.config.KafkaAuthPassword,
+	}
+	for key, value := range overrides {
+		configMap[key] = value
+	}
+	return &configMap
+}
+
+func (server *Server) initVapidKeys() *notifier.VapidKeys {
+	if server.config.VapidPrivateKey == "" {
+		log.Printf("VAPID_PRIVATE_KEY is not set, Web Push notifications are disabled")
+		return nil
+	}
+	keys, err := notifier.NewVapidKeys(server.config.VapidPrivateKey, server.config.VapidSubject)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return keys
+}
+
+func (server *Server) initPushSubscriptionStore(client *mongo.Client) domain.PushSubscriptionStore {
+	return persistence.NewPushSubscriptionMongoDBStore(client)
+}
+
+func (server *Server) initPushSubscriptionHandler(store domain.PushSubscriptionStore, vapidKeys *notifier.VapidKeys) *api.PushSubscriptionHandler {
+	publicKey := ""
+	if vapidKeys != nil {
+		publicKey = vapidKeys.PublicKey()
+	}
+	service := application.NewPushSubscriptionService(store, server.loki)
+	return api.NewPushSubscriptionHandler(service, publicKey, server.traceProvider, server.loki)
+}
+
+func (server *Server) initDeviceStore(client *mongo.Client) domain.DeviceStore {
+	return persistence.NewDeviceMongoDBStore(client)
+}
+
+func (server *Server) initDeviceHandler(store domain.DeviceStore) *api.DeviceHandler {
+	service := application.NewDeviceService(store, server.loki)
+	return api.NewDeviceHandler(service, server.traceProvider, server.loki)
+}
+
+func (server *Server) initWebhookStore(client *mongo.Client) domain.WebhookStore {
+	return persistence.NewWebhookMongoDBStore(client)
+}
+
+func (server *Server) initWebhookHandler(store domain.WebhookStore) *api.WebhookHandler {
+	service := application.NewWebhookService(store, notifier.CheckWebhookUrl, server.loki)
+	return api.NewWebhookHandler(service, server.traceProvider, server.loki)
+}
+
+// initSmsProvider returns the configured SMS provider, SMS_PROVIDER=http for the messaging API
+// and SMS_PROVIDER=fake for a provider that drops what it would send, which is for local
+// development only and has to be chosen explicitly.
+func (server *Server) initSmsProvider() domain.SmsProvider {
+	switch server.config.SmsProvider {
+	case "http":
+		return notifier.NewHttpSmsProvider(notifier.HttpSmsConfig{
+			BaseUrl:    server.config.SmsApiUrl,
+			AccountSid: server.config.SmsAccountSid,
+			AuthToken:  server.config.SmsAuthToken,
+			From:       server.config.SmsFrom,
+		}, &http.Client{Timeout: 10 * time.Second})
+	case "fake":
+		log.Printf("SMS_PROVIDER is fake, SMS notifications are not sent")
+		return notifier.NewFakeSmsProvider()
+	default:
+		log.Printf("SMS_PROVIDER is not set, SMS notifications are disabled")
+		return nil
+	}
+}
+
+// initPushProviders returns the mobile push providers that are configured. PUSH_PROVIDER=fake
+// replaces them with providers that drop what they would send, which is for local development
+// only and has to be chosen explicitly.
+func (server *Server) initPushProviders() []domain.PushProvider {
+	if server.config.PushProvider == "fake" {
+		log.Printf("PUSH_PROVIDER is fake, mobile push notifications are not sent")
+		return []domain.PushProvider{notifier.NewFakePushProvider(domain.AndroidPlatform), notifier.NewFakePushProvider(domain.IosPlatform)}
+	}
+
+	var providers []domain.PushProvider
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	if server.config.FcmCredentials != "" {
+		credentials, err := os.ReadFile(server.config.FcmCredentials)
+		if err != nil {
+			log.Fatalf("Failed to read FCM credentials: %v", err)
+		}
+		provider, err := notifier.NewFcmProvider(notifier.FcmConfig{ProjectId: server.config.FcmProjectId, Credentials: credentials}, httpClient)
+		if err != nil {
+			log.Fatal(err)
+		}
+		providers = append(providers, provider)
+	} else {
+		log.Printf("FCM_CREDENTIALS_FILE is not set, Android push notifications are disabled")
+	}
+	if server.config.ApnsKeyFile != "" {
+		key, err := os.ReadFile(server.config.ApnsKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to read APNs key: %v", err)
+		}
+		baseUrl := notifier.ApnsSandboxUrl
+		if server.config.ApnsProduction {
+			baseUrl = notifier.ApnsProductionUrl
+		}
+		provider, err := notifier.NewApnsProvider(notifier.ApnsConfig{
+			Key:     key,
+			KeyId:   server.config.ApnsKeyId,
+			TeamId:  server.config.ApnsTeamId,
+			Topic:   server.config.ApnsTopic,
+			BaseUrl: baseUrl,
+		}, httpClient)
+		if err != nil {
+			log.Fatal(err)
+		}
+		providers = append(providers, provider)
+	} else {
+		log.Printf("APNS_KEY_FILE is not set, iOS push notifications are disabled")
+	}
+	return providers
+}
+
+func (server *Server) initDeliveryLogService(client *mongo.Client) *application.DeliveryLogService {
+	return application.NewDeliveryLogService(persistence.NewDeliveryLogMongoDBStore(client), server.loki)
+}
+
+func (server *Server) initDeliveryLogHandler(service *application.DeliveryLogService) *api.DeliveryLogHandler {
+	return api.NewDeliveryLogHandler(service, server.traceProvider, server.loki)
+}
+
+func (server *Server) initDispatcher(messages domain.MessageRenderer, deliveryLog *application.DeliveryLogService, pushSubscriptionStore domain.PushSubscriptionStore, vapidKeys *notifier.VapidKeys, deviceStore domain.DeviceStore, webhookNotifier *notifier.WebhookNotifier) *application.NotificationDispatcher {
+	var notifiers []domain.Notifier
+	if server.config.SmtpHost != "" {
+		emailTemplates, err := templates.LoadEmailTemplates(server.config.EmailTemplatesDir, server.config.DefaultLocale)
+		if err != nil {
+			log.Fatalf("Failed to load email templates: %v", err)
+		}
+		notifiers = append(notifiers, notifier.NewSmtpNotifier(notifier.SmtpConfig{
+			Host:     server.config.SmtpHost,
+			Port:     server.config.SmtpPort,
+			Username: server.config.SmtpUsername,
+			Password: server.config.SmtpPassword,
+			From:     server.config.SmtpFrom,
+			Security: server.config.SmtpSecurity,
+			AppUrl:   server.config.AppUrl,
+		}, emailTemplates))
+	} else {
+		log.Printf("SMTP_HOST is not set, email notifications are disabled")
+	}
+	if vapidKeys != nil {
+		notifiers = append(notifiers, notifier.NewWebPushNotifier(pushSubscriptionStore, vapidKeys, &http.Client{Timeout: 10 * time.Second}, server.config.AppUrl))
+	}
+	if providers := server.initPushProviders(); len(providers) > 0 {
+		notifiers = append(notifiers, notifier.NewMobilePushNotifier(deviceStore, providers...))
+	}
+	if provider := server.initSmsProvider(); provider != nil {
+		notifiers = append(notifiers, notifier.NewSmsNotifier(provider, server.config.SmsMaxSegments, server.config.AppUrl))
+	}
+	notifiers = append(notifiers, webhookNotifier)
+	return application.NewNotificationDispatcher(messages, deliveryLog, notifiers...)
+}
+
+func (server *Server) initWebhookNotifier(webhookStore domain.WebhookStore, jobStore domain.JobStore) *notifier.WebhookNotifier {
+	return notifier.NewWebhookNotifier(webhookStore, jobStore, notifier.NewWebhookHttpClient(10*time.Second), notifier.WebhookConfig{
+		MaxAttempts:    server.config.Webhook.MaxAttempts,
+		InitialBackoff: server.config.Webhook.InitialBackoff,
+		MaxBackoff:     server.config.Webhook.MaxBackoff,
+		DisableAfter:   server.config.Webhook.DisableAfter,
+		AppUrl:         server.config.AppUrl,
+	})
+}
+
+func (server *Server) initJobStore(client *mongo.Client) domain.JobStore {
+	return persistence.NewJobMongoDBStore(client)
+}
+
+func (server *Server) initEscalationService(jobStore domain.JobStore, bellStore domain.BellNotificationStore, settingsService *application.NotificationSettingsService, dispatcher *application.NotificationDispatcher) *application.EscalationService {
+	return application.NewEscalationService(jobStore, bellStore, settingsService, dispatcher, server.config.Escalation, server.loki)
+}
+
+func (server *Server) initDeferredDeliveryService(jobStore domain.JobStore, settingsService *application.NotificationSettingsService, dispatcher *application.NotificationDispatcher) *application.DeferredDeliveryService {
+	return application.NewDeferredDeliveryService(jobStore, settingsService, dispatcher, server.loki)
+}
+
+func (server *Server) initDigestService(client *mongo.Client, jobStore domain.JobStore, bellService *application.BellNotificationService, settingsService *application.NotificationSettingsService, dispatcher *application.NotificationDispatcher, deliveryLog *application.DeliveryLogService) *application.DigestService {
+	return application.NewDigestService(persistence.NewDigestEventMongoDBStore(client), jobStore, bellService, settingsService, dispatcher, deliveryLog, server.config.DigestHour, server.loki)
+}
+
+func (server *Server) initJobScheduler(store domain.JobStore, escalationService *application.EscalationService, webhookNotifier *notifier.WebhookNotifier, purgeJob *application.BellNotificationPurgeJob, notificationHandler *api.NotificationHandler) {
+	scheduler := application.NewJobScheduler(store, server.config.PodName, server.config.Jobs.PollInterval, server.config.Jobs.Lease, server.config.Jobs.MaxAttempts, server.traceProvider.Tracer(domain.ServiceName), server.loki)
+	scheduler.Register(domain.EscalationJob, escalationService.Escalate)
+	scheduler.Register(domain.DeferredDeliveryJob, notificationHandler.DeliverDeferred)
+	scheduler.Register(domain.DigestJob, notificationHandler.DeliverDigest)
+	scheduler.Register(domain.WebhookRetryJob, webhookNotifier.Retry)
+	scheduler.Register(domain.PurgeJob, purgeJob.Run)
+	scheduler.Start()
 }
 
-func (server *Server) initNotificationHandler(service *application.BellNotificationService, settingsService *application.NotificationSettingsService) *api.NotificationHandler {
-	return api.NewNotificationHandler(service, settingsService, server.traceProvider, server.loki)
+func (server *Server) initNotificationHandler(service *application.BellNotificationService, settingsService *application.NotificationSettingsService, dispatcher *application.NotificationDispatcher, deliveryLog *application.DeliveryLogService, escalations *application.EscalationService, deferredDeliveries *application.DeferredDeliveryService, digests *application.DigestService, hub *realtime.Hub, fanout realtime.Fanout) *api.NotificationHandler {
+	return api.NewNotificationHandler(service, settingsService, dispatcher, deliveryLog, escalations, deferredDeliveries, digests, hub, fanout, server.config.DispatchWorkers, server.traceProvider, server.loki)
 }
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"testing"
+	"time"
+)
+
+// fakePurgeStore counts the purges and runs duringPurge while one is in progress. It records
+// the retention filters notifications were counted and deleted with.
+type fakePurgeStore struct {
+	domain.BellNotificationStore
+	purges      int
+	duringPurge func()
+	counted     []domain.RetentionFilter
+	deleted     []domain.RetentionFilter
+}
+
+func (store *fakePurgeStore) PurgeDeleted(deletedBefore time.Time) (int64, error) {
+	store.purges++
+	if store.duringPurge != nil {
+		store.duringPurge()
+	}
+	return 0, nil
+}
+
+func (store *fakePurgeStore) CountExpired(filter domain.RetentionFilter) (int64, error) {
+	store.counted = append(store.counted, filter)
+	return 1, nil
+}
+
+func (store *fakePurgeStore) DeleteExpired(filter domain.RetentionFilter) (int64, error) {
+	store.deleted = append(store.deleted, filter)
+	return 1, nil
+}
+
+func TestPurgeJobRunsOnOneReplica(t *testing.T) {
+	store := &fakePurgeStore{}
+	jobs := &fakeJobStore{}
+	var schedulers []*application.JobScheduler
+	for _, owner := range []string{"replica-1", "replica-2"} {
+		purgeJob := application.NewBellNotificationPurgeJob(store, jobs, time.Hour, domain.RetentionPolicy{}, time.Hour, discardLoki{})
+		if err := purgeJob.Schedule(); err != nil {
+			t.Fatalf("Schedule() error = %v", err)
+		}
+		scheduler := newScheduler(jobs, owner)
+		scheduler.Register(domain.PurgeJob, purgeJob.Run)
+		schedulers = append(schedulers, scheduler)
+	}
+	if len(jobs.jobs) != 1 {
+		t.Fatalf("jobs = %d, want one purge job for every replica", len(jobs.jobs))
+	}
+
+	due := jobs.jobs[0].RunAt
+	store.duringPurge = func() {
+		store.duringPurge = nil
+		schedulers[1].RunDue(due)
+	}
+	schedulers[0].RunDue(due)
+	if store.purges != 1 {
+		t.Errorf("purged %d times, want once while another replica purges", store.purges)
+	}
+	if job := jobs.get(string(domain.PurgeJob)); job == nil || !job.RunAt.After(time.Now()) {
+		t.Errorf("purge job = %+v, want it scheduled again", job)
+	}
+}
+
+func TestRetentionDryRunOnlyCounts(t *testing.T) {
+	retention := domain.RetentionPolicy{
+		Default: domain.RetentionRule{Seen: 24 * time.Hour},
+		ByType:  map[domain.NotificationType]domain.RetentionRule{domain.NewReservationRequest: {Seen: time.Hour, Unseen: 2 * time.Hour}},
+	}
+
+	store := &fakePurgeStore{}
+	application.NewBellNotificationPurgeJob(store, &fakeJobStore{}, time.Hour, retention, time.Hour, discardLoki{}).EnforceRetention()
+	if len(store.counted) != 0 || len(store.deleted) != 3 {
+		t.Errorf("counted %d and deleted %d times, want the three rules enforced", len(store.counted), len(store.deleted))
+	}
+
+	retention.DryRun = true
+	store = &fakePurgeStore{}
+	application.NewBellNotificationPurgeJob(store, &fakeJobStore{}, time.Hour, retention, time.Hour, discardLoki{}).EnforceRetention()
+	if len(store.counted) != 3 || len(store.deleted) != 0 {
+		t.Errorf("counted %d and deleted %d times, want the three rules only counted in a dry run", len(store.counted), len(store.deleted))
+	}
+	for _, filter := range store.counted {
+		if filter.ExcludeTypes && !filter.Seen {
+			t.Errorf("counted unseen notifications of the default rule, which keeps them forever")
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/request"
+	"net/url"
+	"testing"
+)
+
+func TestBellNotificationQueryRequestPaging(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		limit int64
+	}{
+		{name: "unpaged", query: "", limit: 0},
+		{name: "filtered but unpaged", query: "seen=false", limit: 0},
+		{name: "limit", query: "limit=5", limit: 5},
+		{name: "before without limit", query: "before=5f1d7f9c3b9a4b2f8c6e1a2b", limit: domain.DefaultPageSize},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(test.query)
+			queryRequest, err := request.NewBellNotificationQueryRequest(values)
+			if err != nil {
+				t.Fatalf("NewBellNotificationQueryRequest() error = %v", err)
+			}
+			if err := queryRequest.AreValidRequestData(); err != nil {
+				t.Fatalf("AreValidRequestData() error = %v", err)
+			}
+			if limit := queryRequest.ToQuery().Limit; limit != test.limit {
+				t.Errorf("limit = %d, want %d", limit, test.limit)
+			}
+		})
+	}
+}
+
+func TestBellNotificationQueryRequestValidation(t *testing.T) {
+	tests := []struct {
+		query string
+		valid bool
+	}{
+		{query: "limit=0", valid: false},
+		{query: "limit=101", valid: false},
+		{query: "type=0,5", valid: true},
+		{query: "type=6", valid: false},
+		{query: "type=-1", valid: false},
+	}
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		queryRequest, err := request.NewBellNotificationQueryRequest(values)
+		if err != nil {
+			t.Fatalf("%s: NewBellNotificationQueryRequest() error = %v", test.query, err)
+		}
+		if err := queryRequest.AreValidRequestData(); (err == nil) != test.valid {
+			t.Errorf("%s: AreValidRequestData() error = %v, want valid %v", test.query, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/startup/config"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetentionRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  map[domain.NotificationType]domain.RetentionRule
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  map[domain.NotificationType]domain.RetentionRule{},
+		},
+		{
+			name:  "seen and unseen",
+			value: "0:seen=720h,unseen=2160h; 3:seen=24h",
+			want: map[domain.NotificationType]domain.RetentionRule{
+				domain.NewReservationRequest:  {Seen: 720 * time.Hour, Unseen: 2160 * time.Hour},
+				domain.NewAccommodationReview: {Seen: 24 * time.Hour},
+			},
+		},
+		{
+			name:  "unknown key",
+			value: "1:seen=24h,read=1h",
+			want: map[domain.NotificationType]domain.RetentionRule{
+				domain.CancelReservation: {Seen: 24 * time.Hour},
+			},
+		},
+		{
+			name:  "misspelled key",
+			value: "1:unsen=24h",
+			want:  map[domain.NotificationType]domain.RetentionRule{},
+		},
+		{
+			name:  "invalid duration",
+			value: "1:seen=a week,unseen=48h",
+			want: map[domain.NotificationType]domain.RetentionRule{
+				domain.CancelReservation: {Unseen: 48 * time.Hour},
+			},
+		},
+		{
+			name:  "unknown type",
+			value: "42:seen=24h;host:seen=24h;2",
+			want:  map[domain.NotificationType]domain.RetentionRule{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := config.RetentionRules(test.value); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("RetentionRules(%q) = %+v, want %+v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDigestHour(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{value: "", want: 8},
+		{value: "0", want: 0},
+		{value: "23", want: 23},
+		{value: "24", want: 8},
+		{value: "-1", want: 8},
+		{value: "noon", want: 8},
+	}
+	for _, test := range tests {
+		t.Setenv("DIGEST_HOUR", test.value)
+		if got := config.NewConfig().DigestHour; got != test.want {
+			t.Errorf("DIGEST_HOUR=%q: DigestHour = %d, want %d", test.value, got, test.want)
+		}
+	}
+}
+
+func TestEscalationRulesLeaveOutUnknownTypes(t *testing.T) {
+	t.Setenv("ESCALATION_RULES", "42:30m=email;host:30m=email;1:2h=push,30m=email")
+	want := domain.EscalationPolicy{
+		domain.CancelReservation: {{After: 30 * time.Minute, Channel: domain.EmailChannel}, {After: 2 * time.Hour, Channel: domain.PushChannel}},
+	}
+	if got := config.NewConfig().Escalation; !reflect.DeepEqual(got, want) {
+		t.Errorf("Escalation = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newDelivery returns the rendered notification of the guest's confirmed reservation, changed by
+// the overrides in order.
+func newDelivery(overrides ...func(delivery *domain.Delivery)) domain.Delivery {
+	delivery := domain.Delivery{
+		NotificationId: primitive.NewObjectID().Hex(),
+		Recipient:      domain.Recipient{UserId: "guest", Locale: "en"},
+		Type:           domain.ReviewReservation,
+		Payload:        domain.NotificationPayload{ReservationId: "42", Status: "accept-request"},
+		Message:        "The host has confirmed your reservation #42.",
+		RedirectId:     "reservation/view/42",
+	}
+	for _, override := range overrides {
+		override(&delivery)
+	}
+	return delivery
+}
+
+// unrendered leaves the message to be rendered by the dispatcher.
+func unrendered(delivery *domain.Delivery) {
+	delivery.Message = ""
+}
+
+// reservationRequest makes the delivery the host's notification of a new reservation request.
+func reservationRequest(delivery *domain.Delivery) {
+	delivery.Recipient = domain.Recipient{UserId: "host", Email: "host@zms.com", Locale: "en"}
+	delivery.Type = domain.NewReservationRequest
+	delivery.Payload = domain.NotificationPayload{ReservationId: "42"}
+	delivery.Message = "You have a new reservation request."
+}
+
+// hostReview makes the delivery the host's unrendered notification of a review of their profile.
+func hostReview(delivery *domain.Delivery) {
+	delivery.Recipient = domain.Recipient{UserId: "host", Email: "host@zms.com"}
+	delivery.Type = domain.NewHostReview
+	delivery.Payload = domain.NotificationPayload{ActorName: "Ana"}
+	delivery.Message = ""
+	delivery.RedirectId = "auth/view-profile/host"
+}
+
+func withRecipient(recipient domain.Recipient) func(delivery *domain.Delivery) {
+	return func(delivery *domain.Delivery) {
+		delivery.Recipient = recipient
+	}
+}
+
+func withMessage(message string) func(delivery *domain.Delivery) {
+	return func(delivery *domain.Delivery) {
+		delivery.Message = message
+	}
+}
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+type fakeDigestEventStore struct {
+	events    []*domain.DigestEvent
+	deleteErr error
+	// onRead runs once after the events of a digest are read.
+	onRead func()
+}
+
+func (store *fakeDigestEventStore) Insert(event *domain.DigestEvent) error {
+	stored := *event
+	stored.Id = primitive.NewObjectID()
+	store.events = append(store.events, &stored)
+	return nil
+}
+
+func (store *fakeDigestEventStore) GetByDigestKey(key string) ([]*domain.DigestEvent, error) {
+	var events []*domain.DigestEvent
+	for _, event := range store.events {
+		if event.DigestKey == key {
+			events = append(events, event)
+		}
+	}
+	if store.onRead != nil {
+		store.onRead()
+		store.onRead = nil
+	}
+	return events, nil
+}
+
+func (store *fakeDigestEventStore) DeleteByDigestKey(key string, until time.Time) (int64, error) {
+	if store.deleteErr != nil {
+		return 0, store.deleteErr
+	}
+	count := len(store.events)
+	store.events = slices.DeleteFunc(store.events, func(event *domain.DigestEvent) bool {
+		return event.DigestKey == key && !event.CreatedAt.After(until)
+	})
+	return int64(count - len(store.events)), nil
+}
+
+func TestNextDigestAt(t *testing.T) {
+	belgrade, _ := time.LoadLocation("Europe/Belgrade")
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	tests := []struct {
+		name      string
+		frequency domain.DigestFrequency
+		location  *time.Location
+		now       time.Time
+		want      time.Time
+	}{
+		{"hourly", domain.HourlyDigest, belgrade, utc("2026-06-10T10:20:00Z"), utc("2026-06-10T11:00:00Z")},
+		{"hourly in a half hour offset", domain.HourlyDigest, kolkata, utc("2026-06-10T10:20:00Z"), utc("2026-06-10T10:30:00Z")},
+		{"daily before the hour", domain.DailyDigest, belgrade, utc("2026-06-10T05:00:00Z"), utc("2026-06-10T06:00:00Z")},
+		{"daily at the hour", domain.DailyDigest, belgrade, utc("2026-06-10T06:00:00Z"), utc("2026-06-11T06:00:00Z")},
+		{"daily after the local midnight", domain.DailyDigest, belgrade, utc("2026-06-10T22:30:00Z"), utc("2026-06-11T06:00:00Z")},
+		{"daily across spring forward", domain.DailyDigest, belgrade, utc("2026-03-28T12:00:00Z"), utc("2026-03-29T06:00:00Z")},
+		{"weekly on Wednesday", domain.WeeklyDigest, belgrade, utc("2026-06-10T12:00:00Z"), utc("2026-06-15T06:00:00Z")},
+		{"weekly on Monday before the hour", domain.WeeklyDigest, belgrade, utc("2026-06-15T05:00:00Z"), utc("2026-06-15T06:00:00Z")},
+		{"weekly on Monday after the hour", domain.WeeklyDigest, belgrade, utc("2026-06-15T07:00:00Z"), utc("2026-06-22T06:00:00Z")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.frequency.NextDigestAt(tt.now, tt.location, 8); !got.Equal(tt.want) {
+				t.Errorf("NextDigestAt() = %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+type digestFixture struct {
+	jobFixture
+	events   *fakeDigestEventStore
+	bell     *fakeBellNotificationStore
+	settings *domain.Settings
+	email    *fakeNotifier
+	service  *application.DigestService
+}
+
+func newDigestFixture(t *testing.T) *digestFixture {
+	fixture := &digestFixture{
+		events: &fakeDigestEventStore{},
+		bell:   &fakeBellNotificationStore{notifications: map[primitive.ObjectID]*domain.BellNotification{}},
+		settings: &domain.Settings{
+			UserId:   "host",
+			Email:    "host@zms.com",
+			TimeZone: "Europe/Belgrade",
+			Settings: []domain.NotificationSetting{{
+				Type:     domain.NewAccommodationReview,
+				Active:   true,
+				Channels: map[domain.Channel]bool{domain.BellChannel: true, domain.EmailChannel: true, domain.WebhookChannel: true},
+				Digest:   domain.DailyDigest,
+			}},
+		},
+		email: &fakeNotifier{channel: domain.EmailChannel},
+	}
+	fixture.jobFixture = newJobFixture(t, fixture.settings, domain.DigestJob, func(wiring jobFixture) application.JobHandler {
+		bellService := application.NewBellNotificationService(fixture.bell, wiring.messages, &http.Client{}, discardLoki{}, time.Hour)
+		fixture.service = application.NewDigestService(fixture.events, wiring.jobs, bellService, wiring.settingsService, wiring.dispatcher, wiring.deliveryLog, 8, discardLoki{})
+		return fixture.service.Deliver
+	}, fixture.email)
+	return fixture
+}
+
+func (fixture *digestFixture) collect(t *testing.T, count int) {
+	span := trace.SpanFromContext(context.Background())
+	for range count {
+		delivery := newDelivery(hostReview)
+		delivery.Type = domain.NewAccommodationReview
+		delivery.RedirectId = "accommodation/7"
+		immediate, err := fixture.service.Collect(delivery, []domain.Channel{domain.BellChannel, domain.EmailChannel, domain.WebhookChannel}, fixture.settings, span, discardLoki{})
+		if err != nil {
+			t.Fatalf("Collect() error = %v", err)
+		}
+		if !slices.Equal(immediate, []domain.Channel{domain.WebhookChannel}) {
+			t.Fatalf("immediate channels = %v, want only the webhook", immediate)
+		}
+	}
+}
+
+func TestCollectSchedulesOneDigestPerPeriod(t *testing.T) {
+	fixture := newDigestFixture(t)
+	fixture.collect(t, 3)
+
+	if len(fixture.jobs.jobs) != 1 || len(fixture.events.events) != 3 {
+		t.Fatalf("jobs = %d, events = %d, want one digest of three events", len(fixture.jobs.jobs), len(fixture.events.events))
+	}
+	want := domain.DailyDigest.NextDigestAt(time.Now(), fixture.settings.Location(), 8)
+	if job := fixture.jobs.jobs[0]; !job.RunAt.Equal(want) || job.RunAt.In(fixture.settings.Location()).Hour() != 8 {
+		t.Errorf("digest runs at %s, want 8:00 in the user's time zone", job.RunAt)
+	}
+}
+
+func TestCollectWithoutDigestDeliversRightAway(t *testing.T) {
+	fixture := newDigestFixture(t)
+	fixture.settings.Settings[0].Digest = ""
+	channels := []domain.Channel{domain.BellChannel, domain.EmailChannel}
+
+	immediate, err := fixture.service.Collect(newDelivery(hostReview), channels, fixture.settings, trace.SpanFromContext(context.Background()), discardLoki{})
+	if err != nil || !slices.Equal(immediate, channels) || len(fixture.jobs.jobs) != 0 {
+		t.Errorf("Collect() = %v, %v with %d jobs, want every channel right away", immediate, err, len(fixture.jobs.jobs))
+	}
+}
+
+func TestDigestSummarizesCollectedEvents(t *testing.T) {
+	fixture := newDigestFixture(t)
+	fixture.collect(t, 2)
+	job := *fixture.jobs.jobs[0]
+
+	fixture.scheduler.RunDue(job.RunAt)
+	notification := fixture.bell.notifications[job.NotificationId]
+	if notification == nil || notification.Type != domain.Digest || notification.Payload.Count != 2 || notification.RedirectId != "accommodation/7" {
+		t.Fatalf("digest notification = %+v, want a digest of two reviews", notification)
+	}
+	if len(fixture.email.sent) != 1 || fixture.email.sent[0].Message != "Your accommodations have 2 new reviews." {
+		t.Errorf("emails = %+v, want the rendered digest", fixture.email.sent)
+	}
+	if len(fixture.events.events) != 0 || len(fixture.jobs.jobs) != 0 {
+		t.Error("collected events or the digest job are left after delivery")
+	}
+}
+
+func TestDigestKeepsEventsCollectedLater(t *testing.T) {
+	fixture := newDigestFixture(t)
+	fixture.collect(t, 1)
+	job := *fixture.jobs.jobs[0]
+	late := *fixture.events.events[0]
+	late.CreatedAt = late.CreatedAt.Add(time.Second)
+	fixture.events.onRead = func() {
+		fixture.events.events = append(fixture.events.events, &late)
+	}
+
+	fixture.scheduler.RunDue(job.RunAt)
+	if len(fixture.events.events) != 1 || fixture.events.events[0] != &late {
+		t.Errorf("events left = %+v, want only the one collected during delivery", fixture.events.events)
+	}
+}
+
+func TestDigestIsStoredOnceWhenRunAgain(t *testing.T) {
+	fixture := newDigestFixture(t)
+	fixture.collect(t, 2)
+	job := *fixture.jobs.jobs[0]
+
+	fixture.events.deleteErr = errors.New("connection reset")
+	fixture.scheduler.RunDue(job.RunAt)
+	fixture.events.deleteErr = nil
+	restarted := newScheduler(fixture.jobs, "replica-2")
+	restarted.Register(domain.DigestJob, fixture.service.Deliver)
+	restarted.RunDue(job.RunAt.Add(time.Hour))
+
+	if len(fixture.bell.notifications) != 1 {
+		t.Errorf("stored %d digest notifications, want one", len(fixture.bell.notifications))
+	}
+	if len(fixture.email.sent) != 1 {
+		t.Errorf("sent %d digest emails, want one", len(fixture.email.sent))
+	}
+	if len(fixture.events.events) != 0 || len(fixture.jobs.jobs) != 0 {
+		t.Error("the digest was not completed when run again")
+	}
+}
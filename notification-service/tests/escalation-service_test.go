@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"context"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+	"time"
+)
+
+// fakeBellNotificationStore only serves the lookups escalation needs and the inserts of digests.
+type fakeBellNotificationStore struct {
+	domain.BellNotificationStore
+	notifications map[primitive.ObjectID]*domain.BellNotification
+}
+
+func (store *fakeBellNotificationStore) Insert(notification *domain.BellNotification) (primitive.ObjectID, error) {
+	if _, ok := store.notifications[notification.Id]; ok {
+		return primitive.NilObjectID, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}
+	}
+	store.notifications[notification.Id] = notification
+	return notification.Id, nil
+}
+
+func (store *fakeBellNotificationStore) Get(userId string, id primitive.ObjectID) (*domain.BellNotification, error) {
+	notification, ok := store.notifications[id]
+	if !ok || notification.UserId != userId {
+		return nil, mongo.ErrNoDocuments
+	}
+	return notification, nil
+}
+
+// fakeSettingsStore only serves the lookups escalation needs and updates of the settings.
+type fakeSettingsStore struct {
+	domain.UserNotificationSettingsStore
+	settings map[string]*domain.Settings
+}
+
+func (store *fakeSettingsStore) Update(id primitive.ObjectID, updated *domain.Settings) error {
+	for _, settings := range store.settings {
+		if settings.Id == id {
+			settings.Settings = updated.Settings
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (store *fakeSettingsStore) GetByUserId(id string) (*domain.Settings, error) {
+	settings, ok := store.settings[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return settings, nil
+}
+
+type escalationFixture struct {
+	jobFixture
+	notification *domain.BellNotification
+	settings     *domain.Settings
+	email        *fakeNotifier
+	push         *fakeNotifier
+	service      *application.EscalationService
+}
+
+func newEscalationFixture(t *testing.T) *escalationFixture {
+	fixture := &escalationFixture{
+		notification: &domain.BellNotification{
+			Id:         primitive.NewObjectID(),
+			UserId:     "host",
+			Type:       domain.NewReservationRequest,
+			Payload:    domain.NotificationPayload{ReservationId: "42", ActorName: "Ana"},
+			TimeStamp:  time.Now(),
+			RedirectId: domain.ReservationRedirectUrlStart + "42",
+		},
+		settings: &domain.Settings{
+			UserId:   "host",
+			Email:    "host@zms.com",
+			Settings: []domain.NotificationSetting{{Type: domain.NewReservationRequest, Active: true, Channels: map[domain.Channel]bool{domain.BellChannel: true}, Escalate: true}},
+		},
+		email: &fakeNotifier{channel: domain.EmailChannel},
+		push:  &fakeNotifier{channel: domain.PushChannel},
+	}
+
+	bellStore := &fakeBellNotificationStore{notifications: map[primitive.ObjectID]*domain.BellNotification{fixture.notification.Id: fixture.notification}}
+	policy := domain.EscalationPolicy{domain.NewReservationRequest: {
+		{After: 30 * time.Minute, Channel: domain.EmailChannel},
+		{After: 2 * time.Hour, Channel: domain.PushChannel},
+	}}
+	fixture.jobFixture = newJobFixture(t, fixture.settings, domain.EscalationJob, func(wiring jobFixture) application.JobHandler {
+		fixture.service = application.NewEscalationService(wiring.jobs, bellStore, wiring.settingsService, wiring.dispatcher, policy, discardLoki{})
+		return fixture.service.Escalate
+	}, fixture.email, fixture.push)
+
+	span := trace.SpanFromContext(context.Background())
+	if err := fixture.service.Schedule(fixture.notification.Id, "host", domain.NewReservationRequest, fixture.notification.TimeStamp, span, discardLoki{}); err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	return fixture
+}
+
+func (fixture *escalationFixture) runAfter(after time.Duration) {
+	fixture.scheduler.RunDue(fixture.notification.TimeStamp.Add(after))
+}
+
+func TestEscalationDeliversOverNextChannelsWhileUnseen(t *testing.T) {
+	fixture := newEscalationFixture(t)
+
+	fixture.runAfter(29 * time.Minute)
+	if len(fixture.email.sent) != 0 {
+		t.Fatal("escalated before the first step was due")
+	}
+	fixture.runAfter(30 * time.Minute)
+	if len(fixture.email.sent) != 1 || len(fixture.push.sent) != 0 {
+		t.Fatalf("after 30m sent %d emails and %d pushes, want only the email", len(fixture.email.sent), len(fixture.push.sent))
+	}
+	if sent := fixture.email.sent[0]; sent.NotificationId != fixture.notification.Id.Hex() || sent.Recipient.Email != "host@zms.com" || sent.Message == "" {
+		t.Errorf("escalated delivery = %+v, want the rendered notification for the host", sent)
+	}
+	fixture.runAfter(2 * time.Hour)
+	if len(fixture.email.sent) != 1 || len(fixture.push.sent) != 1 {
+		t.Errorf("after 2h sent %d emails and %d pushes, want one of each", len(fixture.email.sent), len(fixture.push.sent))
+	}
+	if len(fixture.jobs.jobs) != 0 {
+		t.Errorf("jobs = %d, want the escalation done after its last step", len(fixture.jobs.jobs))
+	}
+}
+
+func TestEscalationStopsOnceSeen(t *testing.T) {
+	fixture := newEscalationFixture(t)
+	fixture.runAfter(30 * time.Minute)
+
+	fixture.notification.Seen = true
+	fixture.runAfter(2 * time.Hour)
+	if len(fixture.push.sent) != 0 || len(fixture.jobs.jobs) != 0 {
+		t.Errorf("escalated a seen notification over push")
+	}
+}
+
+func TestEscalationIsCancelledWhenMarkedSeen(t *testing.T) {
+	fixture := newEscalationFixture(t)
+	span := trace.SpanFromContext(context.Background())
+
+	if err := fixture.service.Cancel([]primitive.ObjectID{fixture.notification.Id}, span, discardLoki{}); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if len(fixture.jobs.jobs) != 0 {
+		t.Error("escalation job is still scheduled")
+	}
+}
+
+func TestEscalationSkipsChannelsTheUserAlreadyReceives(t *testing.T) {
+	fixture := newEscalationFixture(t)
+	fixture.settings.Settings[0].Channels[domain.EmailChannel] = true
+
+	fixture.runAfter(30 * time.Minute)
+	fixture.runAfter(2 * time.Hour)
+	if len(fixture.email.sent) != 0 || len(fixture.push.sent) != 1 {
+		t.Errorf("sent %d emails and %d pushes, want only the push", len(fixture.email.sent), len(fixture.push.sent))
+	}
+}
+
+func TestEscalationNeedsOptIn(t *testing.T) {
+	fixture := newEscalationFixture(t)
+	fixture.settings.Settings[0].Escalate = false
+
+	fixture.runAfter(2 * time.Hour)
+	if len(fixture.email.sent) != 0 || len(fixture.push.sent) != 0 || len(fixture.jobs.jobs) != 0 {
+		t.Errorf("sent %d emails and %d pushes, want no escalation without the opt-in", len(fixture.email.sent), len(fixture.push.sent))
+	}
+}
+
+func TestEscalationSurvivesRestart(t *testing.T) {
+	fixture := newEscalationFixture(t)
+
+	restarted := newScheduler(fixture.jobs, "replica-2")
+	restarted.Register(domain.EscalationJob, fixture.service.Escalate)
+	restarted.RunDue(fixture.notification.TimeStamp.Add(time.Hour))
+	if len(fixture.email.sent) != 1 {
+		t.Errorf("a new replica sent %d emails, want the pending escalation", len(fixture.email.sent))
+	}
+}
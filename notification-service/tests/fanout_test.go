@@ -0,0 +1,29 @@
+package tests
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/realtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFanoutDeliversThroughEveryReplica(t *testing.T) {
+	fanout := realtime.NewInMemoryFanout()
+	var replicas []*openStream
+	for range 2 {
+		hub := newHub(time.Minute)
+		if err := fanout.Subscribe(hub.SendToUser); err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		replicas = append(replicas, serveStream(t, hub, "host"))
+	}
+
+	if err := fanout.Publish("host", notificationMessage(1)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	for replica, stream := range replicas {
+		if event := stream.next(t); !strings.Contains(event, "id: 1\n") {
+			t.Errorf("replica %d streamed %q, want notification 1", replica, event)
+		}
+	}
+}
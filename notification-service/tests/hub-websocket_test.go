@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/realtime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newWebSocketServer serves the hub over WebSocket, taking the user from the user query parameter.
+func newWebSocketServer(t *testing.T, hub *realtime.Hub, replay realtime.ReplayFunc) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Serve(conn, r.URL.Query().Get("user"), replay, nil)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func dial(server *httptest.Server, userId string) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/?user="+userId, nil)
+	return conn, err
+}
+
+// readIds reads the ids of count notifications from the connection.
+func readIds(conn *websocket.Conn, count int) ([]string, error) {
+	ids := make([]string, 0, count)
+	for len(ids) < count {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, strings.TrimSuffix(strings.TrimPrefix(string(data), `{"id":"`), `"}`))
+	}
+	return ids, nil
+}
+
+func closeGracefully(conn *websocket.Conn) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	_ = conn.Close()
+}
+
+func TestHubServesManyConcurrentWebSocketClients(t *testing.T) {
+	const users, connectionsPerUser, messagesPerUser = 10, 5, 50
+	hub := newHub(time.Minute)
+	server := newWebSocketServer(t, hub, nil)
+
+	conns := make([][]*websocket.Conn, users)
+	var dialing sync.WaitGroup
+	for user := range users {
+		conns[user] = make([]*websocket.Conn, connectionsPerUser)
+		for i := range connectionsPerUser {
+			dialing.Add(1)
+			go func() {
+				defer dialing.Done()
+				conn, err := dial(server, fmt.Sprintf("user-%d", user))
+				if err != nil {
+					t.Errorf("dial() error = %v", err)
+					return
+				}
+				conns[user][i] = conn
+			}()
+		}
+	}
+	dialing.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+	for user := range users {
+		waitForConnections(t, hub, fmt.Sprintf("user-%d", user), connectionsPerUser)
+	}
+
+	// Other clients come and go, closing with and without a handshake, while messages are broadcast.
+	var churning sync.WaitGroup
+	for i := range 20 {
+		churning.Add(1)
+		go func() {
+			defer churning.Done()
+			conn, err := dial(server, fmt.Sprintf("churn-%d", i%4))
+			if err != nil {
+				t.Errorf("dial() error = %v", err)
+				return
+			}
+			hub.SendToUser(fmt.Sprintf("churn-%d", i%4), notificationMessage(i))
+			if i%2 == 0 {
+				closeGracefully(conn)
+			} else {
+				_ = conn.UnderlyingConn().Close()
+			}
+		}()
+	}
+
+	var reading sync.WaitGroup
+	for user := range users {
+		for _, conn := range conns[user] {
+			reading.Add(1)
+			go func() {
+				defer reading.Done()
+				ids, err := readIds(conn, messagesPerUser)
+				if err != nil {
+					t.Errorf("user-%d read %d messages, then error = %v", user, len(ids), err)
+					return
+				}
+				for n, id := range ids {
+					if want := fmt.Sprintf("%d-%d", user, n); id != want {
+						t.Errorf("user-%d message %d = %s, want %s", user, n, id, want)
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			for n := range messagesPerUser {
+				hub.SendToUser(fmt.Sprintf("user-%d", user), realtime.Message{Data: []byte(fmt.Sprintf(`{"id":"%d-%d"}`, user, n))})
+			}
+		}()
+	}
+	reading.Wait()
+	churning.Wait()
+
+	for user := range users {
+		for _, conn := range conns[user] {
+			closeGracefully(conn)
+		}
+	}
+	for user := range users {
+		waitForConnections(t, hub, fmt.Sprintf("user-%d", user), 0)
+	}
+	for i := range 4 {
+		waitForConnections(t, hub, fmt.Sprintf("churn-%d", i), 0)
+	}
+}
+
+func TestHubEvictsWebSocketClientsWhichDisappear(t *testing.T) {
+	const pongWait = 300 * time.Millisecond
+	hub := newHub(pongWait)
+	server := newWebSocketServer(t, hub, nil)
+
+	healthy, err := dial(server, "host")
+	if err != nil {
+		t.Fatalf("dial() error = %v", err)
+	}
+	defer closeGracefully(healthy)
+	received := make(chan string)
+	go func() {
+		for {
+			_, data, err := healthy.ReadMessage()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- string(data)
+		}
+	}()
+
+	// The silent client keeps reading but never answers pings.
+	silent, err := dial(server, "host")
+	if err != nil {
+		t.Fatalf("dial() error = %v", err)
+	}
+	defer silent.Close()
+	silent.SetPingHandler(func(string) error { return nil })
+	silentClosed := make(chan struct{})
+	go func() {
+		for {
+			if _, _, err := silent.ReadMessage(); err != nil {
+				close(silentClosed)
+				return
+			}
+		}
+	}()
+
+	vanished, err := dial(server, "host")
+	if err != nil {
+		t.Fatalf("dial() error = %v", err)
+	}
+	waitForConnections(t, hub, "host", 3)
+
+	// The vanished client drops its socket without a close handshake before a message is sent.
+	_ = vanished.UnderlyingConn().Close()
+	hub.SendToUser("host", notificationMessage(1))
+	if message := <-received; !strings.Contains(message, `"1"`) {
+		t.Fatalf("healthy client received %q, want notification 1", message)
+	}
+
+	waitForConnections(t, hub, "host", 1)
+	select {
+	case <-silentClosed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection of the client which does not answer pings is still open")
+	}
+
+	// The healthy client answered the pings and outlived several pong waits.
+	time.Sleep(3 * pongWait)
+	hub.SendToUser("host", notificationMessage(2))
+	select {
+	case message, ok := <-received:
+		if !ok || !strings.Contains(message, `"2"`) {
+			t.Errorf("healthy client received %q, want notification 2", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("healthy client was evicted")
+	}
+	if connections := hub.Connections("host"); connections != 1 {
+		t.Errorf("host has %d connections, want only the healthy one", connections)
+	}
+}
+
+func TestReplayHandsOverToLiveDeliveryWithoutDuplicatesOrGaps(t *testing.T) {
+	hub := newHub(time.Minute)
+	// Notifications 4 and 5 are created while the replay is loaded, so they are replayed and
+	// delivered live too, followed by newer live ones.
+	replay := func() ([]realtime.Message, error) {
+		for id := 4; id <= 8; id++ {
+			hub.SendToUser("host", notificationMessage(id))
+		}
+		var replayed []realtime.Message
+		for id := 1; id <= 5; id++ {
+			replayed = append(replayed, notificationMessage(id))
+		}
+		return replayed, nil
+	}
+	server := newWebSocketServer(t, hub, replay)
+
+	conn, err := dial(server, "host")
+	if err != nil {
+		t.Fatalf("dial() error = %v", err)
+	}
+	defer closeGracefully(conn)
+	ids, err := readIds(conn, 8)
+	if err != nil {
+		t.Fatalf("read %v, then error = %v", ids, err)
+	}
+	hub.SendToUser("host", notificationMessage(9))
+	next, err := readIds(conn, 1)
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	if got, want := strings.Join(append(ids, next...), ","), "1,2,3,4,5,6,7,8,9"; got != want {
+		t.Errorf("received %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/realtime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamRecorder hands every flushed chunk of an event stream to the test, so a stream whose
+// chunks are not taken stands in for a client which does not keep up.
+type streamRecorder struct {
+	header  http.Header
+	buffer  strings.Builder
+	flushes chan string
+}
+
+func (recorder *streamRecorder) Header() http.Header            { return recorder.header }
+func (recorder *streamRecorder) WriteHeader(statusCode int)     {}
+func (recorder *streamRecorder) Write(data []byte) (int, error) { return recorder.buffer.Write(data) }
+
+func (recorder *streamRecorder) Flush() {
+	recorder.flushes <- recorder.buffer.String()
+	recorder.buffer.Reset()
+}
+
+type openStream struct {
+	recorder *streamRecorder
+	close    context.CancelFunc
+	done     chan error
+}
+
+// serveStream opens an event stream of the user, replaying the given messages, and returns once
+// the hub has registered it.
+func serveStream(t *testing.T, hub *realtime.Hub, userId string, replayed ...realtime.Message) *openStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &openStream{
+		recorder: &streamRecorder{header: http.Header{}, flushes: make(chan string)},
+		close:    cancel,
+		done:     make(chan error, 1),
+	}
+	registered := make(chan struct{})
+	replay := func() ([]realtime.Message, error) {
+		close(registered)
+		return replayed, nil
+	}
+	r := httptest.NewRequest(http.MethodGet, "/notification/"+userId+"/stream", nil).WithContext(ctx)
+	go func() { stream.done <- hub.ServeStream(stream.recorder, r, userId, replay, nil) }()
+
+	stream.next(t)
+	select {
+	case <-registered:
+	case <-time.After(time.Second):
+		t.Fatalf("stream of %s was not registered", userId)
+	}
+	t.Cleanup(cancel)
+	return stream
+}
+
+func (stream *openStream) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case chunk := <-stream.recorder.flushes:
+		return chunk
+	case <-time.After(time.Second):
+		t.Fatal("no event was streamed")
+		return ""
+	}
+}
+
+func (stream *openStream) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-stream.done:
+	case <-time.After(time.Second):
+		t.Fatal("stream is still served")
+	}
+}
+
+func notificationMessage(id int) realtime.Message {
+	return realtime.Message{Id: strconv.Itoa(id), Type: realtime.NotificationCreatedEvent, Data: []byte(`{"id":"` + strconv.Itoa(id) + `"}`)}
+}
+
+func waitForConnections(t *testing.T, hub *realtime.Hub, userId string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Connections(userId) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s has %d connections, want %d", userId, hub.Connections(userId), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newHub(pongWait time.Duration) *realtime.Hub {
+	hub := realtime.NewHub(pongWait)
+	go hub.Run()
+	return hub
+}
+
+func TestHubRegistersAndUnregistersConnections(t *testing.T) {
+	hub := newHub(time.Minute)
+	first := serveStream(t, hub, "host")
+	serveStream(t, hub, "host")
+	serveStream(t, hub, "guest")
+	if connections := hub.Connections("host"); connections != 2 {
+		t.Fatalf("host has %d connections, want 2", connections)
+	}
+
+	first.close()
+	first.wait(t)
+	if connections := hub.Connections("host"); connections != 1 {
+		t.Errorf("host has %d connections after closing one, want 1", connections)
+	}
+	if connections := hub.Connections("guest"); connections != 1 {
+		t.Errorf("guest has %d connections, want the other user's untouched", connections)
+	}
+}
+
+func TestHubBroadcastsToEveryConnectionOfTheUser(t *testing.T) {
+	hub := newHub(time.Minute)
+	host := []*openStream{serveStream(t, hub, "host"), serveStream(t, hub, "host")}
+	guest := serveStream(t, hub, "guest")
+
+	hub.SendToUser("host", notificationMessage(1))
+	hub.SendToUser("guest", notificationMessage(2))
+	for _, stream := range host {
+		if event := stream.next(t); !strings.Contains(event, "id: 1\n") || !strings.Contains(event, "event: "+realtime.NotificationCreatedEvent+"\n") {
+			t.Errorf("host streamed %q, want notification 1", event)
+		}
+	}
+	if event := guest.next(t); !strings.Contains(event, "id: 2\n") {
+		t.Errorf("guest streamed %q, want only its own notification 2", event)
+	}
+}
+
+func TestHubDropsConnectionsWhichDoNotKeepUp(t *testing.T) {
+	hub := newHub(time.Minute)
+	slow := serveStream(t, hub, "host")
+
+	// The slow stream holds the first message while its queue fills up with the rest.
+	for id := 0; id < 100; id++ {
+		hub.SendToUser("host", notificationMessage(id))
+	}
+	waitForConnections(t, hub, "host", 0)
+
+	go func() {
+		for range slow.recorder.flushes {
+		}
+	}()
+	slow.wait(t)
+
+	reconnected := serveStream(t, hub, "host")
+	hub.SendToUser("host", notificationMessage(100))
+	if event := reconnected.next(t); !strings.Contains(event, "id: 100\n") {
+		t.Errorf("reconnected stream streamed %q, want notification 100", event)
+	}
+}
+
+func TestHubKeepsConnectionsWhichAreSlowToReplay(t *testing.T) {
+	hub := newHub(time.Minute)
+	var replayed []realtime.Message
+	for id := range 5 {
+		replayed = append(replayed, notificationMessage(id))
+	}
+	stream := serveStream(t, hub, "host", replayed...)
+	// Messages are delivered in order, so once the guest streams one the host has been sent the
+	// ones before it.
+	guest := serveStream(t, hub, "guest")
+
+	// Every replayed message is written while more live ones arrive than the send queue holds.
+	live := 5
+	var streamed []string
+	for range replayed {
+		for range 25 {
+			hub.SendToUser("host", notificationMessage(live))
+			live++
+		}
+		hub.SendToUser("guest", notificationMessage(live))
+		guest.next(t)
+		streamed = append(streamed, stream.next(t))
+	}
+	for len(streamed) < live {
+		streamed = append(streamed, stream.next(t))
+	}
+
+	for id, event := range streamed {
+		if !strings.Contains(event, "id: "+strconv.Itoa(id)+"\n") {
+			t.Fatalf("event %d = %q, want notification %d", id, event, id)
+		}
+	}
+	if connections := hub.Connections("host"); connections != 1 {
+		t.Errorf("host has %d connections, want the replaying one kept", connections)
+	}
+}
@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"errors"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/trace"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeJobStore struct {
+	mutex sync.Mutex
+	jobs  []*domain.Job
+}
+
+func (store *fakeJobStore) Schedule(job *domain.Job) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.find(job.Key) != nil {
+		return false, nil
+	}
+	stored := *job
+	stored.Id = primitive.NewObjectID()
+	store.jobs = append(store.jobs, &stored)
+	return true, nil
+}
+
+func (store *fakeJobStore) Claim(owner string, now time.Time, lease time.Duration) (*domain.Job, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	var due *domain.Job
+	for _, job := range store.jobs {
+		if job.RunAt.After(now) || job.LockedUntil.After(now) {
+			continue
+		}
+		if due == nil || job.RunAt.Before(due.RunAt) {
+			due = job
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+	due.LockedBy, due.LockedUntil = owner, now.Add(lease)
+	due.Attempts++
+	claimed := *due
+	return &claimed, nil
+}
+
+func (store *fakeJobStore) ExtendLease(job *domain.Job, owner string, until time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, stored := range store.jobs {
+		if stored.Id == job.Id && stored.LockedBy == owner {
+			stored.LockedUntil = until
+		}
+	}
+	return nil
+}
+
+func (store *fakeJobStore) Reschedule(job *domain.Job, owner string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, stored := range store.jobs {
+		if stored.Id == job.Id && stored.LockedBy == owner {
+			stored.RunAt, stored.Step, stored.Attempts, stored.LastError = job.RunAt, job.Step, job.Attempts, job.LastError
+			stored.LockedBy, stored.LockedUntil = "", time.Time{}
+		}
+	}
+	return nil
+}
+
+func (store *fakeJobStore) Complete(job *domain.Job, owner string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.jobs = slices.DeleteFunc(store.jobs, func(stored *domain.Job) bool {
+		return stored.Id == job.Id && stored.LockedBy == owner
+	})
+	return nil
+}
+
+func (store *fakeJobStore) CancelByKeys(keys []string) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	count := len(store.jobs)
+	store.jobs = slices.DeleteFunc(store.jobs, func(stored *domain.Job) bool {
+		return slices.Contains(keys, stored.Key)
+	})
+	return int64(count - len(store.jobs)), nil
+}
+
+func (store *fakeJobStore) CancelByUserId(userId string, kind domain.JobKind) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	count := len(store.jobs)
+	store.jobs = slices.DeleteFunc(store.jobs, func(stored *domain.Job) bool {
+		return stored.UserId == userId && stored.Kind == kind
+	})
+	return int64(count - len(store.jobs)), nil
+}
+
+func (store *fakeJobStore) get(key string) *domain.Job {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.find(key)
+}
+
+func (store *fakeJobStore) find(key string) *domain.Job {
+	for _, job := range store.jobs {
+		if job.Key == key {
+			return job
+		}
+	}
+	return nil
+}
+
+const testJob domain.JobKind = "test"
+
+func newScheduler(store domain.JobStore, owner string) *application.JobScheduler {
+	return application.NewJobScheduler(store, owner, time.Minute, 5*time.Minute, 3, trace.NewNoopTracerProvider().Tracer(""), discardLoki{})
+}
+
+func TestSchedulerRunsDueJobsOnce(t *testing.T) {
+	store := &fakeJobStore{}
+	now := time.Now()
+	_, _ = store.Schedule(&domain.Job{Key: "later", Kind: testJob, RunAt: now.Add(time.Hour)})
+	_, _ = store.Schedule(&domain.Job{Key: "due", Kind: testJob, RunAt: now.Add(-time.Minute)})
+	if scheduled, _ := store.Schedule(&domain.Job{Key: "due", Kind: testJob, RunAt: now}); scheduled {
+		t.Error("Schedule() scheduled a second job with the same key")
+	}
+
+	var ran []string
+	scheduler := newScheduler(store, "replica-1")
+	scheduler.Register(testJob, func(job *domain.Job, span trace.Span) (*domain.Job, error) {
+		ran = append(ran, job.Key)
+		return nil, nil
+	})
+	scheduler.RunDue(now)
+	scheduler.RunDue(now)
+
+	if !slices.Equal(ran, []string{"due"}) {
+		t.Errorf("ran %v, want only the due job once", ran)
+	}
+	if store.get("due") != nil || store.get("later") == nil {
+		t.Error("the due job was not completed or the later one was")
+	}
+}
+
+func TestSchedulerReschedulesJobsWithMoreWork(t *testing.T) {
+	store := &fakeJobStore{}
+	now := time.Now()
+	_, _ = store.Schedule(&domain.Job{Key: "steps", Kind: testJob, RunAt: now})
+
+	scheduler := newScheduler(store, "replica-1")
+	scheduler.Register(testJob, func(job *domain.Job, span trace.Span) (*domain.Job, error) {
+		job.Step++
+		job.RunAt = now.Add(time.Hour)
+		return job, nil
+	})
+	scheduler.RunDue(now)
+
+	job := store.get("steps")
+	if job == nil || job.Step != 1 || !job.RunAt.Equal(now.Add(time.Hour)) || job.LockedBy != "" || job.Attempts != 0 {
+		t.Errorf("rescheduled job = %+v, want the next step an hour later, released", job)
+	}
+}
+
+func TestSchedulerRetriesFailedJobsWithBackoff(t *testing.T) {
+	store := &fakeJobStore{}
+	now := time.Now()
+	_, _ = store.Schedule(&domain.Job{Key: "flaky", Kind: testJob, RunAt: now})
+
+	runs := 0
+	scheduler := newScheduler(store, "replica-1")
+	scheduler.Register(testJob, func(job *domain.Job, span trace.Span) (*domain.Job, error) {
+		runs++
+		return nil, errors.New("transport unavailable")
+	})
+
+	scheduler.RunDue(now)
+	job := store.get("flaky")
+	if job == nil || !job.RunAt.Equal(now.Add(time.Minute)) || job.LastError != "transport unavailable" {
+		t.Fatalf("job after first failure = %+v, want a retry a minute later", job)
+	}
+	scheduler.RunDue(now.Add(time.Minute))
+	if job := store.get("flaky"); job == nil || !job.RunAt.Equal(now.Add(3*time.Minute)) {
+		t.Fatalf("job after second failure = %+v, want a retry two minutes later", job)
+	}
+	scheduler.RunDue(now.Add(3 * time.Minute))
+	if runs != 3 || store.get("flaky") != nil {
+		t.Errorf("runs = %d, job = %+v, want the job dropped after 3 attempts", runs, store.get("flaky"))
+	}
+}
+
+func TestSchedulerReclaimsJobsWhoseLeaseExpired(t *testing.T) {
+	store := &fakeJobStore{}
+	now := time.Now()
+	_, _ = store.Schedule(&domain.Job{Key: "orphaned", Kind: testJob, RunAt: now})
+	if _, err := store.Claim("crashed-replica", now, 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := 0
+	scheduler := newScheduler(store, "replica-2")
+	scheduler.Register(testJob, func(job *domain.Job, span trace.Span) (*domain.Job, error) {
+		runs++
+		return nil, nil
+	})
+	scheduler.RunDue(now.Add(time.Minute))
+	if runs != 0 {
+		t.Fatal("claimed a job leased to another replica")
+	}
+	scheduler.RunDue(now.Add(5 * time.Minute))
+	if runs != 1 || store.get("orphaned") != nil {
+		t.Errorf("runs = %d, want the job run once its lease expired", runs)
+	}
+}
+
+func TestSchedulerExtendsLeaseOfLongRunningJobs(t *testing.T) {
+	store := &fakeJobStore{}
+	now := time.Now()
+	_, _ = store.Schedule(&domain.Job{Key: "long", Kind: testJob, RunAt: now})
+	lease := 30 * time.Millisecond
+	scheduler := application.NewJobScheduler(store, "replica-1", time.Minute, lease, 3, trace.NewNoopTracerProvider().Tracer(""), discardLoki{})
+	other := application.NewJobScheduler(store, "replica-2", time.Minute, lease, 3, trace.NewNoopTracerProvider().Tracer(""), discardLoki{})
+	runs := 0
+	handler := func(job *domain.Job, span trace.Span) (*domain.Job, error) {
+		runs++
+		if runs == 1 {
+			time.Sleep(4 * lease)
+			other.RunDue(now.Add(4 * lease))
+		}
+		return nil, nil
+	}
+	scheduler.Register(testJob, handler)
+	other.Register(testJob, handler)
+
+	scheduler.RunDue(now)
+	if runs != 1 {
+		t.Errorf("job ran %d times, want once while its lease is extended", runs)
+	}
+}
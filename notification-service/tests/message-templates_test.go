@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/templates"
+	"testing"
+)
+
+var payload = domain.NotificationPayload{ReservationId: "42", AccommodationId: "7", ActorId: "1", ActorName: "Ana", Count: 3}
+
+func loadMessageTemplates(t *testing.T) *templates.MessageTemplates {
+	messageTemplates, err := templates.LoadMessageTemplates("../templates", "en")
+	if err != nil {
+		t.Fatalf("LoadMessageTemplates() error = %v", err)
+	}
+	return messageTemplates
+}
+
+func TestRenderAllTypesInEveryLocale(t *testing.T) {
+	messageTemplates := loadMessageTemplates(t)
+	tests := []struct {
+		notificationType domain.NotificationType
+		status           string
+		want             map[string]string
+	}{
+		{domain.NewReservationRequest, "", map[string]string{
+			"en": "You have a new reservation request.",
+			"sr": "Imate novi zahtev za rezervaciju.",
+		}},
+		{domain.NewReservationRequest, "automatic", map[string]string{
+			"en": "Reservation request #42 is automatically accepted.",
+			"sr": "Zahtev za rezervaciju #42 je automatski prihvaćen.",
+		}},
+		{domain.CancelReservation, "", map[string]string{
+			"en": "A reservation #42 has been cancelled.",
+			"sr": "Rezervacija #42 je otkazana.",
+		}},
+		{domain.NewHostReview, "", map[string]string{
+			"en": "Ana has reviewed your profile. Check for more details.",
+			"sr": "Korisnik Ana je ocenio vaš profil. Pogledajte detalje.",
+		}},
+		{domain.NewAccommodationReview, "", map[string]string{
+			"en": "Ana has reviewed your accommodation. Check for more details.",
+			"sr": "Korisnik Ana je ocenio vaš smeštaj. Pogledajte detalje.",
+		}},
+		{domain.ReviewReservation, "", map[string]string{
+			"en": "The host has declined your reservation #42.",
+			"sr": "Domaćin je odbio vašu rezervaciju #42.",
+		}},
+		{domain.ReviewReservation, "accept-request", map[string]string{
+			"en": "The host has confirmed your reservation #42.",
+			"sr": "Domaćin je potvrdio vašu rezervaciju #42.",
+		}},
+		{domain.Digest, "", map[string]string{
+			"en": "You have 3 new notifications.",
+			"sr": "Broj novih obaveštenja: 3.",
+		}},
+		{domain.Digest, "new-accommodation-review", map[string]string{
+			"en": "Your accommodations have 3 new reviews.",
+			"sr": "Broj novih ocena vašeg smeštaja: 3.",
+		}},
+		{domain.Digest, "review-reservation", map[string]string{
+			"en": "Hosts have responded to 3 of your reservation requests.",
+			"sr": "Broj odgovora domaćina na vaše zahteve za rezervaciju: 3.",
+		}},
+	}
+
+	covered := map[domain.NotificationType]bool{}
+	for _, test := range tests {
+		covered[test.notificationType] = true
+		eventPayload := payload
+		eventPayload.Status = test.status
+		for locale, want := range test.want {
+			got, err := messageTemplates.Render(locale, messageTemplates.MessageKey(test.notificationType, eventPayload), eventPayload)
+			if err != nil {
+				t.Errorf("Render(%q, %s, %q) error = %v", locale, test.notificationType, test.status, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("Render(%q, %s, %q) = %q, want %q", locale, test.notificationType, test.status, got, want)
+			}
+		}
+	}
+	for _, notificationType := range domain.NotificationTypes() {
+		if !covered[notificationType] {
+			t.Errorf("notification type %s is not covered", notificationType)
+		}
+	}
+}
+
+func TestRenderFallsBackToBaseLanguageAndDefaultLocale(t *testing.T) {
+	messageTemplates := loadMessageTemplates(t)
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"sr-Latn", "Rezervacija #42 je otkazana."},
+		{"sr_RS", "Rezervacija #42 je otkazana."},
+		{"de", "A reservation #42 has been cancelled."},
+		{"", "A reservation #42 has been cancelled."},
+	}
+
+	for _, test := range tests {
+		got, err := messageTemplates.Render(test.locale, "cancel-reservation.default", payload)
+		if err != nil {
+			t.Errorf("Render(%q) error = %v", test.locale, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Render(%q) = %q, want %q", test.locale, got, test.want)
+		}
+	}
+}
+
+func TestMessageKey(t *testing.T) {
+	messageTemplates := loadMessageTemplates(t)
+	tests := []struct {
+		notificationType domain.NotificationType
+		status           string
+		want             string
+	}{
+		{domain.NewReservationRequest, "", "new-reservation-request.default"},
+		{domain.NewReservationRequest, "automatic", "new-reservation-request.automatic"},
+		{domain.ReviewReservation, "accept-request", "review-reservation.accept-request"},
+		{domain.ReviewReservation, "decline-request", "review-reservation.default"},
+	}
+
+	for _, test := range tests {
+		eventPayload := payload
+		eventPayload.Status = test.status
+		if got := messageTemplates.MessageKey(test.notificationType, eventPayload); got != test.want {
+			t.Errorf("MessageKey(%s, %q) = %q, want %q", test.notificationType, test.status, got, test.want)
+		}
+	}
+}
+
+func TestRenderUnknownKey(t *testing.T) {
+	messageTemplates := loadMessageTemplates(t)
+	for _, key := range []string{"", "unknown.default", "cancel-reservation.unknown"} {
+		if _, err := messageTemplates.Render("en", key, payload); err == nil {
+			t.Errorf("Render(%q) succeeded", key)
+		}
+	}
+}
+
+func TestSupports(t *testing.T) {
+	messageTemplates := loadMessageTemplates(t)
+	for locale, want := range map[string]bool{"en": true, "sr": true, "sr-Latn": true, "EN-us": true, "de": false} {
+		if got := messageTemplates.Supports(locale); got != want {
+			t.Errorf("Supports(%q) = %v, want %v", locale, got, want)
+		}
+	}
+}
+
+func TestLoadFailsWithoutDefaultLocale(t *testing.T) {
+	if _, err := templates.LoadMessageTemplates("../templates", "de"); err == nil {
+		t.Error("LoadMessageTemplates() with a missing default locale succeeded")
+	}
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/persistence"
+	"testing"
+)
+
+func TestInferTypeAndPayloadOfLegacyNotifications(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     string
+		redirectId  string
+		wantType    domain.NotificationType
+		wantPayload domain.NotificationPayload
+	}{
+		{
+			name:        "reservation request",
+			message:     "You have a new reservation request.",
+			redirectId:  "reservation/view/42",
+			wantType:    domain.NewReservationRequest,
+			wantPayload: domain.NotificationPayload{ReservationId: "42"},
+		},
+		{
+			name:        "automatically accepted reservation request",
+			message:     "Reservation request #42 is automatically accepted.",
+			redirectId:  "reservation/view/42",
+			wantType:    domain.NewReservationRequest,
+			wantPayload: domain.NotificationPayload{ReservationId: "42", Status: "automatic"},
+		},
+		{
+			name:        "cancelled reservation",
+			message:     "A reservation #42 has been cancelled.",
+			redirectId:  "reservation/view/42",
+			wantType:    domain.CancelReservation,
+			wantPayload: domain.NotificationPayload{ReservationId: "42"},
+		},
+		{
+			name:        "host review",
+			message:     "Ana has reviewed your profile. Check for more details.",
+			redirectId:  "auth/view-profile/host",
+			wantType:    domain.NewHostReview,
+			wantPayload: domain.NotificationPayload{ActorName: "Ana"},
+		},
+		{
+			name:        "accommodation review",
+			message:     "Ana has reviewed your accommodation. Check for more details.",
+			redirectId:  "accommodation/7",
+			wantType:    domain.NewAccommodationReview,
+			wantPayload: domain.NotificationPayload{AccommodationId: "7", ActorName: "Ana"},
+		},
+		{
+			name:        "confirmed reservation",
+			message:     "The host has been confirmed your reservation #42",
+			redirectId:  "reservation/view/42",
+			wantType:    domain.ReviewReservation,
+			wantPayload: domain.NotificationPayload{ReservationId: "42", Status: "accept-request"},
+		},
+		{
+			name:        "declined reservation",
+			message:     "The host has been canceled your reservation #42",
+			redirectId:  "reservation/view/42",
+			wantType:    domain.ReviewReservation,
+			wantPayload: domain.NotificationPayload{ReservationId: "42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notificationType, payload := persistence.InferTypeAndPayload(tt.message, tt.redirectId)
+			if notificationType != tt.wantType || payload != tt.wantPayload {
+				t.Errorf("InferTypeAndPayload() = %s %+v, want %s %+v", notificationType, payload, tt.wantType, tt.wantPayload)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/notifier"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/request"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeDeviceStore struct {
+	mutex   sync.Mutex
+	devices []*domain.Device
+}
+
+func (store *fakeDeviceStore) GetActiveByUserId(userId string) ([]*domain.Device, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	var devices []*domain.Device
+	for _, device := range store.devices {
+		if device.UserId == userId && device.Active {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (store *fakeDeviceStore) Upsert(device *domain.Device) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	device.Active = true
+	store.devices = append(store.devices, device)
+	return nil
+}
+
+func (store *fakeDeviceStore) Delete(userId, token string) (int64, error) {
+	return 0, nil
+}
+
+func (store *fakeDeviceStore) Deactivate(token string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, device := range store.devices {
+		if device.Token == token {
+			device.Active = false
+		}
+	}
+	return nil
+}
+
+func TestMobilePushNotifierFansOutToActiveDevices(t *testing.T) {
+	store := &fakeDeviceStore{}
+	store.Upsert(&domain.Device{UserId: "guest", Platform: domain.AndroidPlatform, Token: "android-1"})
+	store.Upsert(&domain.Device{UserId: "guest", Platform: domain.IosPlatform, Token: "ios-1"})
+	store.Upsert(&domain.Device{UserId: "guest", Platform: domain.IosPlatform, Token: "ios-2"})
+	store.Upsert(&domain.Device{UserId: "host", Platform: domain.AndroidPlatform, Token: "android-2"})
+	android, ios := notifier.NewFakePushProvider(domain.AndroidPlatform), notifier.NewFakePushProvider(domain.IosPlatform)
+
+	if err := notifier.NewMobilePushNotifier(store, android, ios).Notify(newDelivery()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if sent := android.Sent(); len(sent) != 1 || sent[0].Token != "android-1" {
+		t.Errorf("android sent = %v, want only the guest's device", sent)
+	}
+	sent := ios.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("ios sent = %v, want both of the guest's devices", sent)
+	}
+	if sent[0].Message.Body != "The host has confirmed your reservation #42." || sent[0].Message.Data["redirectId"] != "reservation/view/42" {
+		t.Errorf("message = %+v, want the message and the redirect", sent[0].Message)
+	}
+}
+
+func TestMobilePushNotifierDeactivatesInvalidTokens(t *testing.T) {
+	store := &fakeDeviceStore{}
+	store.Upsert(&domain.Device{UserId: "guest", Platform: domain.AndroidPlatform, Token: "stale"})
+	store.Upsert(&domain.Device{UserId: "guest", Platform: domain.AndroidPlatform, Token: "fresh"})
+	android := notifier.NewFakePushProvider(domain.AndroidPlatform)
+	android.Invalidate("stale")
+
+	if err := notifier.NewMobilePushNotifier(store, android).Notify(newDelivery()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	active, _ := store.GetActiveByUserId("guest")
+	if len(active) != 1 || active[0].Token != "fresh" {
+		t.Errorf("active devices = %v, want only the fresh one", active)
+	}
+}
+
+func TestMobilePushNotifierWithoutDevices(t *testing.T) {
+	pushNotifier := notifier.NewMobilePushNotifier(&fakeDeviceStore{}, notifier.NewFakePushProvider(domain.AndroidPlatform))
+	if err := pushNotifier.Notify(newDelivery()); !errors.Is(err, domain.ErrMissingContact) {
+		t.Errorf("Notify() error = %v, want %v", err, domain.ErrMissingContact)
+	}
+}
+
+func newFcmProvider(t *testing.T, server *httptest.Server) *notifier.FcmProvider {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	encoded, _ := x509.MarshalPKCS8PrivateKey(key)
+	credentials, _ := json.Marshal(map[string]string{
+		"client_email": "push@zms.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encoded})),
+		"token_uri":    server.URL + "/token",
+	})
+	provider, err := notifier.NewFcmProvider(notifier.FcmConfig{ProjectId: "zms", Credentials: credentials, BaseUrl: server.URL}, server.Client())
+	if err != nil {
+		t.Fatalf("NewFcmProvider() error = %v", err)
+	}
+	return provider
+}
+
+func TestFcmProviderMapsUnregisteredTokens(t *testing.T) {
+	tokenExchanges := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			tokenExchanges++
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "access", "expires_in": 3600})
+		case r.Header.Get("Authorization") != "Bearer access":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path != "/v1/projects/zms/messages:send":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			var body struct {
+				Message struct {
+					Token string `json:"token"`
+				} `json:"message"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			switch body.Message.Token {
+			case "unregistered":
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"status":"INVALID_ARGUMENT","details":[{"errorCode":"UNREGISTERED"}]}}`))
+			case "throttled":
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"error":{"status":"RESOURCE_EXHAUSTED","message":"quota exceeded"}}`))
+			default:
+				w.Write([]byte(`{"name":"projects/zms/messages/1"}`))
+			}
+		}
+	}))
+	defer server.Close()
+	provider := newFcmProvider(t, server)
+
+	if err := provider.Send("valid", domain.PushMessage{Body: "message"}); err != nil {
+		t.Errorf("Send(valid) error = %v", err)
+	}
+	if err := provider.Send("unregistered", domain.PushMessage{Body: "message"}); !errors.Is(err, domain.ErrInvalidDeviceToken) {
+		t.Errorf("Send(unregistered) error = %v, want %v", err, domain.ErrInvalidDeviceToken)
+	}
+	if err := provider.Send("throttled", domain.PushMessage{Body: "message"}); err == nil || errors.Is(err, domain.ErrInvalidDeviceToken) {
+		t.Errorf("Send(throttled) error = %v, want a provider error", err)
+	}
+	if tokenExchanges != 1 {
+		t.Errorf("token exchanges = %d, want the access token cached", tokenExchanges)
+	}
+}
+
+func TestApnsProviderMapsInvalidTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "bearer ") || r.Header.Get("apns-topic") != "com.zms.app" || r.Header.Get("apns-push-type") != "alert" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"reason":"MissingProviderToken"}`))
+			return
+		}
+		switch strings.TrimPrefix(r.URL.Path, "/3/device/") {
+		case "gone":
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+		case "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+		case "throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"reason":"TooManyRequests"}`))
+		}
+	}))
+	defer server.Close()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	encoded, _ := x509.MarshalPKCS8PrivateKey(key)
+	provider, err := notifier.NewApnsProvider(notifier.ApnsConfig{
+		Key:     pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encoded}),
+		KeyId:   "KEY",
+		TeamId:  "TEAM",
+		Topic:   "com.zms.app",
+		BaseUrl: server.URL,
+	}, server.Client())
+	if err != nil {
+		t.Fatalf("NewApnsProvider() error = %v", err)
+	}
+
+	if err := provider.Send("valid", domain.PushMessage{Body: "message"}); err != nil {
+		t.Errorf("Send(valid) error = %v", err)
+	}
+	for _, token := range []string{"gone", "bad"} {
+		if err := provider.Send(token, domain.PushMessage{Body: "message"}); !errors.Is(err, domain.ErrInvalidDeviceToken) {
+			t.Errorf("Send(%s) error = %v, want %v", token, err, domain.ErrInvalidDeviceToken)
+		}
+	}
+	if err := provider.Send("throttled", domain.PushMessage{Body: "message"}); err == nil || errors.Is(err, domain.ErrInvalidDeviceToken) {
+		t.Errorf("Send(throttled) error = %v, want a provider error", err)
+	}
+}
+
+func TestDeviceRequestValidation(t *testing.T) {
+	apnsToken := strings.Repeat("ab12", 16)
+	tests := []struct {
+		request request.DeviceRequest
+		valid   bool
+	}{
+		{request: request.DeviceRequest{Platform: domain.IosPlatform, Token: apnsToken}, valid: true},
+		{request: request.DeviceRequest{Platform: domain.AndroidPlatform, Token: "fcm-token:APA91b_x-1"}, valid: true},
+		{request: request.DeviceRequest{Platform: domain.IosPlatform, Token: apnsToken[:62]}, valid: false},
+		{request: request.DeviceRequest{Platform: domain.IosPlatform, Token: "../../3/device/" + apnsToken[:49]}, valid: false},
+		{request: request.DeviceRequest{Platform: domain.AndroidPlatform, Token: "fcm/../token"}, valid: false},
+		{request: request.DeviceRequest{Platform: domain.AndroidPlatform, Token: "fcm-token?x=1"}, valid: false},
+	}
+	for _, test := range tests {
+		if err := test.request.AreValidRequestData(); (err == nil) != test.valid {
+			t.Errorf("AreValidRequestData(%+v) error = %v, want valid %t", test.request, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/templates"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type discardLoki struct{}
+
+func (discardLoki) Debugf(string, ...interface{}) {}
+func (discardLoki) Infof(string, ...interface{})  {}
+func (discardLoki) Warnf(string, ...interface{})  {}
+func (discardLoki) Errorf(string, ...interface{}) {}
+func (discardLoki) Shutdown()                     {}
+
+type fakeDeliveryLogStore struct {
+	mutex   sync.Mutex
+	records []*domain.DeliveryRecord
+}
+
+func (store *fakeDeliveryLogStore) Insert(records []*domain.DeliveryRecord) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, record := range records {
+		record.Id = primitive.NewObjectID()
+		store.records = append(store.records, record)
+	}
+	return nil
+}
+
+func (store *fakeDeliveryLogStore) Complete(id primitive.ObjectID, status domain.DeliveryStatus, errorMessage string, completedAt time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, record := range store.records {
+		if record.Id == id {
+			record.Status, record.Error, record.UpdatedAt = status, errorMessage, completedAt
+		}
+	}
+	return nil
+}
+
+func (store *fakeDeliveryLogStore) GetByNotificationId(userId string, notificationId primitive.ObjectID) ([]*domain.DeliveryRecord, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	var records []*domain.DeliveryRecord
+	for _, record := range store.records {
+		if record.UserId == userId && record.NotificationId == notificationId {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (store *fakeDeliveryLogStore) GetPageByUserId(userId string, query domain.DeliveryLogQuery) ([]*domain.DeliveryRecord, error) {
+	return nil, nil
+}
+
+func (store *fakeDeliveryLogStore) byChannel() map[domain.Channel]*domain.DeliveryRecord {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	records := map[domain.Channel]*domain.DeliveryRecord{}
+	for _, record := range store.records {
+		records[record.Channel] = record
+	}
+	return records
+}
+
+type fakeNotifier struct {
+	channel domain.Channel
+	err     error
+	sent    []domain.Delivery
+}
+
+func (notifier *fakeNotifier) Channel() domain.Channel {
+	return notifier.channel
+}
+
+func (notifier *fakeNotifier) Notify(delivery domain.Delivery) error {
+	if notifier.err == nil {
+		notifier.sent = append(notifier.sent, delivery)
+	}
+	return notifier.err
+}
+
+func newMessages(t *testing.T) domain.MessageRenderer {
+	messages, err := templates.LoadMessageTemplates("../templates", "en")
+	if err != nil {
+		t.Fatalf("LoadMessageTemplates() error = %v", err)
+	}
+	return messages
+}
+
+func newDispatcher(t *testing.T, store domain.DeliveryLogStore, notifiers ...domain.Notifier) *application.NotificationDispatcher {
+	return application.NewNotificationDispatcher(newMessages(t), application.NewDeliveryLogService(store, discardLoki{}), notifiers...)
+}
+
+// jobFixture wires the services a scheduled job needs around the settings of a single user, with
+// a dispatcher delivering through the given notifiers, and registers the handler of the job kind.
+type jobFixture struct {
+	jobs            *fakeJobStore
+	messages        domain.MessageRenderer
+	settingsService *application.NotificationSettingsService
+	deliveryLog     *application.DeliveryLogService
+	dispatcher      *application.NotificationDispatcher
+	scheduler       *application.JobScheduler
+}
+
+func newJobFixture(t *testing.T, settings *domain.Settings, kind domain.JobKind, handler func(fixture jobFixture) application.JobHandler, notifiers ...domain.Notifier) jobFixture {
+	fixture := jobFixture{
+		jobs:        &fakeJobStore{},
+		messages:    newMessages(t),
+		deliveryLog: application.NewDeliveryLogService(&fakeDeliveryLogStore{}, discardLoki{}),
+	}
+	fixture.settingsService = application.NewNotificationSettingsService(&fakeSettingsStore{settings: map[string]*domain.Settings{settings.UserId: settings}}, fixture.messages, nil, &http.Client{}, discardLoki{})
+	fixture.dispatcher = application.NewNotificationDispatcher(fixture.messages, fixture.deliveryLog, notifiers...)
+	fixture.scheduler = newScheduler(fixture.jobs, "replica-1")
+	fixture.scheduler.Register(kind, handler(fixture))
+	return fixture
+}
+
+func TestDispatcherLogsOutcomePerChannel(t *testing.T) {
+	store := &fakeDeliveryLogStore{}
+	email := &fakeNotifier{channel: domain.EmailChannel}
+	sms := &fakeNotifier{channel: domain.SmsChannel, err: domain.ErrInvalidPhoneNumber}
+	webhook := &fakeNotifier{channel: domain.WebhookChannel, err: errors.New("webhook responded with 500")}
+	delivery := newDelivery(unrendered)
+
+	newDispatcher(t, store, email, sms, webhook).Dispatch(delivery, []domain.Channel{domain.BellChannel, domain.EmailChannel, domain.SmsChannel, domain.WebhookChannel, domain.PushChannel}, trace.SpanFromContext(context.Background()), discardLoki{})
+
+	records := store.byChannel()
+	if _, ok := records[domain.BellChannel]; ok {
+		t.Error("dispatcher logged the bell, which is delivered by the notification handler")
+	}
+	want := map[domain.Channel]domain.DeliveryStatus{
+		domain.EmailChannel:   domain.SentDelivery,
+		domain.SmsChannel:     domain.BouncedDelivery,
+		domain.WebhookChannel: domain.FailedDelivery,
+		domain.PushChannel:    domain.SkippedDelivery,
+	}
+	for channel, status := range want {
+		record, ok := records[channel]
+		if !ok {
+			t.Errorf("no %s record", channel)
+			continue
+		}
+		if record.Status != status || (status == domain.SentDelivery) != (record.Error == "") {
+			t.Errorf("%s record = %s %q, want %s", channel, record.Status, record.Error, status)
+		}
+		if record.NotificationId.Hex() != delivery.NotificationId || record.UserId != "guest" {
+			t.Errorf("%s record is not logged under the notification and its recipient", channel)
+		}
+	}
+	if len(email.sent) != 1 || email.sent[0].Message != "The host has confirmed your reservation #42." {
+		t.Errorf("email deliveries = %+v, want the rendered message", email.sent)
+	}
+}
+
+func TestDispatcherTreatsChannelAsSentWhenAnyNotifierDelivers(t *testing.T) {
+	store := &fakeDeliveryLogStore{}
+	webPush := &fakeNotifier{channel: domain.PushChannel, err: domain.ErrMissingContact}
+	mobilePush := &fakeNotifier{channel: domain.PushChannel}
+
+	newDispatcher(t, store, webPush, mobilePush).Dispatch(newDelivery(unrendered), []domain.Channel{domain.PushChannel}, trace.SpanFromContext(context.Background()), discardLoki{})
+
+	if record := store.byChannel()[domain.PushChannel]; record == nil || record.Status != domain.SentDelivery {
+		t.Errorf("push record = %+v, want sent", record)
+	}
+	if len(mobilePush.sent) != 1 {
+		t.Errorf("mobile push deliveries = %d, want 1", len(mobilePush.sent))
+	}
+}
+
+func TestDispatcherRecordsUnattemptedDeliveriesAsSkipped(t *testing.T) {
+	tests := []struct {
+		name      string
+		notifiers []domain.Notifier
+		want      domain.DeliveryStatus
+	}{
+		{"without contact", []domain.Notifier{&fakeNotifier{channel: domain.PushChannel, err: domain.ErrMissingContact}}, domain.SkippedDelivery},
+		{"without consent", []domain.Notifier{&fakeNotifier{channel: domain.PushChannel, err: domain.ErrNoConsent}}, domain.SkippedDelivery},
+		{"not configured", nil, domain.SkippedDelivery},
+		{"skipped by every notifier", []domain.Notifier{
+			&fakeNotifier{channel: domain.PushChannel, err: domain.ErrMissingContact},
+			&fakeNotifier{channel: domain.PushChannel, err: domain.ErrMissingContact},
+		}, domain.SkippedDelivery},
+		{"failed by one notifier", []domain.Notifier{
+			&fakeNotifier{channel: domain.PushChannel, err: domain.ErrMissingContact},
+			&fakeNotifier{channel: domain.PushChannel, err: errors.New("push service unavailable")},
+		}, domain.FailedDelivery},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeDeliveryLogStore{}
+			newDispatcher(t, store, tt.notifiers...).Dispatch(newDelivery(), []domain.Channel{domain.PushChannel}, trace.SpanFromContext(context.Background()), discardLoki{})
+
+			if record := store.byChannel()[domain.PushChannel]; record == nil || record.Status != tt.want {
+				t.Errorf("push record = %+v, want %s", record, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryLogRecordsImmediateDeliveries(t *testing.T) {
+	store := &fakeDeliveryLogStore{}
+	deliveryLog := application.NewDeliveryLogService(store, discardLoki{})
+	delivery := newDelivery(unrendered)
+
+	deliveryLog.Record(delivery, domain.BellChannel, nil, trace.SpanFromContext(context.Background()), discardLoki{})
+
+	notificationId, _ := primitive.ObjectIDFromHex(delivery.NotificationId)
+	records, _ := store.GetByNotificationId("guest", notificationId)
+	if len(records) != 1 || records[0].Channel != domain.BellChannel || records[0].Status != domain.SentDelivery {
+		t.Errorf("records = %+v, want a sent bell record", records)
+	}
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"context"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func newSettingsService(settings ...*domain.Settings) *application.NotificationSettingsService {
+	store := &fakeSettingsStore{settings: map[string]*domain.Settings{}}
+	for _, userSettings := range settings {
+		store.settings[userSettings.UserId] = userSettings
+	}
+	return application.NewNotificationSettingsService(store, nil, nil, &http.Client{}, discardLoki{})
+}
+
+func TestResolveChannels(t *testing.T) {
+	settings := &domain.Settings{
+		UserId: "host",
+		Settings: []domain.NotificationSetting{
+			{Type: domain.NewReservationRequest, Active: true, Channels: map[domain.Channel]bool{domain.BellChannel: true, domain.EmailChannel: true, domain.SmsChannel: false}},
+			{Type: domain.CancelReservation, Active: false, Channels: map[domain.Channel]bool{domain.BellChannel: true, domain.EmailChannel: true}},
+			{Type: domain.NewHostReview, Active: true},
+			{Type: domain.NewAccommodationReview, Active: true, Channels: map[domain.Channel]bool{}},
+		},
+	}
+	service := newSettingsService(settings)
+	span := trace.SpanFromContext(context.Background())
+
+	tests := []struct {
+		name             string
+		userId           string
+		notificationType domain.NotificationType
+		want             []domain.Channel
+	}{
+		{name: "enabled channels", userId: "host", notificationType: domain.NewReservationRequest, want: []domain.Channel{domain.BellChannel, domain.EmailChannel}},
+		{name: "inactive type", userId: "host", notificationType: domain.CancelReservation, want: nil},
+		{name: "stored before channels", userId: "host", notificationType: domain.NewHostReview, want: []domain.Channel{domain.BellChannel}},
+		{name: "every channel turned off", userId: "host", notificationType: domain.NewAccommodationReview, want: nil},
+		{name: "type without setting", userId: "host", notificationType: domain.ReviewReservation, want: nil},
+		{name: "user without settings", userId: "guest", notificationType: domain.NewReservationRequest, want: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := service.ResolveChannels(test.userId, test.notificationType, span, discardLoki{}); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ResolveChannels() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdateKeepsStoredFieldsTheUpdateLeavesOut(t *testing.T) {
+	stored := map[domain.Channel]bool{domain.BellChannel: true, domain.EmailChannel: true}
+	settings := &domain.Settings{
+		Id:     primitive.NewObjectID(),
+		UserId: "host",
+		Settings: []domain.NotificationSetting{
+			{Type: domain.NewReservationRequest, Active: true, Channels: stored, Digest: domain.DailyDigest, Escalate: true},
+			{Type: domain.CancelReservation, Active: true, Channels: stored, Digest: domain.DailyDigest, Escalate: true},
+			{Type: domain.NewHostReview, Active: true, Channels: stored, Digest: domain.DailyDigest, Escalate: true},
+		},
+	}
+	service := newSettingsService(settings)
+	hourly, immediate, escalate := domain.HourlyDigest, domain.DigestFrequency(""), false
+
+	err := service.Update("host", domain.HostRole, []domain.NotificationSettingUpdate{
+		{Type: domain.NewReservationRequest, Active: false},
+		{Type: domain.CancelReservation, Active: true, Channels: map[domain.Channel]bool{domain.PushChannel: true}},
+		{Type: domain.NewHostReview, Active: true, Digest: &hourly, Escalate: &escalate},
+		{Type: domain.NewAccommodationReview, Active: true, Digest: &immediate},
+	}, trace.SpanFromContext(context.Background()), discardLoki{})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	want := []domain.NotificationSetting{
+		{Type: domain.NewReservationRequest, Active: false, Channels: stored, Digest: domain.DailyDigest, Escalate: true},
+		{Type: domain.CancelReservation, Active: true, Channels: map[domain.Channel]bool{domain.PushChannel: true}, Digest: domain.DailyDigest, Escalate: true},
+		{Type: domain.NewHostReview, Active: true, Channels: stored, Digest: domain.HourlyDigest},
+		{Type: domain.NewAccommodationReview, Active: true},
+	}
+	if !reflect.DeepEqual(settings.Settings, want) {
+		t.Errorf("settings = %+v, want %+v", settings.Settings, want)
+	}
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The deployment mounts the message templates from a config map, which has to be updated along
+// with the templates directory the service is built and tested with.
+const (
+	templatesConfigMap = "../../notification-k8s/notification-templates-configmap.yml"
+	serviceDeployment  = "../../notification-k8s/notification-service.yml"
+)
+
+func TestTemplatesConfigMapMatchesTemplates(t *testing.T) {
+	configMap := readConfigMapData(t, templatesConfigMap)
+	deployment := readManifest(t, serviceDeployment)
+
+	templates := map[string]string{}
+	err := filepath.WalkDir("../templates", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relative, _ := filepath.Rel("../templates", path)
+		locale, name, _ := strings.Cut(filepath.ToSlash(relative), "/")
+		templates[locale+"."+name] = strings.TrimRight(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading templates: %v", err)
+	}
+
+	for key, content := range templates {
+		mapped, ok := configMap[key]
+		if !ok {
+			t.Errorf("config map is missing %s", key)
+			continue
+		}
+		if mapped != content {
+			t.Errorf("config map %s differs from the template:\n%s\nwant:\n%s", key, mapped, content)
+		}
+		locale, name, _ := strings.Cut(key, ".")
+		if !strings.Contains(deployment, "- key: "+key+"\n") || !strings.Contains(deployment, "path: "+locale+"/"+name+"\n") {
+			t.Errorf("deployment does not mount %s", key)
+		}
+	}
+	for key := range configMap {
+		if _, ok := templates[key]; !ok {
+			t.Errorf("config map has %s, which is not a template", key)
+		}
+	}
+}
+
+func readManifest(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return strings.ReplaceAll(string(content), "\r\n", "\n")
+}
+
+// readConfigMapData reads the literal block entries of the config map's data.
+func readConfigMapData(t *testing.T, path string) map[string]string {
+	data := map[string]string{}
+	key := ""
+	var lines []string
+	flush := func() {
+		if key != "" {
+			data[key] = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+		}
+		key, lines = "", nil
+	}
+
+	inData := false
+	for _, line := range strings.Split(readManifest(t, path), "\n") {
+		switch {
+		case line == "data:":
+			inData = true
+		case !inData:
+		case strings.HasPrefix(line, "    ") || (line == "" && key != ""):
+			lines = append(lines, strings.TrimPrefix(line, "    "))
+		case strings.HasPrefix(line, "  ") && strings.HasSuffix(line, ": |"):
+			flush()
+			key = strings.TrimSuffix(strings.TrimSpace(line), ": |")
+		default:
+			flush()
+			inData = false
+		}
+	}
+	flush()
+	return data
+}
@@ -0,0 +1,227 @@
+package tests
+
+import (
+	"context"
+	"github.com/mmmajder/zms-devops-notification-service/application"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func utc(value string) time.Time {
+	moment, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return moment
+}
+
+func TestQuietUntil(t *testing.T) {
+	night := []domain.QuietHours{{Start: "22:00", End: "07:00"}}
+	tests := []struct {
+		name       string
+		settings   domain.Settings
+		now        time.Time
+		wantQuiet  bool
+		wantUntil  time.Time
+		wantLength time.Duration
+	}{
+		{
+			name:      "before the window",
+			settings:  domain.Settings{TimeZone: "Europe/Belgrade", QuietHours: night},
+			now:       utc("2026-06-10T19:59:00Z"),
+			wantQuiet: false,
+		},
+		{
+			name:      "after midnight in the window spanning it",
+			settings:  domain.Settings{TimeZone: "Europe/Belgrade", QuietHours: night},
+			now:       utc("2026-06-11T01:00:00Z"),
+			wantQuiet: true,
+			wantUntil: utc("2026-06-11T05:00:00Z"),
+		},
+		{
+			name:      "at the end of the window",
+			settings:  domain.Settings{TimeZone: "Europe/Belgrade", QuietHours: night},
+			now:       utc("2026-06-11T05:00:00Z"),
+			wantQuiet: false,
+		},
+		{
+			name:       "spring forward shortens the night",
+			settings:   domain.Settings{TimeZone: "Europe/Belgrade", QuietHours: night},
+			now:        utc("2026-03-28T21:00:00Z"),
+			wantQuiet:  true,
+			wantUntil:  utc("2026-03-29T05:00:00Z"),
+			wantLength: 8 * time.Hour,
+		},
+		{
+			name:      "spring forward in the skipped hour",
+			settings:  domain.Settings{TimeZone: "Europe/Belgrade", QuietHours: night},
+			now:       utc("2026-03-29T01:30:00Z"),
+			wantQuiet: true,
+			wantUntil: utc("2026-03-29T05:00:00Z"),
+		},
+		{
+			name:       "fall back lengthens the night",
+			settings:   domain.Settings{TimeZone: "Europe/Belgrade", QuietHours: night},
+			now:        utc("2026-10-24T20:00:00Z"),
+			wantQuiet:  true,
+			wantUntil:  utc("2026-10-25T06:00:00Z"),
+			wantLength: 10 * time.Hour,
+		},
+		{
+			name:      "fall back in the repeated hour",
+			settings:  domain.Settings{TimeZone: "Europe/Belgrade", QuietHours: night},
+			now:       utc("2026-10-25T01:30:00Z"),
+			wantQuiet: true,
+			wantUntil: utc("2026-10-25T06:00:00Z"),
+		},
+		{
+			name:      "fall back after the old end of the window",
+			settings:  domain.Settings{TimeZone: "Europe/Belgrade", QuietHours: night},
+			now:       utc("2026-10-25T05:30:00Z"),
+			wantQuiet: true,
+			wantUntil: utc("2026-10-25T06:00:00Z"),
+		},
+		{
+			name:      "US spring forward",
+			settings:  domain.Settings{TimeZone: "America/New_York", QuietHours: []domain.QuietHours{{Start: "01:00", End: "06:00"}}},
+			now:       utc("2026-03-08T06:30:00Z"),
+			wantQuiet: true,
+			wantUntil: utc("2026-03-08T10:00:00Z"),
+		},
+		{
+			name:      "following windows are merged",
+			settings:  domain.Settings{TimeZone: "UTC", QuietHours: []domain.QuietHours{{Start: "06:00", End: "08:00"}, {Start: "22:00", End: "06:00"}}},
+			now:       utc("2026-06-10T23:00:00Z"),
+			wantQuiet: true,
+			wantUntil: utc("2026-06-11T08:00:00Z"),
+		},
+		{
+			name:      "without a time zone in UTC",
+			settings:  domain.Settings{QuietHours: night},
+			now:       utc("2026-06-10T23:00:00Z"),
+			wantQuiet: true,
+			wantUntil: utc("2026-06-11T07:00:00Z"),
+		},
+		{
+			name:      "without windows",
+			settings:  domain.Settings{TimeZone: "Europe/Belgrade"},
+			now:       utc("2026-06-10T23:00:00Z"),
+			wantQuiet: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := tt.settings.QuietUntil(tt.now)
+			if quiet != tt.wantQuiet {
+				t.Fatalf("QuietUntil() quiet = %t, want %t", quiet, tt.wantQuiet)
+			}
+			if quiet && !until.Equal(tt.wantUntil) {
+				t.Errorf("QuietUntil() until = %s, want %s", until.UTC(), tt.wantUntil)
+			}
+			if tt.wantLength != 0 && until.Sub(tt.now) != tt.wantLength {
+				t.Errorf("quiet hours last %s, want %s", until.Sub(tt.now), tt.wantLength)
+			}
+		})
+	}
+}
+
+type deferredFixture struct {
+	jobFixture
+	settings *domain.Settings
+	email    *fakeNotifier
+	webhook  *fakeNotifier
+	service  *application.DeferredDeliveryService
+}
+
+func newDeferredFixture(t *testing.T) *deferredFixture {
+	fixture := &deferredFixture{
+		settings: &domain.Settings{UserId: "host", Email: "host@zms.com", TimeZone: "Europe/Belgrade"},
+		email:    &fakeNotifier{channel: domain.EmailChannel},
+		webhook:  &fakeNotifier{channel: domain.WebhookChannel},
+	}
+	fixture.jobFixture = newJobFixture(t, fixture.settings, domain.DeferredDeliveryJob, func(wiring jobFixture) application.JobHandler {
+		fixture.service = application.NewDeferredDeliveryService(wiring.jobs, wiring.settingsService, wiring.dispatcher, discardLoki{})
+		return fixture.service.Deliver
+	}, fixture.email, fixture.webhook)
+	return fixture
+}
+
+// windowAround returns a quiet hours window which the current time falls into.
+func windowAround(now time.Time, location *time.Location) domain.QuietHours {
+	return domain.QuietHours{
+		Start: now.In(location).Add(-time.Hour).Format(domain.QuietHoursLayout),
+		End:   now.In(location).Add(time.Hour).Format(domain.QuietHoursLayout),
+	}
+}
+
+func TestDeferHoldsBackInterruptiveChannels(t *testing.T) {
+	fixture := newDeferredFixture(t)
+	span := trace.SpanFromContext(context.Background())
+	until := time.Now().Add(time.Hour)
+
+	immediate, err := fixture.service.Defer(newDelivery(hostReview), []domain.Channel{domain.BellChannel, domain.EmailChannel, domain.WebhookChannel}, until, span, discardLoki{})
+	if err != nil {
+		t.Fatalf("Defer() error = %v", err)
+	}
+	if !slices.Equal(immediate, []domain.Channel{domain.BellChannel, domain.WebhookChannel}) {
+		t.Errorf("immediate channels = %v, want the bell and the webhook", immediate)
+	}
+	if len(fixture.jobs.jobs) != 1 {
+		t.Fatalf("jobs = %d, want one deferred delivery", len(fixture.jobs.jobs))
+	}
+	job := fixture.jobs.jobs[0]
+	if !job.RunAt.Equal(until) || !slices.Equal(job.Channels, []domain.Channel{domain.BellChannel, domain.EmailChannel}) {
+		t.Errorf("deferred job = %+v, want the bell toast and the email at the end of quiet hours", job)
+	}
+}
+
+func TestDeferredDeliveryIsDeliveredAfterQuietHours(t *testing.T) {
+	fixture := newDeferredFixture(t)
+	span := trace.SpanFromContext(context.Background())
+	delivery := newDelivery(hostReview)
+	until := time.Now().Add(-time.Minute)
+	_, _ = fixture.service.Defer(delivery, []domain.Channel{domain.EmailChannel}, until, span, discardLoki{})
+
+	fixture.scheduler.RunDue(time.Now())
+	if len(fixture.email.sent) != 1 || fixture.email.sent[0].NotificationId != delivery.NotificationId || fixture.email.sent[0].Message == "" {
+		t.Errorf("emails = %+v, want the rendered deferred notification", fixture.email.sent)
+	}
+	if len(fixture.jobs.jobs) != 0 {
+		t.Error("deferred delivery is still scheduled")
+	}
+}
+
+func TestDeferredDeliveryWaitsForExtendedQuietHours(t *testing.T) {
+	fixture := newDeferredFixture(t)
+	span := trace.SpanFromContext(context.Background())
+	_, _ = fixture.service.Defer(newDelivery(hostReview), []domain.Channel{domain.EmailChannel}, time.Now().Add(-time.Minute), span, discardLoki{})
+	now := time.Now()
+	fixture.settings.QuietHours = []domain.QuietHours{windowAround(now, fixture.settings.Location())}
+
+	fixture.scheduler.RunDue(now)
+	if len(fixture.email.sent) != 0 {
+		t.Fatal("delivered during the user's new quiet hours")
+	}
+	if job := fixture.jobs.get(string(domain.DeferredDeliveryJob) + ":" + fixture.jobs.jobs[0].NotificationId.Hex()); job == nil || !job.RunAt.After(now) {
+		t.Errorf("deferred job = %+v, want it moved to the end of the quiet hours", job)
+	}
+}
+
+func TestUrgentTypesAreNotHeldBack(t *testing.T) {
+	now := time.Now()
+	settings := &domain.Settings{UserId: "host", QuietHours: []domain.QuietHours{windowAround(now, time.UTC)}}
+	settingsService := application.NewNotificationSettingsService(&fakeSettingsStore{settings: map[string]*domain.Settings{"host": settings}}, newMessages(t), []domain.NotificationType{domain.CancelReservation}, &http.Client{}, discardLoki{})
+	span := trace.SpanFromContext(context.Background())
+
+	if _, quiet := settingsService.QuietUntil("host", domain.CancelReservation, now, span, discardLoki{}); quiet {
+		t.Error("held back an urgent notification")
+	}
+	if _, quiet := settingsService.QuietUntil("host", domain.NewHostReview, now, span, discardLoki{}); !quiet {
+		t.Error("delivered a non-urgent notification during quiet hours")
+	}
+}
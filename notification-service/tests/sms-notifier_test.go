@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"errors"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/notifier"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/request"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+var smsRecipient = domain.Recipient{UserId: "guest", Phone: "+381641234567", SmsOptIn: true}
+
+func TestSmsNotifierSendsMessageWithLink(t *testing.T) {
+	provider := notifier.NewFakeSmsProvider()
+	if err := notifier.NewSmsNotifier(provider, 3, "https://zms.com").Notify(newDelivery(withRecipient(smsRecipient))); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	sent := provider.Sent()
+	if len(sent) != 1 || sent[0].To != "+381641234567" || sent[0].Body != "The host has confirmed your reservation #42.\nhttps://zms.com/reservation/view/42" {
+		t.Errorf("sent = %v, want the message and the link texted to the guest", sent)
+	}
+}
+
+func TestSmsNotifierRequiresOptIn(t *testing.T) {
+	provider := notifier.NewFakeSmsProvider()
+	smsNotifier := notifier.NewSmsNotifier(provider, 3, "https://zms.com")
+
+	withoutConsent := newDelivery(withRecipient(smsRecipient), withMessage("message"))
+	withoutConsent.Recipient.SmsOptIn = false
+	if err := smsNotifier.Notify(withoutConsent); !errors.Is(err, domain.ErrNoConsent) {
+		t.Errorf("Notify() error = %v, want %v", err, domain.ErrNoConsent)
+	}
+	withoutPhone := newDelivery(withRecipient(smsRecipient), withMessage("message"))
+	withoutPhone.Recipient.Phone = ""
+	if err := smsNotifier.Notify(withoutPhone); !errors.Is(err, domain.ErrMissingContact) {
+		t.Errorf("Notify() error = %v, want %v", err, domain.ErrMissingContact)
+	}
+	if sent := provider.Sent(); len(sent) != 0 {
+		t.Errorf("sent = %v, want nothing sent", sent)
+	}
+}
+
+func TestSmsNotifierShortensLongMessages(t *testing.T) {
+	tests := []struct {
+		name      string
+		word      string
+		maxLength int
+		ucs2      bool
+	}{
+		{name: "GSM 7-bit single segment", word: "reservation ", maxLength: 160},
+		{name: "UCS-2 single segment", word: "rezervacija čeka ", maxLength: 70, ucs2: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := notifier.NewFakeSmsProvider()
+			if err := notifier.NewSmsNotifier(provider, 1, "https://zms.com").Notify(newDelivery(withRecipient(smsRecipient), withMessage(strings.Repeat(test.word, 40)))); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+
+			body := provider.Sent()[0].Body
+			length := len(body)
+			if test.ucs2 {
+				length = len(utf16.Encode([]rune(body)))
+			}
+			if length > test.maxLength {
+				t.Errorf("body has %d units, want at most %d: %q", length, test.maxLength, body)
+			}
+			if !strings.HasSuffix(body, "...\nhttps://zms.com/reservation/view/42") {
+				t.Errorf("body = %q, want the shortened message followed by the whole link", body)
+			}
+		})
+	}
+}
+
+func TestSmsNotifierDropsLinksLongerThanTheSegmentLimit(t *testing.T) {
+	provider := notifier.NewFakeSmsProvider()
+	longMessage := withMessage(strings.Repeat("reservation ", 20))
+	longLink := func(delivery *domain.Delivery) {
+		delivery.RedirectId = strings.Repeat("r", 200)
+	}
+	if err := notifier.NewSmsNotifier(provider, 1, "https://zms.com").Notify(newDelivery(withRecipient(smsRecipient), longMessage, longLink)); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	body := provider.Sent()[0].Body
+	if len(body) > 160 || strings.Contains(body, "https://") || !strings.HasPrefix(body, "reservation") {
+		t.Errorf("body = %q, want the shortened message of one segment without the link", body)
+	}
+}
+
+func TestSmsNotifierKeepsMessagesThatFitTheSegmentLimit(t *testing.T) {
+	message := strings.Repeat("a", 400)
+	provider := notifier.NewFakeSmsProvider()
+	if err := notifier.NewSmsNotifier(provider, 3, "").Notify(newDelivery(withRecipient(smsRecipient), withMessage(message))); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if body := provider.Sent()[0].Body; body != message {
+		t.Errorf("body has %d characters, want the whole message of three segments", len(body))
+	}
+}
+
+func TestHttpSmsProviderMapsInvalidNumbers(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if r.URL.Path != "/2010-04-01/Accounts/AC1/Messages.json" || username != "AC1" || password != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.ParseForm()
+		switch r.PostForm.Get("To") {
+		case "+15005550001":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":21211,"message":"The 'To' number is not a valid phone number."}`))
+		case "+15005550002":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"code":20500,"message":"Internal Server Error"}`))
+		default:
+			if r.PostForm.Get("From") != "+15005550006" || r.PostForm.Get("Body") != "message" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer api.Close()
+
+	provider := notifier.NewHttpSmsProvider(notifier.HttpSmsConfig{BaseUrl: api.URL, AccountSid: "AC1", AuthToken: "token", From: "+15005550006"}, api.Client())
+	if err := provider.Send("+381641234567", "message"); err != nil {
+		t.Errorf("Send() error = %v", err)
+	}
+	if err := provider.Send("+15005550001", "message"); !errors.Is(err, domain.ErrInvalidPhoneNumber) {
+		t.Errorf("Send() error = %v, want %v", err, domain.ErrInvalidPhoneNumber)
+	}
+	if err := provider.Send("+15005550002", "message"); err == nil || errors.Is(err, domain.ErrInvalidPhoneNumber) {
+		t.Errorf("Send() error = %v, want an API error", err)
+	}
+}
+
+func TestPhoneRequestValidation(t *testing.T) {
+	tests := []struct {
+		request request.PhoneRequest
+		valid   bool
+	}{
+		{request: request.PhoneRequest{Phone: "+381641234567", SmsOptIn: true}, valid: true},
+		{request: request.PhoneRequest{Phone: "+381641234567"}, valid: true},
+		{request: request.PhoneRequest{}, valid: true},
+		{request: request.PhoneRequest{SmsOptIn: true}, valid: false},
+		{request: request.PhoneRequest{Phone: "064 123 4567"}, valid: false},
+		{request: request.PhoneRequest{Phone: "381641234567"}, valid: false},
+	}
+	for _, test := range tests {
+		if err := test.request.AreValidRequestData(); (err == nil) != test.valid {
+			t.Errorf("AreValidRequestData(%+v) error = %v, want valid %t", test.request, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/notifier"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/templates"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type receivedMail struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// fakeSmtpServer accepts mail over plain SMTP with AUTH PLAIN and records what it receives.
+type fakeSmtpServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	mails    []receivedMail
+	received chan struct{}
+}
+
+func newFakeSmtpServer(t *testing.T) *fakeSmtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	server := &fakeSmtpServer{listener: listener, received: make(chan struct{}, 10)}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (server *fakeSmtpServer) port() string {
+	_, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	return port
+}
+
+func (server *fakeSmtpServer) serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.handle(conn)
+	}
+}
+
+func (server *fakeSmtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var current receivedMail
+	reply("220 localhost fake SMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			fields := strings.Fields(command)
+			decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			current.auth = string(decoded)
+			reply("235 Authenticated")
+		case "MAIL":
+			current.from = strings.TrimSuffix(strings.TrimPrefix(command[len("MAIL FROM:"):], "<"), ">")
+			reply("250 OK")
+		case "RCPT":
+			current.to = append(current.to, strings.TrimSuffix(strings.TrimPrefix(command[len("RCPT TO:"):], "<"), ">"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			current.data = data.String()
+			server.mutex.Lock()
+			server.mails = append(server.mails, current)
+			server.mutex.Unlock()
+			server.received <- struct{}{}
+			current = receivedMail{auth: current.auth}
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func newSmtpNotifier(t *testing.T, server *fakeSmtpServer) *notifier.SmtpNotifier {
+	emailTemplates, err := templates.LoadEmailTemplates("../email-templates", "en")
+	if err != nil {
+		t.Fatalf("LoadEmailTemplates() error = %v", err)
+	}
+	return notifier.NewSmtpNotifier(notifier.SmtpConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Username: "user",
+		Password: "secret",
+		From:     "ZMS <no-reply@zms.com>",
+		Security: notifier.NoSecurity,
+		AppUrl:   "https://zms.com/",
+	}, emailTemplates)
+}
+
+func TestSmtpNotifierSendsTextAndHtml(t *testing.T) {
+	server := newFakeSmtpServer(t)
+	if err := newSmtpNotifier(t, server).Notify(newDelivery(reservationRequest)); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	<-server.received
+
+	received := server.mails[0]
+	if received.auth != "\x00user\x00secret" {
+		t.Errorf("auth = %q, want PLAIN credentials of user", received.auth)
+	}
+	if received.from != "no-reply@zms.com" || len(received.to) != 1 || received.to[0] != "host@zms.com" {
+		t.Errorf("envelope = %s -> %v, want no-reply@zms.com -> [host@zms.com]", received.from, received.to)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(received.data))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if subject := message.Header.Get("Subject"); subject != "New reservation request" {
+		t.Errorf("Subject = %q, want %q", subject, "New reservation request")
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", message.Header.Get("Content-Type"))
+	}
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		content, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(content)
+	}
+	if text := parts["text/plain"]; !strings.Contains(text, "You have a new reservation request.") || !strings.Contains(text, "https://zms.com/reservation/view/42") {
+		t.Errorf("text part = %q, want the message and the link", text)
+	}
+	if html := parts["text/html"]; !strings.Contains(html, `<a href="https://zms.com/reservation/view/42">Review the request</a>`) {
+		t.Errorf("html part = %q, want a link to the reservation", html)
+	}
+}
+
+func TestSmtpNotifierRendersRecipientLocale(t *testing.T) {
+	server := newFakeSmtpServer(t)
+	if err := newSmtpNotifier(t, server).Notify(newDelivery(reservationRequest, func(delivery *domain.Delivery) { delivery.Recipient.Locale = "sr" })); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	<-server.received
+
+	message, err := mail.ReadMessage(strings.NewReader(server.mails[0].data))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil || subject != "Novi zahtev za rezervaciju" {
+		t.Errorf("Subject = %q, want %q", subject, "Novi zahtev za rezervaciju")
+	}
+}
+
+func TestSmtpNotifierWithoutEmail(t *testing.T) {
+	server := newFakeSmtpServer(t)
+	withoutEmail := newDelivery(reservationRequest)
+	withoutEmail.Recipient.Email = ""
+	if err := newSmtpNotifier(t, server).Notify(withoutEmail); !errors.Is(err, domain.ErrMissingContact) {
+		t.Errorf("Notify() error = %v, want %v", err, domain.ErrMissingContact)
+	}
+}
+
+func TestEmailTemplatesCoverAllTypes(t *testing.T) {
+	emailTemplates, err := templates.LoadEmailTemplates("../email-templates", "en")
+	if err != nil {
+		t.Fatalf("LoadEmailTemplates() error = %v", err)
+	}
+	for _, locale := range []string{"en", "sr"} {
+		for _, notificationType := range domain.NotificationTypes() {
+			email, err := emailTemplates.Render(locale, notificationType, templates.EmailData{Message: "message", Link: "https://zms.com"})
+			if err != nil {
+				t.Errorf("Render(%q, %s) error = %v", locale, notificationType, err)
+				continue
+			}
+			if email.Subject == "" || !strings.Contains(email.Text, "message") || !strings.Contains(email.Html, "https://zms.com") {
+				t.Errorf("Render(%q, %s) = %+v, want subject, text and html", locale, notificationType, email)
+			}
+		}
+	}
+}
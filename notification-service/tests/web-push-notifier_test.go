@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/notifier"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakePushSubscriptionStore struct {
+	mutex         sync.Mutex
+	subscriptions []*domain.PushSubscription
+}
+
+func (store *fakePushSubscriptionStore) GetByUserId(userId string) ([]*domain.PushSubscription, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	var subscriptions []*domain.PushSubscription
+	for _, subscription := range store.subscriptions {
+		if subscription.UserId == userId {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (store *fakePushSubscriptionStore) Upsert(subscription *domain.PushSubscription) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.subscriptions = append(store.subscriptions, subscription)
+	return nil
+}
+
+func (store *fakePushSubscriptionStore) Delete(userId, endpoint string) (int64, error) {
+	return 0, nil
+}
+
+func (store *fakePushSubscriptionStore) DeleteByEndpoint(endpoint string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for i, subscription := range store.subscriptions {
+		if subscription.Endpoint == endpoint {
+			store.subscriptions = append(store.subscriptions[:i], store.subscriptions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// browserSubscription holds the keys a browser subscribes with, so the fake push service can
+// decrypt what it receives.
+type browserSubscription struct {
+	private *ecdh.PrivateKey
+	auth    []byte
+}
+
+func newBrowserSubscription(t *testing.T) browserSubscription {
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return browserSubscription{private: private, auth: auth}
+}
+
+func (browser browserSubscription) subscription(userId, endpoint string) *domain.PushSubscription {
+	return &domain.PushSubscription{
+		UserId:   userId,
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(browser.private.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(browser.auth),
+	}
+}
+
+func (browser browserSubscription) decrypt(t *testing.T, body []byte) []byte {
+	salt, keyLength := body[:16], int(body[20])
+	serverPublic, record := body[21:21+keyLength], body[21+keyLength:]
+	serverKey, err := ecdh.P256().NewPublicKey(serverPublic)
+	if err != nil {
+		t.Fatalf("invalid server key: %v", err)
+	}
+	sharedSecret, _ := browser.private.ECDH(serverKey)
+
+	keyInfo := append([]byte("WebPush: info\x00"), browser.private.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm := read(hkdf.New(sha256.New, sharedSecret, browser.auth, keyInfo), 32)
+	contentKey := read(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), 16)
+	nonce := read(hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), 12)
+
+	block, _ := aes.NewCipher(contentKey)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, record, nil)
+	if err != nil {
+		t.Fatalf("failed to decrypt push message: %v", err)
+	}
+	if plaintext[len(plaintext)-1] != 0x02 {
+		t.Fatalf("push message does not end with the last record delimiter")
+	}
+	return plaintext[:len(plaintext)-1]
+}
+
+func read(reader io.Reader, length int) []byte {
+	key := make([]byte, length)
+	io.ReadFull(reader, key)
+	return key
+}
+
+// verifyVapid checks the ES256 signature of the VAPID token against the key sent with it.
+func verifyVapid(t *testing.T, authorization, audience string) {
+	var token, key string
+	for _, field := range strings.Split(strings.TrimPrefix(authorization, "vapid "), ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "t":
+			token = value
+		case "k":
+			key = value
+		}
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Authorization = %q, want a VAPID token", authorization)
+	}
+
+	publicKey, _ := base64.RawURLEncoding.DecodeString(key)
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	verifier := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(publicKey[1:33]), Y: new(big.Int).SetBytes(publicKey[33:])}
+	if !ecdsa.Verify(verifier, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		t.Error("VAPID token signature does not verify")
+	}
+
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var decoded map[string]interface{}
+	json.Unmarshal(claims, &decoded)
+	if decoded["aud"] != audience || decoded["sub"] != "mailto:ops@zms.com" {
+		t.Errorf("VAPID claims = %v, want aud %s and the configured subject", decoded, audience)
+	}
+}
+
+func newVapidKeys(t *testing.T) *notifier.VapidKeys {
+	private, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := notifier.NewVapidKeys(base64.RawURLEncoding.EncodeToString(private.Bytes()), "mailto:ops@zms.com")
+	if err != nil {
+		t.Fatalf("NewVapidKeys() error = %v", err)
+	}
+	return keys
+}
+
+func TestWebPushNotifierEncryptsAndSigns(t *testing.T) {
+	browser := newBrowserSubscription(t)
+	received := make(chan []byte, 1)
+	var pushService *httptest.Server
+	pushService = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+			t.Errorf("headers = %v, want aes128gcm content encoding and a TTL", r.Header)
+		}
+		verifyVapid(t, r.Header.Get("Authorization"), pushService.URL)
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer pushService.Close()
+
+	store := &fakePushSubscriptionStore{}
+	store.Upsert(browser.subscription("guest", pushService.URL+"/push/1"))
+	webPush := notifier.NewWebPushNotifier(store, newVapidKeys(t), pushService.Client(), "https://zms.com")
+
+	err := webPush.Notify(newDelivery())
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(browser.decrypt(t, <-received), &payload); err != nil {
+		t.Fatalf("push payload is not JSON: %v", err)
+	}
+	if payload["message"] != "The host has confirmed your reservation #42." || payload["url"] != "https://zms.com/reservation/view/42" {
+		t.Errorf("payload = %v, want the message and the link", payload)
+	}
+}
+
+func TestWebPushNotifierPrunesExpiredSubscriptions(t *testing.T) {
+	pushService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer pushService.Close()
+
+	store := &fakePushSubscriptionStore{}
+	for _, path := range []string{"/gone", "/missing", "/active"} {
+		store.Upsert(newBrowserSubscription(t).subscription("guest", pushService.URL+path))
+	}
+	webPush := notifier.NewWebPushNotifier(store, newVapidKeys(t), pushService.Client(), "https://zms.com")
+
+	if err := webPush.Notify(domain.Delivery{Recipient: domain.Recipient{UserId: "guest"}, Message: "message"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	remaining, _ := store.GetByUserId("guest")
+	if len(remaining) != 1 || !strings.HasSuffix(remaining[0].Endpoint, "/active") {
+		t.Errorf("remaining subscriptions = %v, want only the active one", remaining)
+	}
+}
+
+func TestWebPushNotifierReportsFailures(t *testing.T) {
+	pushService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer pushService.Close()
+
+	store := &fakePushSubscriptionStore{}
+	store.Upsert(newBrowserSubscription(t).subscription("guest", pushService.URL))
+	webPush := notifier.NewWebPushNotifier(store, newVapidKeys(t), pushService.Client(), "https://zms.com")
+
+	if err := webPush.Notify(domain.Delivery{Recipient: domain.Recipient{UserId: "guest"}, Message: "message"}); err == nil {
+		t.Error("Notify() succeeded, want the push service error")
+	}
+	if remaining, _ := store.GetByUserId("guest"); len(remaining) != 1 {
+		t.Errorf("remaining subscriptions = %d, want the subscription kept", len(remaining))
+	}
+}
+
+func TestWebPushNotifierWithoutSubscriptions(t *testing.T) {
+	webPush := notifier.NewWebPushNotifier(&fakePushSubscriptionStore{}, newVapidKeys(t), http.DefaultClient, "https://zms.com")
+	if err := webPush.Notify(domain.Delivery{Recipient: domain.Recipient{UserId: "guest"}}); err != domain.ErrMissingContact {
+		t.Errorf("Notify() error = %v, want %v", err, domain.ErrMissingContact)
+	}
+}
@@ -0,0 +1,312 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mmmajder/zms-devops-notification-service/domain"
+	"github.com/mmmajder/zms-devops-notification-service/infrastructure/notifier"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeWebhookStore struct {
+	mutex    sync.Mutex
+	webhooks []*domain.Webhook
+	attempts []*domain.WebhookAttempt
+}
+
+func (store *fakeWebhookStore) find(id primitive.ObjectID) *domain.Webhook {
+	for _, webhook := range store.webhooks {
+		if webhook.Id == id {
+			return webhook
+		}
+	}
+	return nil
+}
+
+func (store *fakeWebhookStore) Get(userId string, id primitive.ObjectID) (*domain.Webhook, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if webhook := store.find(id); webhook != nil && webhook.UserId == userId {
+		return webhook, nil
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (store *fakeWebhookStore) GetByUserId(userId string) ([]*domain.Webhook, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	var webhooks []*domain.Webhook
+	for _, webhook := range store.webhooks {
+		if webhook.UserId == userId {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (store *fakeWebhookStore) GetActiveByUserId(userId string) ([]*domain.Webhook, error) {
+	webhooks, _ := store.GetByUserId(userId)
+	var active []*domain.Webhook
+	for _, webhook := range webhooks {
+		if webhook.Active {
+			active = append(active, webhook)
+		}
+	}
+	return active, nil
+}
+
+func (store *fakeWebhookStore) Insert(webhook *domain.Webhook) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	webhook.Id = primitive.NewObjectID()
+	store.webhooks = append(store.webhooks, webhook)
+	return nil
+}
+
+func (store *fakeWebhookStore) Delete(userId string, id primitive.ObjectID) (int64, error) {
+	return 0, nil
+}
+
+func (store *fakeWebhookStore) Enable(userId string, id primitive.ObjectID) (int64, error) {
+	return 0, nil
+}
+
+func (store *fakeWebhookStore) RecordSuccess(id primitive.ObjectID) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.find(id).ConsecutiveFailures = 0
+	return nil
+}
+
+func (store *fakeWebhookStore) RecordFailure(id primitive.ObjectID, disableAfter int) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	webhook := store.find(id)
+	webhook.ConsecutiveFailures++
+	if webhook.Active && webhook.ConsecutiveFailures >= disableAfter {
+		webhook.Active = false
+		return true, nil
+	}
+	return false, nil
+}
+
+func (store *fakeWebhookStore) InsertAttempt(attempt *domain.WebhookAttempt) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.attempts = append(store.attempts, attempt)
+	return nil
+}
+
+func (store *fakeWebhookStore) GetAttempts(webhookId primitive.ObjectID, limit int64) ([]*domain.WebhookAttempt, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	var attempts []*domain.WebhookAttempt
+	for _, attempt := range store.attempts {
+		if attempt.WebhookId == webhookId {
+			attempts = append(attempts, attempt)
+		}
+	}
+	return attempts, nil
+}
+
+func newWebhookNotifier(store domain.WebhookStore, jobs domain.JobStore, disableAfter int) *notifier.WebhookNotifier {
+	return notifier.NewWebhookNotifier(store, jobs, http.DefaultClient, notifier.WebhookConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     2 * time.Minute,
+		DisableAfter:   disableAfter,
+		AppUrl:         "https://zms.com",
+	})
+}
+
+// runWebhookRetries runs the scheduled retries until none are left, as replicas would once their
+// backoff has passed.
+func runWebhookRetries(jobs *fakeJobStore, webhooks *notifier.WebhookNotifier) {
+	scheduler := newScheduler(jobs, "replica-1")
+	scheduler.Register(domain.WebhookRetryJob, webhooks.Retry)
+	scheduler.RunDue(time.Now().Add(time.Hour))
+}
+
+func TestWebhookNotifierSignsEvents(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer endpoint.Close()
+
+	store := &fakeWebhookStore{}
+	store.Insert(&domain.Webhook{UserId: "host", Url: endpoint.URL, Secret: "whsec_test", Active: true})
+	if err := newWebhookNotifier(store, &fakeJobStore{}, 5).Notify(newDelivery(reservationRequest)); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	request, body := <-received, <-bodies
+	timestamp := request.Header.Get(notifier.WebhookTimestampHeader)
+	if signature := request.Header.Get(notifier.WebhookSignatureHeader); signature != "sha256="+notifier.SignWebhook("whsec_test", timestamp, body) {
+		t.Errorf("signature = %q, want the HMAC-SHA256 of the timestamp and body", signature)
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("event is not JSON: %v", err)
+	}
+	if event["id"] != request.Header.Get(notifier.WebhookEventIdHeader) || event["type"] != "new-reservation-request" || event["url"] != "https://zms.com/reservation/view/42" {
+		t.Errorf("event = %v, want the event id header, the type name and the link", event)
+	}
+	if attempts, _ := store.GetAttempts(store.webhooks[0].Id, 10); len(attempts) != 1 || !attempts[0].Success || attempts[0].StatusCode != http.StatusNoContent {
+		t.Errorf("attempts = %v, want one successful attempt", attempts)
+	}
+}
+
+func TestWebhookNotifierRetriesWithTheSameEventId(t *testing.T) {
+	var calls atomic.Int32
+	eventIds := make(chan string, 3)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		eventIds <- r.Header.Get(notifier.WebhookEventIdHeader)
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer endpoint.Close()
+
+	store := &fakeWebhookStore{}
+	store.Insert(&domain.Webhook{UserId: "host", Url: endpoint.URL, Secret: "secret", Active: true, ConsecutiveFailures: 2})
+	jobs := &fakeJobStore{}
+	webhooks := newWebhookNotifier(store, jobs, 5)
+	if err := webhooks.Notify(newDelivery(reservationRequest)); !errors.Is(err, domain.ErrRetryScheduled) {
+		t.Fatalf("Notify() error = %v, want %v", err, domain.ErrRetryScheduled)
+	}
+	if len(jobs.jobs) != 1 || jobs.jobs[0].RunAt.Before(time.Now().Add(59*time.Second)) {
+		t.Fatalf("jobs = %v, want a retry after the initial backoff", jobs.jobs)
+	}
+	runWebhookRetries(jobs, webhooks)
+
+	first := <-eventIds
+	if second, third := <-eventIds, <-eventIds; second != first || third != first {
+		t.Errorf("event ids = %s, %s, %s, want the same id on every retry", first, second, third)
+	}
+	attempts, _ := store.GetAttempts(store.webhooks[0].Id, 10)
+	if len(attempts) != 3 || attempts[0].StatusCode != http.StatusServiceUnavailable || attempts[2].Attempt != 3 || !attempts[2].Success {
+		t.Errorf("attempts = %v, want two failures followed by a success", attempts)
+	}
+	if store.webhooks[0].ConsecutiveFailures != 0 {
+		t.Errorf("consecutive failures = %d, want them reset by the success", store.webhooks[0].ConsecutiveFailures)
+	}
+}
+
+func TestWebhookNotifierDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer endpoint.Close()
+
+	store := &fakeWebhookStore{}
+	store.Insert(&domain.Webhook{UserId: "host", Url: endpoint.URL, Secret: "secret", Active: true})
+	jobs := &fakeJobStore{}
+	if err := newWebhookNotifier(store, jobs, 5).Notify(newDelivery(reservationRequest)); err == nil || errors.Is(err, domain.ErrRetryScheduled) {
+		t.Errorf("Notify() error = %v, want the webhook error without retries", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want a single attempt", calls.Load())
+	}
+}
+
+func TestWebhookNotifierDisablesFailingWebhooks(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer endpoint.Close()
+
+	store := &fakeWebhookStore{}
+	store.Insert(&domain.Webhook{UserId: "host", Url: endpoint.URL, Secret: "secret", Active: true})
+	jobs := &fakeJobStore{}
+	webhooks := newWebhookNotifier(store, jobs, 2)
+
+	for i := 0; i < 2; i++ {
+		if err := webhooks.Notify(newDelivery(reservationRequest)); !errors.Is(err, domain.ErrRetryScheduled) {
+			t.Fatalf("Notify() error = %v, want a retry scheduled", err)
+		}
+		runWebhookRetries(jobs, webhooks)
+	}
+	if store.webhooks[0].Active {
+		t.Error("webhook is still active after failing twice in a row")
+	}
+	if err := webhooks.Notify(newDelivery(reservationRequest)); !errors.Is(err, domain.ErrMissingContact) {
+		t.Errorf("Notify() error = %v, want %v for a disabled webhook", err, domain.ErrMissingContact)
+	}
+	if attempts, _ := store.GetAttempts(store.webhooks[0].Id, 10); len(attempts) != 6 {
+		t.Errorf("attempts = %d, want three attempts for each of the two deliveries", len(attempts))
+	}
+}
+
+func TestWebhookNotifierFiltersEventTypes(t *testing.T) {
+	var calls atomic.Int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer endpoint.Close()
+
+	store := &fakeWebhookStore{}
+	store.Insert(&domain.Webhook{UserId: "host", Url: endpoint.URL, Secret: "secret", Active: true, EventTypes: []domain.NotificationType{domain.CancelReservation}})
+	store.Insert(&domain.Webhook{UserId: "host", Url: endpoint.URL, Secret: "secret", Active: true})
+	if err := newWebhookNotifier(store, &fakeJobStore{}, 5).Notify(newDelivery(reservationRequest)); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want only the webhook receiving every type", calls.Load())
+	}
+}
+
+func TestCheckWebhookUrl(t *testing.T) {
+	tests := []struct {
+		url  string
+		safe bool
+	}{
+		{"https://93.184.216.34/hooks", true},
+		{"http://93.184.216.34/hooks", false},
+		{"https://127.0.0.1/hooks", false},
+		{"https://localhost/hooks", false},
+		{"https://10.0.0.7/hooks", false},
+		{"https://192.168.1.1/hooks", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://100.64.0.1/hooks", false},
+		{"https://[::1]/hooks", false},
+		{"https://[fd00:ec2::254]/hooks", false},
+		{"https://[::ffff:10.0.0.1]/hooks", false},
+	}
+
+	for _, test := range tests {
+		if err := notifier.CheckWebhookUrl(test.url); (err == nil) != test.safe {
+			t.Errorf("CheckWebhookUrl(%q) error = %v, want safe = %t", test.url, err, test.safe)
+		}
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddressesAndRedirects(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer endpoint.Close()
+
+	client := notifier.NewWebhookHttpClient(time.Second)
+	if _, err := client.Get(endpoint.URL); !errors.Is(err, notifier.ErrPrivateWebhookAddress) {
+		t.Errorf("Get() error = %v, want %v", err, notifier.ErrPrivateWebhookAddress)
+	}
+	if err := client.CheckRedirect(httptest.NewRequest(http.MethodPost, "https://example.com", nil), nil); !errors.Is(err, http.ErrUseLastResponse) {
+		t.Errorf("CheckRedirect() = %v, want redirects not followed", err)
+	}
+}